@@ -0,0 +1,129 @@
+// Package domains checks custom domain names and verifies their ownership with a
+// DNS TXT record. Lookups go through a TxtResolver so tests need no network access.
+package domains
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The owner of example.com proves it by publishing
+// _u235-verify.example.com TXT "u235-verify=<token>"
+const (
+	RecordPrefix = "_u235-verify."
+	ValuePrefix  = "u235-verify="
+)
+
+const (
+	tokenBytes           = 16
+	defaultLookupTimeout = 5 * time.Second
+)
+
+var (
+	ErrInvalidHostname = errors.New("not a valid domain name")
+	ErrRecordNotFound  = errors.New("verification TXT record not found")
+	ErrLookupFailed    = errors.New("verification TXT record could not be looked up")
+)
+
+// TxtResolver looks up the TXT records of a name; *net.Resolver implements it
+type TxtResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// NormalizeHostname lower cases a domain name and drops a trailing dot. Schemes,
+// ports, paths and IP addresses are rejected, and the name must have at least
+// two labels of letters, digits and inner hyphens.
+func NormalizeHostname(hostname string) (string, error) {
+	host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+	if host == "" || len(host) > 253 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidHostname, hostname)
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return "", fmt.Errorf("%w: %q is an IP address", ErrInvalidHostname, hostname)
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidHostname, hostname)
+	}
+	for _, label := range labels {
+		if !validLabel(label) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidHostname, hostname)
+		}
+	}
+	return host, nil
+}
+
+func validLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewToken returns a random verification token
+func NewToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RecordName is the name the verification TXT record of hostname is published at
+func RecordName(hostname string) string {
+	return RecordPrefix + hostname
+}
+
+// RecordValue is the content of the verification TXT record for token
+func RecordValue(token string) string {
+	return ValuePrefix + token
+}
+
+type Verifier struct {
+	resolver TxtResolver
+	timeout  time.Duration
+}
+
+// NewVerifier returns a Verifier using resolver, with a lookup timeout of 5s by default
+func NewVerifier(resolver TxtResolver, timeout time.Duration) *Verifier {
+	if timeout <= 0 {
+		timeout = defaultLookupTimeout
+	}
+	return &Verifier{resolver: resolver, timeout: timeout}
+}
+
+// Verify returns nil when hostname publishes the verification record for token,
+// or an error wrapping ErrRecordNotFound or ErrLookupFailed.
+func (v *Verifier) Verify(ctx context.Context, hostname, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	records, err := v.resolver.LookupTXT(ctx, RecordName(hostname))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return fmt.Errorf("%w at %s", ErrRecordNotFound, RecordName(hostname))
+		}
+		return fmt.Errorf("%w: %v", ErrLookupFailed, err)
+	}
+
+	want := RecordValue(token)
+	if slices.ContainsFunc(records, func(record string) bool { return strings.TrimSpace(record) == want }) {
+		return nil
+	}
+	return fmt.Errorf("%w at %s", ErrRecordNotFound, RecordName(hostname))
+}
@@ -0,0 +1,99 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// stubResolver answers from a fixed table; names without an entry do not exist
+type stubResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *stubResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestNormalizeHostname(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"go.brand-a.com", "go.brand-a.com", false},
+		{" Brand-B.LINK. ", "brand-b.link", false},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example", false},
+		{"localhost", "", true},
+		{"https://brand.com", "", true},
+		{"brand.com:8080", "", true},
+		{"brand.com/x", "", true},
+		{"-brand.com", "", true},
+		{"brand-.com", "", true},
+		{"bra_nd.com", "", true},
+		{"brand..com", "", true},
+		{"192.168.1.1", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeHostname(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeHostname(%q) = %q, %v; want %q, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidHostname) {
+			t.Errorf("NormalizeHostname(%q) error = %v, want %v", tt.in, err, ErrInvalidHostname)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	resolver := &stubResolver{records: map[string][]string{
+		"_u235-verify.brand-a.com": {"v=spf1 -all", "u235-verify=abc123"},
+		"_u235-verify.brand-b.com": {"u235-verify=other"},
+	}}
+	v := NewVerifier(resolver, 0)
+
+	tests := []struct {
+		name     string
+		hostname string
+		token    string
+		want     error
+	}{
+		{"record present", "brand-a.com", "abc123", nil},
+		{"wrong token", "brand-b.com", "abc123", ErrRecordNotFound},
+		{"no record", "brand-c.com", "abc123", ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify(context.Background(), tt.hostname, tt.token)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("Verify(%q) = %v, want %v", tt.hostname, err, tt.want)
+			}
+		})
+	}
+
+	failing := NewVerifier(&stubResolver{err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}, 0)
+	if err := failing.Verify(context.Background(), "brand-a.com", "abc123"); !errors.Is(err, ErrLookupFailed) {
+		t.Errorf("Verify() with a failing resolver = %v, want %v", err, ErrLookupFailed)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatalf("NewToken() error = %v", err)
+	}
+	b, _ := NewToken()
+	if len(a) != tokenBytes*2 || a == b {
+		t.Errorf("NewToken() = %q, %q; want distinct %d character tokens", a, b, tokenBytes*2)
+	}
+}
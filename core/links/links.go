@@ -0,0 +1,91 @@
+// Package links builds the public URLs of short links from the configured domains.
+package links
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Builder joins slugs to the configured base URLs. The first one is canonical:
+// API responses, exports and QR codes all use it, while the others are aliases
+// that short links are served on as well. Links on a custom domain are built on
+// that domain instead.
+type Builder struct {
+	bases []*url.URL
+}
+
+// NewBuilder parses a comma separated list of domains, each written like
+// "u235.link/" or "https://u235.link"; see BaseUrl. An empty list builds
+// root-relative links.
+func NewBuilder(domains string) (*Builder, error) {
+	b := &Builder{}
+	for _, domain := range strings.Split(domains, ",") {
+		base := BaseUrl(domain)
+		if base == "" {
+			continue
+		}
+		u, err := url.Parse(base)
+		if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid domain %q", strings.TrimSpace(domain))
+		}
+		b.bases = append(b.bases, u)
+	}
+	return b, nil
+}
+
+// Link returns the absolute URL of the short link slug: on the custom domain
+// when one is given, over https, otherwise on the canonical domain.
+func (b *Builder) Link(domain, slug string) string {
+	if domain != "" {
+		return "https://" + domain + "/" + url.PathEscape(slug)
+	}
+	return b.Base() + "/" + url.PathEscape(slug)
+}
+
+// Base returns the canonical base URL, without a trailing slash
+func (b *Builder) Base() string {
+	if len(b.bases) == 0 {
+		return ""
+	}
+	return b.bases[0].String()
+}
+
+// Hosts returns the host name of every configured domain, canonical first
+func (b *Builder) Hosts() []string {
+	hosts := make([]string, len(b.bases))
+	for i, base := range b.bases {
+		hosts[i] = base.Hostname()
+	}
+	return hosts
+}
+
+// IsDefaultHost reports whether host is the host name of a configured domain
+func (b *Builder) IsDefaultHost(host string) bool {
+	for _, base := range b.bases {
+		if strings.EqualFold(base.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// BaseUrl turns a domain setting, written like "u235.link/" or "https://u235.link",
+// into the absolute URL short links are joined to, without a trailing slash.
+// Without a scheme, https is assumed except for localhost and loopback addresses.
+func BaseUrl(domain string) string {
+	domain = strings.TrimRight(strings.TrimSpace(domain), "/")
+	if domain == "" || strings.Contains(domain, "://") {
+		return domain
+	}
+
+	host, _, _ := strings.Cut(domain, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return "http://" + domain
+	}
+	return "https://" + domain
+}
@@ -0,0 +1,71 @@
+package links
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBaseUrl(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"u235.link/", "https://u235.link"},
+		{"u235.link", "https://u235.link"},
+		{"https://u235.link/", "https://u235.link"},
+		{"http://go.example.com", "http://go.example.com"},
+		{"example.com/s/", "https://example.com/s"},
+		{"localhost:1111/", "http://localhost:1111"},
+		{"127.0.0.1:1111", "http://127.0.0.1:1111"},
+		{"[::1]:1111", "http://[::1]:1111"},
+		{" ", ""},
+	}
+	for _, tt := range tests {
+		if got := BaseUrl(tt.domain); got != tt.want {
+			t.Errorf("BaseUrl(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		domains   string
+		wantLink  string
+		wantHosts []string
+	}{
+		{"u235.link/", "https://u235.link/abc1234", []string{"u235.link"}},
+		{"https://u235.link, go.u235.link/ ,localhost:1111", "https://u235.link/abc1234", []string{"u235.link", "go.u235.link", "localhost"}},
+		{"example.com/s/", "https://example.com/s/abc1234", []string{"example.com"}},
+		{"", "/abc1234", []string{}},
+	}
+	for _, tt := range tests {
+		b, err := NewBuilder(tt.domains)
+		if err != nil {
+			t.Fatalf("NewBuilder(%q) error = %v", tt.domains, err)
+		}
+		if got := b.Link("", "abc1234"); got != tt.wantLink {
+			t.Errorf("NewBuilder(%q).Link() = %q, want %q", tt.domains, got, tt.wantLink)
+		}
+		if got := b.Hosts(); !slices.Equal(got, tt.wantHosts) {
+			t.Errorf("NewBuilder(%q).Hosts() = %v, want %v", tt.domains, got, tt.wantHosts)
+		}
+		if got := b.Link("go.brand-a.com", "abc1234"); got != "https://go.brand-a.com/abc1234" {
+			t.Errorf("NewBuilder(%q).Link() on a custom domain = %q", tt.domains, got)
+		}
+	}
+
+	b, _ := NewBuilder("https://u235.link,localhost:1111")
+	for host, want := range map[string]bool{"u235.link": true, "LOCALHOST": true, "go.brand-a.com": false, "": false} {
+		if got := b.IsDefaultHost(host); got != want {
+			t.Errorf("IsDefaultHost(%q) = %t, want %t", host, got, want)
+		}
+	}
+}
+
+func TestNewBuilderInvalid(t *testing.T) {
+	for _, domains := range []string{"ftp://u235.link", "https://:80", "u235.link,mailto:x"} {
+		if _, err := NewBuilder(domains); err == nil {
+			t.Errorf("NewBuilder(%q) error = nil, want an error", domains)
+		}
+	}
+}
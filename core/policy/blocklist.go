@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Blocklist is a set of blocked domains. A listed domain also blocks every
+// subdomain, so "example.com" blocks "www.example.com" but not "notexample.com".
+type Blocklist struct {
+	domains map[string]struct{}
+}
+
+// LoadBlocklist reads a blocklist file, see ParseBlocklist
+func LoadBlocklist(path string) (*Blocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer f.Close()
+	return ParseBlocklist(f)
+}
+
+// ParseBlocklist reads one domain per line. Lines in hosts file format
+// ("0.0.0.0 example.com") and wildcards ("*.example.com") are accepted too;
+// blank lines and text after '#' are ignored.
+func ParseBlocklist(r io.Reader) (*Blocklist, error) {
+	b := &Blocklist{domains: make(map[string]struct{})}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("blocklist line %d: expected a domain", line)
+		}
+		domain := normalizeHost(strings.TrimPrefix(fields[len(fields)-1], "*."))
+		if domain == "" || strings.ContainsAny(domain, "/:@") {
+			return nil, fmt.Errorf("blocklist line %d: invalid domain %q", line, fields[len(fields)-1])
+		}
+		b.domains[domain] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return b, nil
+}
+
+// Len returns the number of blocked domains
+func (b *Blocklist) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.domains)
+}
+
+// Blocks reports whether host or one of its parent domains is listed
+func (b *Blocklist) Blocks(host string) bool {
+	if b == nil || len(b.domains) == 0 {
+		return false
+	}
+	host = normalizeHost(host)
+	for host != "" {
+		if _, ok := b.domains[host]; ok {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return false
+}
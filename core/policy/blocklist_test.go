@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	b, err := ParseBlocklist(strings.NewReader(`# phishing
+evil.test
+*.Tracker.Test.   # wildcard and trailing dot
+0.0.0.0 ads.test
+
+`))
+	if err != nil {
+		t.Fatalf("ParseBlocklist() error = %v", err)
+	}
+	if b.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", b.Len())
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"evil.test", true},
+		{"EVIL.TEST.", true},
+		{"a.b.evil.test", true},
+		{"notevil.test", false},
+		{"tracker.test", true},
+		{"x.tracker.test", true},
+		{"ads.test", true},
+		{"0.0.0.0", false},
+		{"test", false},
+	}
+	for _, tt := range tests {
+		if got := b.Blocks(tt.host); got != tt.want {
+			t.Errorf("Blocks(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	var empty *Blocklist
+	if empty.Blocks("evil.test") || empty.Len() != 0 {
+		t.Error("nil Blocklist blocks hosts")
+	}
+
+	for _, bad := range []string{"a b c\n", "https://evil.test/\n"} {
+		if _, err := ParseBlocklist(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseBlocklist(%q) error = nil, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package policy decides whether a URL may be used as the destination of a
+// short link. DNS lookups go through a Resolver so the checks can run without
+// network access in tests.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Reasons a destination is rejected. Check wraps them with the offending value.
+var (
+	ErrInvalidURL       = errors.New("destination must be an absolute URL with a host")
+	ErrSchemeNotAllowed = errors.New("destination scheme is not allowed")
+	ErrSelfReferential  = errors.New("destination points back at this service")
+	ErrBlockedDomain    = errors.New("destination domain is blocked")
+	ErrPrivateAddress   = errors.New("destination resolves to a private, loopback or link-local address")
+	ErrUnresolvable     = errors.New("destination host could not be resolved")
+)
+
+// DefaultSchemes are allowed when Options.Schemes is empty
+var DefaultSchemes = []string{"http", "https"}
+
+const (
+	defaultLookupTimeout  = 3 * time.Second
+	defaultLookupCacheTTL = time.Minute
+)
+
+// reservedPrefixes are non-public ranges that netip has no predicate for
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+}
+
+// DestinationPolicy rejects destinations that short links must not redirect to
+type DestinationPolicy interface {
+	Check(ctx context.Context, destination string) error
+}
+
+// HostSet is a set of host names that can change at run time, such as the
+// verified custom domains links are served on
+type HostSet interface {
+	Contains(ctx context.Context, host string) bool
+}
+
+// Resolver looks up the addresses of a host; *net.Resolver implements it
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+type Options struct {
+	// Schemes lists the allowed URL schemes, in lower case
+	Schemes []string
+	// SelfHosts are the host names this service answers on; links to them would loop
+	SelfHosts []string
+	// CustomHosts are further self hosts looked up on every check; nil has none
+	CustomHosts HostSet
+	// Blocklist rejects listed domains and their subdomains; nil blocks nothing
+	Blocklist *Blocklist
+	// AllowPrivate skips the address checks, for local development
+	AllowPrivate bool
+	// Resolver defaults to net.DefaultResolver behind a CachingResolver
+	Resolver Resolver
+	// LookupTimeout bounds each DNS lookup, 3s by default
+	LookupTimeout time.Duration
+}
+
+// Policy is the DestinationPolicy configured by Options
+type Policy struct {
+	schemes       []string
+	selfHosts     []string
+	customHosts   HostSet
+	blocklist     *Blocklist
+	allowPrivate  bool
+	resolver      Resolver
+	lookupTimeout time.Duration
+}
+
+func New(opts Options) *Policy {
+	p := &Policy{
+		schemes:       opts.Schemes,
+		customHosts:   opts.CustomHosts,
+		blocklist:     opts.Blocklist,
+		allowPrivate:  opts.AllowPrivate,
+		resolver:      opts.Resolver,
+		lookupTimeout: opts.LookupTimeout,
+	}
+	if len(p.schemes) == 0 {
+		p.schemes = DefaultSchemes
+	}
+	for _, host := range opts.SelfHosts {
+		if host = normalizeHost(host); host != "" {
+			p.selfHosts = append(p.selfHosts, host)
+		}
+	}
+	if p.resolver == nil {
+		p.resolver = NewCachingResolver(net.DefaultResolver, defaultLookupCacheTTL)
+	}
+	if p.lookupTimeout <= 0 {
+		p.lookupTimeout = defaultLookupTimeout
+	}
+	return p
+}
+
+// Check returns nil when destination may be used, or an error wrapping one of the
+// Err values above. Every address a host resolves to must be public.
+func (p *Policy) Check(ctx context.Context, destination string) error {
+	u, err := url.Parse(destination)
+	if err != nil || !u.IsAbs() {
+		return ErrInvalidURL
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(p.schemes, scheme) {
+		return fmt.Errorf("%w: %s", ErrSchemeNotAllowed, scheme)
+	}
+	host := normalizeHost(u.Hostname())
+	if host == "" {
+		return ErrInvalidURL
+	}
+	if slices.Contains(p.selfHosts, host) || (p.customHosts != nil && p.customHosts.Contains(ctx, host)) {
+		return fmt.Errorf("%w: %s", ErrSelfReferential, host)
+	}
+	if p.blocklist.Blocks(host) {
+		return fmt.Errorf("%w: %s", ErrBlockedDomain, host)
+	}
+	if p.allowPrivate {
+		return nil
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !IsPublicAddr(addr) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+		}
+		return nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, p.lookupTimeout)
+	defer cancel()
+	addrs, err := p.resolver.LookupNetIP(lookupCtx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: %s", ErrUnresolvable, host)
+	}
+	for _, addr := range addrs {
+		if !IsPublicAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, addr.Unmap())
+		}
+	}
+	return nil
+}
+
+// IsPublicAddr reports whether addr is a globally routable unicast address
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeHost lower cases a host name and drops a trailing dot and IPv6 brackets
+func normalizeHost(host string) string {
+	host = strings.TrimSpace(strings.ToLower(host))
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// stubResolver answers from a fixed table and counts lookups
+type stubResolver struct {
+	hosts   map[string][]string
+	lookups int
+}
+
+func (r *stubResolver) LookupNetIP(_ context.Context, _ string, host string) ([]netip.Addr, error) {
+	r.lookups++
+	ips, ok := r.hosts[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	addrs := make([]netip.Addr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = netip.MustParseAddr(ip)
+	}
+	return addrs, nil
+}
+
+// stubHostSet holds host names already normalized by the policy
+type stubHostSet map[string]bool
+
+func (s stubHostSet) Contains(_ context.Context, host string) bool {
+	return s[host]
+}
+
+func TestCheck(t *testing.T) {
+	blocklist, err := ParseBlocklist(strings.NewReader("malware.test\n"))
+	if err != nil {
+		t.Fatalf("ParseBlocklist() error = %v", err)
+	}
+	resolver := &stubResolver{hosts: map[string][]string{
+		"example.com":        {"93.184.215.14", "2606:2800:21f:cb07:6820:80da:af6b:8b2c"},
+		"internal.corp.test": {"10.1.2.3"},
+		"mixed.test":         {"93.184.215.14", "127.0.0.1"},
+		"metadata.test":      {"169.254.169.254"},
+		"mapped.test":        {"::ffff:192.168.1.1"},
+	}}
+	p := New(Options{
+		SelfHosts:   []string{"u235.link", "Go.U235.Link."},
+		CustomHosts: stubHostSet{"go.brand-a.com": true},
+		Blocklist:   blocklist,
+		Resolver:    resolver,
+	})
+
+	tests := []struct {
+		name        string
+		destination string
+		want        error
+	}{
+		{"public https", "https://example.com/path?q=1", nil},
+		{"public http with port", "http://example.com:8080/", nil},
+		{"upper case scheme", "HTTPS://EXAMPLE.COM/", nil},
+		{"javascript", "javascript:alert(1)", ErrSchemeNotAllowed},
+		{"file", "file:///etc/passwd", ErrSchemeNotAllowed},
+		{"data", "data:text/html,<script>alert(1)</script>", ErrSchemeNotAllowed},
+		{"ftp", "ftp://example.com/file", ErrSchemeNotAllowed},
+		{"relative", "/some/path", ErrInvalidURL},
+		{"no host", "https:///path", ErrInvalidURL},
+		{"loopback literal", "http://127.0.0.1/admin", ErrPrivateAddress},
+		{"loopback v6 literal", "http://[::1]:8080/", ErrPrivateAddress},
+		{"private literal", "http://192.168.0.10/", ErrPrivateAddress},
+		{"unspecified literal", "http://0.0.0.0/", ErrPrivateAddress},
+		{"cgnat literal", "http://100.64.1.1/", ErrPrivateAddress},
+		{"link-local literal", "http://169.254.169.254/latest/meta-data", ErrPrivateAddress},
+		{"public literal", "http://8.8.8.8/", nil},
+		{"resolves private", "https://internal.corp.test/", ErrPrivateAddress},
+		{"any private address rejects", "https://mixed.test/", ErrPrivateAddress},
+		{"resolves link-local", "http://metadata.test/", ErrPrivateAddress},
+		{"resolves mapped private", "http://mapped.test/", ErrPrivateAddress},
+		{"unresolvable", "https://nxdomain.test/", ErrUnresolvable},
+		{"self host", "https://u235.link/abc1234", ErrSelfReferential},
+		{"self host case and dot", "https://go.u235.link./x", ErrSelfReferential},
+		{"self host userinfo trick", "https://example.com@u235.link/x", ErrSelfReferential},
+		{"custom domain", "https://Go.Brand-A.com/x", ErrSelfReferential},
+		{"blocked domain", "https://malware.test/", ErrBlockedDomain},
+		{"blocked subdomain", "https://cdn.malware.test/x", ErrBlockedDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(context.Background(), tt.destination)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("Check(%q) = %v, want %v", tt.destination, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAllowPrivate(t *testing.T) {
+	resolver := &stubResolver{}
+	p := New(Options{AllowPrivate: true, Resolver: resolver, SelfHosts: []string{"localhost"}})
+
+	if err := p.Check(context.Background(), "http://127.0.0.1:3000/"); err != nil {
+		t.Errorf("Check() with AllowPrivate = %v, want nil", err)
+	}
+	if err := p.Check(context.Background(), "http://localhost:1111/x"); !errors.Is(err, ErrSelfReferential) {
+		t.Errorf("Check() self host with AllowPrivate = %v, want %v", err, ErrSelfReferential)
+	}
+	if err := p.Check(context.Background(), "javascript:alert(1)"); !errors.Is(err, ErrSchemeNotAllowed) {
+		t.Errorf("Check() javascript with AllowPrivate = %v, want %v", err, ErrSchemeNotAllowed)
+	}
+	if resolver.lookups != 0 {
+		t.Errorf("AllowPrivate made %d DNS lookups, want 0", resolver.lookups)
+	}
+}
+
+func TestCheckCustomSchemes(t *testing.T) {
+	p := New(Options{Schemes: []string{"https"}, Resolver: &stubResolver{}})
+	if err := p.Check(context.Background(), "http://8.8.8.8/"); !errors.Is(err, ErrSchemeNotAllowed) {
+		t.Errorf("Check() http with https only = %v, want %v", err, ErrSchemeNotAllowed)
+	}
+	if err := p.Check(context.Background(), "https://8.8.8.8/"); err != nil {
+		t.Errorf("Check() https = %v, want nil", err)
+	}
+}
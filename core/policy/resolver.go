@@ -0,0 +1,59 @@
+package policy
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// maxCachedHosts bounds the lookup cache; it is emptied when full
+const maxCachedHosts = 10000
+
+type cachedLookup struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// CachingResolver remembers successful lookups for a while, so bulk creation and
+// imports of many links to the same site resolve it once. Failures are not cached.
+type CachingResolver struct {
+	resolver Resolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]cachedLookup
+}
+
+func NewCachingResolver(resolver Resolver, ttl time.Duration) *CachingResolver {
+	return &CachingResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		now:      time.Now,
+		hosts:    make(map[string]cachedLookup),
+	}
+}
+
+func (c *CachingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	key := network + "/" + host
+	c.mu.Lock()
+	cached, ok := c.hosts[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.addrs, nil
+	}
+
+	addrs, err := c.resolver.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.hosts) >= maxCachedHosts {
+		clear(c.hosts)
+	}
+	c.hosts[key] = cachedLookup{addrs: addrs, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
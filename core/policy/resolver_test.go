@@ -0,0 +1,42 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCachingResolver(t *testing.T) {
+	stub := &stubResolver{hosts: map[string][]string{"example.com": {"93.184.215.14"}}}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolver := NewCachingResolver(stub, time.Minute)
+	resolver.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.LookupNetIP(ctx, "ip", "example.com"); err != nil {
+			t.Fatalf("LookupNetIP() error = %v", err)
+		}
+	}
+	if stub.lookups != 1 {
+		t.Errorf("lookups = %d, want 1 while cached", stub.lookups)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := resolver.LookupNetIP(ctx, "ip", "example.com"); err != nil {
+		t.Fatalf("LookupNetIP() error = %v", err)
+	}
+	if stub.lookups != 2 {
+		t.Errorf("lookups = %d, want 2 after expiry", stub.lookups)
+	}
+
+	// Failures are retried rather than cached
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.LookupNetIP(ctx, "ip", "nxdomain.test"); err == nil {
+			t.Fatal("LookupNetIP() error = nil for unknown host")
+		}
+	}
+	if stub.lookups != 4 {
+		t.Errorf("lookups = %d, want 4", stub.lookups)
+	}
+}
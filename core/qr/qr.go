@@ -0,0 +1,464 @@
+// Package qr encodes text as a QR code (ISO/IEC 18004, model 2) and renders it
+// as PNG or SVG. Text is always encoded in byte mode, which covers URLs of any
+// case; the smallest version that fits at the requested level is used.
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is the error correction level, the share of the symbol that can be
+// damaged and still read: about 7% for L, 15% for M, 25% for Q and 30% for H.
+type Level int
+
+const (
+	LevelL Level = iota
+	LevelM
+	LevelQ
+	LevelH
+)
+
+// ErrTooLong is returned when the text does not fit in a version 40 symbol
+var ErrTooLong = errors.New("text is too long for a QR code")
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// ParseLevel reads "L", "M", "Q" or "H", in either case
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return LevelL, nil
+	case "M":
+		return LevelM, nil
+	case "Q":
+		return LevelQ, nil
+	case "H":
+		return LevelH, nil
+	}
+	return 0, fmt.Errorf("unknown error correction level %q", s)
+}
+
+func (l Level) String() string {
+	return [...]string{"L", "M", "Q", "H"}[l]
+}
+
+// formatBits is the level's two-bit code in the format information
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+// Code is an encoded QR symbol, without its quiet zone
+type Code struct {
+	// Size is the width and height in modules, 21 for version 1 up to 177 for version 40
+	Size    int
+	Version int
+	Level   Level
+	Mask    int
+
+	modules    []bool // dark modules, row by row
+	isFunction []bool // finder, timing, alignment and format modules, which masks skip
+}
+
+// Dark reports whether the module at column x and row y is dark. Coordinates
+// outside the symbol are light, like the quiet zone around it.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// Encode returns the smallest QR code holding text at the given level, with the
+// mask that scores best against the patterns that confuse readers.
+func Encode(text string, level Level) (*Code, error) {
+	if level < LevelL || level > LevelH {
+		return nil, fmt.Errorf("unknown error correction level %d", level)
+	}
+	data := []byte(text)
+
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if dataBits(len(data), version) <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	c := &Code{
+		Size:    version*4 + 17,
+		Version: version,
+		Level:   level,
+	}
+	c.modules = make([]bool, c.Size*c.Size)
+	c.isFunction = make([]bool, c.Size*c.Size)
+	c.drawFunctionPatterns()
+	c.drawCodewords(addErrorCorrection(encodeData(data, version, level), version, level))
+
+	bestPenalty := -1
+	for mask := range 8 {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			c.Mask, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask) // masks are XOR, so applying again undoes it
+	}
+	c.applyMask(c.Mask)
+	c.drawFormatBits(c.Mask)
+	return c, nil
+}
+
+// charCountBits is the length of the byte mode character count for version
+func charCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// dataBits is the length of n bytes in byte mode, header included
+func dataBits(n, version int) int {
+	if n >= 1<<charCountBits(version) {
+		return 1 << 30 // the count does not fit, so neither does the data
+	}
+	return 4 + charCountBits(version) + n*8
+}
+
+// encodeData builds the data codewords: the byte mode segment, a terminator
+// and the alternating pad bytes that fill the rest of the capacity.
+func encodeData(data []byte, version int, level Level) []byte {
+	capacity := numDataCodewords(version, level)
+	var bb bitBuffer
+	bb.append(0b0100, 4)
+	bb.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	bb.append(0, min(4, capacity*8-bb.len))
+	bb.append(0, -bb.len&7)
+
+	codewords := bb.bytes()
+	for pad := byte(0xEC); len(codewords) < capacity; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// addErrorCorrection splits data into the version's blocks, appends the Reed-Solomon
+// codewords of each and interleaves the blocks as they are placed in the symbol.
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortDataLen := rawCodewords/numBlocks - eccLen
+
+	divisor := reedSolomonDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	eccs := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortDataLen
+		if i >= numShortBlocks {
+			n++
+		}
+		blocks[i] = data[k : k+n]
+		eccs[i] = reedSolomonRemainder(blocks[i], divisor)
+		k += n
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i <= shortDataLen; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := range eccLen {
+		for _, ecc := range eccs {
+			result = append(result, ecc[i])
+		}
+	}
+	return result
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+}
+
+// setFunction draws a module that carries no data
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.isFunction[y*c.Size+x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := range c.Size {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.Size-4, 3)
+	c.drawFinderPattern(3, c.Size-4)
+
+	positions := alignmentPatternPositions(c.Version)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			// The corners taken by finder patterns get none
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// Reserve the format areas; the bits are drawn once the mask is known
+	c.drawFormatBits(0)
+	c.drawVersionBits()
+}
+
+// drawFinderPattern draws the 7x7 finder centred on x, y and its light separator
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatBits returns the 15 bit format information: level and mask, protected
+// by a BCH code and XORed so it is never all light.
+func formatBits(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits returns the 18 bit version information, protected by a BCH code
+func versionBits(version int) int {
+	rem := version
+	for range 12 {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawFormatBits draws both copies of the format information
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(c.Level, mask)
+
+	// Around the top left finder
+	for i := range 6 {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Split between the other two finders
+	for i := range 8 {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true) // always dark
+}
+
+// drawVersionBits draws both copies of the version, from version 7 up
+func (c *Code) drawVersionBits() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+
+	for i := range 18 {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in two module wide columns, zigzagging up
+// and down from the bottom right corner and skipping the function patterns.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // the vertical timing pattern takes a whole column
+		}
+		upward := (right+1)&2 == 0
+		for vert := range c.Size {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := range 2 {
+				x := right - j
+				if c.isFunction[y*c.Size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				c.set(x, y, bit(int(codewords[i>>3]), 7-(i&7)))
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by one of the eight mask patterns
+func (c *Code) applyMask(mask int) {
+	for y := range c.Size {
+		for x := range c.Size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.isFunction[y*c.Size+x] {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// Penalty weights of the mask evaluation rules
+const (
+	penaltyRun        = 3  // five or more same coloured modules in a line
+	penaltyBox        = 3  // a 2x2 block of one colour
+	penaltyFinderLike = 40 // a 1:1:3:1:1 pattern next to four light modules
+	penaltyBalance    = 10 // each 5% the dark share strays from 50%
+)
+
+// finderLike is the 1:1:3:1:1 pattern followed by four light modules
+var finderLike = []bool{true, false, true, true, true, false, true, false, false, false, false}
+
+// penalty scores the symbol by the four rules of the standard; lower is better
+func (c *Code) penalty() int {
+	result := 0
+	for i := range c.Size {
+		result += c.linePenalty(func(j int) bool { return c.Dark(j, i) })
+		result += c.linePenalty(func(j int) bool { return c.Dark(i, j) })
+	}
+
+	dark := 0
+	for y := range c.Size {
+		for x := range c.Size {
+			d := c.Dark(x, y)
+			if d {
+				dark++
+			}
+			if x > 0 && y > 0 && d == c.Dark(x-1, y) && d == c.Dark(x, y-1) && d == c.Dark(x-1, y-1) {
+				result += penaltyBox
+			}
+		}
+	}
+	total := c.Size * c.Size
+	result += abs(dark*20-total*10) / total * penaltyBalance
+	return result
+}
+
+// linePenalty scores one row or column for runs and finder-like patterns
+func (c *Code) linePenalty(at func(int) bool) int {
+	result := 0
+	run := 0
+	for i := range c.Size {
+		if i > 0 && at(i) == at(i-1) {
+			run++
+		} else {
+			run = 1
+		}
+		if run == 5 {
+			result += penaltyRun
+		} else if run > 5 {
+			result++
+		}
+
+		forward, backward := true, true
+		for j, want := range finderLike {
+			forward = forward && at(i+j) == want
+			backward = backward && at(i+j) == finderLike[len(finderLike)-1-j]
+		}
+		if forward {
+			result += penaltyFinderLike
+		}
+		if backward {
+			result += penaltyFinderLike
+		}
+	}
+	return result
+}
+
+// bitBuffer collects bits most significant first
+type bitBuffer struct {
+	data []byte
+	len  int
+}
+
+func (bb *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if bb.len%8 == 0 {
+			bb.data = append(bb.data, 0)
+		}
+		if bit(value, i) {
+			bb.data[bb.len/8] |= 0x80 >> (bb.len % 8)
+		}
+		bb.len++
+	}
+}
+
+func (bb *bitBuffer) bytes() []byte {
+	return bb.data
+}
+
+func bit(value, i int) bool {
+	return value>>i&1 != 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,267 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// "HELLO WORLD" at 1-M, the worked example in most QR code tutorials
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	tests := []struct {
+		level Level
+		mask  int
+		want  int
+	}{
+		{LevelL, 0, 0b111011111000100},
+		{LevelM, 0, 0b101010000010010},
+		{LevelQ, 0, 0b011010101011111},
+		{LevelH, 0, 0b001011010001001},
+		{LevelL, 7, 0b110100101110110},
+		{LevelM, 5, 0b100000011001110},
+	}
+	for _, tt := range tests {
+		if got := formatBits(tt.level, tt.mask); got != tt.want {
+			t.Errorf("formatBits(%s, %d) = %015b, want %015b", tt.level, tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	tests := map[int]int{7: 0x07C94, 8: 0x085BC, 21: 0x15683, 40: 0x28C69}
+	for version, want := range tests {
+		if got := versionBits(version); got != want {
+			t.Errorf("versionBits(%d) = %#05x, want %#05x", version, got, want)
+		}
+	}
+}
+
+func TestAlignmentPatternPositions(t *testing.T) {
+	tests := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		15: {6, 26, 48, 70},
+		32: {6, 34, 60, 86, 112, 138},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	}
+	for version, want := range tests {
+		if got := alignmentPatternPositions(version); !slices.Equal(got, want) {
+			t.Errorf("alignmentPatternPositions(%d) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestByteCapacity(t *testing.T) {
+	// Byte mode capacities from the standard's table
+	tests := []struct {
+		version int
+		level   Level
+		want    int
+	}{
+		{1, LevelL, 17}, {1, LevelM, 14}, {1, LevelQ, 11}, {1, LevelH, 7},
+		{2, LevelL, 32}, {2, LevelH, 14},
+		{10, LevelL, 271}, {10, LevelM, 213}, {10, LevelQ, 151}, {10, LevelH, 119},
+		{40, LevelL, 2953}, {40, LevelM, 2331}, {40, LevelQ, 1663}, {40, LevelH, 1273},
+	}
+	for _, tt := range tests {
+		got := (numDataCodewords(tt.version, tt.level)*8 - 4 - charCountBits(tt.version)) / 8
+		if got != tt.want {
+			t.Errorf("capacity of %d-%s = %d bytes, want %d", tt.version, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		length  int
+		level   Level
+		version int
+	}{
+		{17, LevelL, 1},
+		{18, LevelL, 2},
+		{7, LevelH, 1},
+		{8, LevelH, 2},
+		{2953, LevelL, 40},
+	}
+	for _, tt := range tests {
+		c, err := Encode(strings.Repeat("a", tt.length), tt.level)
+		if err != nil {
+			t.Fatalf("Encode(%d bytes, %s) error = %v", tt.length, tt.level, err)
+		}
+		if c.Version != tt.version || c.Size != tt.version*4+17 {
+			t.Errorf("Encode(%d bytes, %s) = version %d, size %d; want version %d", tt.length, tt.level, c.Version, c.Size, tt.version)
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("a", 2954), LevelL); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Encode(2954 bytes, L) error = %v, want %v", err, ErrTooLong)
+	}
+}
+
+// TestEncodeRoundTrip reads every symbol back the way a scanner would: format
+// information, unmasking, codeword order, error correction and the byte segment.
+func TestEncodeRoundTrip(t *testing.T) {
+	texts := []string{
+		"",
+		"https://u235.link/abc1234",
+		"https://go.example.com/Spring-Sale?utm_source=print&utm_medium=qr",
+		strings.Repeat("https://example.com/", 20),
+		strings.Repeat("0123456789abcdef", 60),
+	}
+	for _, text := range texts {
+		for level := LevelL; level <= LevelH; level++ {
+			c, err := Encode(text, level)
+			if err != nil {
+				t.Fatalf("Encode(%d bytes, %s) error = %v", len(text), level, err)
+			}
+			if got := readSymbol(t, c); got != text {
+				t.Errorf("Encode(%q, %s) reads back as %q", text, level, got)
+			}
+		}
+	}
+}
+
+// readSymbol decodes c, failing the test on any inconsistency
+func readSymbol(t *testing.T, c *Code) string {
+	t.Helper()
+
+	// Format information next to the top left finder
+	format := 0
+	for i := range 6 {
+		format |= boolBit(c.Dark(8, i)) << i
+	}
+	format |= boolBit(c.Dark(8, 7))<<6 | boolBit(c.Dark(8, 8))<<7 | boolBit(c.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		format |= boolBit(c.Dark(14-i, 8)) << i
+	}
+	if want := formatBits(c.Level, c.Mask); format != want {
+		t.Fatalf("version %d-%s: format bits %015b, want %015b", c.Version, c.Level, format, want)
+	}
+
+	unmasked := &Code{Size: c.Size, modules: slices.Clone(c.modules), isFunction: c.isFunction}
+	unmasked.applyMask(c.Mask)
+
+	// Codewords in placement order, the reverse of drawCodewords
+	var codewords []byte
+	bits := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range c.Size {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.Size - 1 - vert
+			}
+			for j := range 2 {
+				x := right - j
+				if c.isFunction[y*c.Size+x] {
+					continue
+				}
+				if bits%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+				codewords[bits/8] |= byte(boolBit(unmasked.Dark(x, y)) << (7 - bits%8))
+				bits++
+			}
+		}
+	}
+	rawCodewords := numRawDataModules(c.Version) / 8
+	codewords = codewords[:rawCodewords] // drop the remainder bits
+
+	// De-interleave, and check each block's syndromes are zero
+	numBlocks := numErrorCorrectionBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortDataLen := rawCodewords/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortDataLen; i++ {
+		for b := range blocks {
+			if i < shortDataLen || b >= numShortBlocks {
+				blocks[b] = append(blocks[b], codewords[k])
+				k++
+			}
+		}
+	}
+	for range eccLen {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[k])
+			k++
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		for i := range eccLen {
+			root := byte(1)
+			for range i {
+				root = gfMultiply(root, 0x02)
+			}
+			syndrome := byte(0)
+			for _, cw := range block {
+				syndrome = gfMultiply(syndrome, root) ^ cw
+			}
+			if syndrome != 0 {
+				t.Fatalf("version %d-%s: block %d syndrome %d is %d", c.Version, c.Level, b, i, syndrome)
+			}
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	// The byte mode segment
+	r := bitReader{data: data}
+	if mode := r.read(4); mode != 0b0100 {
+		t.Fatalf("version %d-%s: mode %04b, want byte mode", c.Version, c.Level, mode)
+	}
+	n := r.read(charCountBits(c.Version))
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = byte(r.read(8))
+	}
+	return string(text)
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for range n {
+		v = v<<1 | int(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
+
+func boolBit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, s := range []string{"l", "M", "q", "H"} {
+		level, err := ParseLevel(s)
+		if err != nil || level.String() != strings.ToUpper(s) {
+			t.Errorf("ParseLevel(%q) = %s, %v", s, level, err)
+		}
+	}
+	if _, err := ParseLevel("X"); err == nil {
+		t.Error("ParseLevel(\"X\") error = nil, want an error")
+	}
+}
@@ -0,0 +1,127 @@
+package qr
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultMargin is the quiet zone readers expect around a symbol, in modules
+const DefaultMargin = 4
+
+// Options control how a Code is drawn
+type Options struct {
+	// Size is the width and height of the image in pixels. PNG modules are whole
+	// pixels, so what does not divide evenly widens the quiet zone; sizes too
+	// small for one pixel per module grow to fit.
+	Size int
+	// Margin is the quiet zone around the symbol, in modules
+	Margin int
+	// Foreground and Background default to black and white
+	Foreground color.Color
+	Background color.Color
+}
+
+func (o Options) colors() (fg, bg color.Color) {
+	fg, bg = o.Foreground, o.Background
+	if fg == nil {
+		fg = color.Black
+	}
+	if bg == nil {
+		bg = color.White
+	}
+	return fg, bg
+}
+
+// PNG writes the code as a two colour PNG image
+func (c *Code) PNG(w io.Writer, opts Options) error {
+	margin := max(opts.Margin, 0)
+	modules := c.Size + 2*margin
+	scale := max(opts.Size/modules, 1)
+	size := max(opts.Size, scale*modules)
+	offset := (size - scale*c.Size) / 2
+
+	fg, bg := opts.colors()
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{bg, fg})
+	for y := range c.Size {
+		for x := range c.Size {
+			if !c.Dark(x, y) {
+				continue
+			}
+			for py := range scale {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := range scale {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	return encoder.Encode(w, img)
+}
+
+// SVG writes the code as an SVG document scaled to opts.Size. Each row of dark
+// modules becomes one rectangle per run, in a single path.
+func (c *Code) SVG(w io.Writer, opts Options) error {
+	margin := max(opts.Margin, 0)
+	modules := c.Size + 2*margin
+	size := opts.Size
+	if size <= 0 {
+		size = modules
+	}
+
+	fg, bg := opts.colors()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, modules, modules)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d"%s/>`+"\n", modules, modules, svgFill(bg))
+	fmt.Fprintf(bw, `<path%s d="`, svgFill(fg))
+	for y := range c.Size {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			run := 1
+			for c.Dark(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(bw, "M%d %dh%dv1h-%dz", x+margin, y+margin, run, run)
+			x += run
+		}
+	}
+	bw.WriteString(`"/>` + "\n</svg>\n")
+	return bw.Flush()
+}
+
+// svgFill returns the fill attributes of col, with an opacity when it is translucent
+func svgFill(col color.Color) string {
+	rgba := color.NRGBAModel.Convert(col).(color.NRGBA)
+	fill := fmt.Sprintf(` fill="#%02x%02x%02x"`, rgba.R, rgba.G, rgba.B)
+	if rgba.A < 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3g"`, float64(rgba.A)/0xff)
+	}
+	return fill
+}
+
+// ParseColor reads a hex colour such as "1a2b3c", or "1a2b3c80" with alpha. A
+// leading '#' is optional.
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
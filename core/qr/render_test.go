@@ -0,0 +1,111 @@
+package qr
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestPNG(t *testing.T) {
+	c, err := Encode("https://u235.link/abc1234", LevelM)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	fg := color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		wantSize int
+		scale    int
+	}{
+		{"exact fit", Options{Size: (c.Size + 8) * 4, Margin: 4}, (c.Size + 8) * 4, 4},
+		{"leftover widens margin", Options{Size: 256, Margin: 4}, 256, 256 / (c.Size + 8)},
+		{"too small grows", Options{Size: 10, Margin: 2}, c.Size + 4, 1},
+		{"no margin", Options{Size: c.Size * 3}, c.Size * 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Foreground = fg
+			var buf bytes.Buffer
+			if err := c.PNG(&buf, tt.opts); err != nil {
+				t.Fatalf("PNG() error = %v", err)
+			}
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatalf("png.Decode() error = %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tt.wantSize || b.Dy() != tt.wantSize {
+				t.Fatalf("image is %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantSize, tt.wantSize)
+			}
+
+			offset := (tt.wantSize - tt.scale*c.Size) / 2
+			for _, m := range [][2]int{{0, 0}, {1, 1}, {7, 0}, {c.Size - 1, c.Size - 1}, {8, c.Size - 8}} {
+				for _, d := range [][2]int{{0, 0}, {tt.scale - 1, tt.scale - 1}} {
+					got := color.NRGBAModel.Convert(img.At(offset+m[0]*tt.scale+d[0], offset+m[1]*tt.scale+d[1]))
+					want := color.Color(color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+					if c.Dark(m[0], m[1]) {
+						want = fg
+					}
+					if got != want {
+						t.Errorf("module %v pixel %v = %v, want %v", m, d, got, want)
+					}
+				}
+			}
+			if got := color.NRGBAModel.Convert(img.At(0, 0)); tt.opts.Margin > 0 && got != (color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+				t.Errorf("quiet zone pixel = %v, want white", got)
+			}
+		})
+	}
+}
+
+func TestSVG(t *testing.T) {
+	c, err := Encode("abc", LevelL)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var buf bytes.Buffer
+	err = c.SVG(&buf, Options{
+		Size:       300,
+		Margin:     2,
+		Foreground: color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff},
+		Background: color.NRGBA{A: 0},
+	})
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	svg := buf.String()
+
+	for _, want := range []string{
+		`width="300" height="300" viewBox="0 0 25 25"`,
+		`<rect width="25" height="25" fill="#000000" fill-opacity="0"/>`,
+		`<path fill="#112233" d="M2 2h7v1h-7z`, // top row of the top left finder
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG() does not contain %q:\n%s", want, svg)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{"000000", color.NRGBA{A: 0xff}, false},
+		{"#1a2B3c", color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, false},
+		{"ffffff80", color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x80}, false},
+		{"fff", color.NRGBA{}, true},
+		{"gggggg", color.NRGBA{}, true},
+		{"", color.NRGBA{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v; want %v, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
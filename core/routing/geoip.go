@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CountryLookup maps a client IP to an ISO 3166-1 alpha-2 country code, or ""
+type CountryLookup interface {
+	Country(ip netip.Addr) string
+}
+
+type countryRange struct {
+	first, last netip.Addr
+	country     string
+}
+
+// CountryDB is an in-memory GeoIP country database, safe for concurrent use
+type CountryDB struct {
+	ranges []countryRange // sorted by first, non-overlapping
+}
+
+// LoadCountryDB reads a GeoIP country CSV file, see ParseCountryDB
+func LoadCountryDB(path string) (*CountryDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	defer f.Close()
+	return ParseCountryDB(f)
+}
+
+// ParseCountryDB reads CSV rows that are either "network,country" with a CIDR
+// network, or "first_ip,last_ip,country" as in the free DB-IP country lite files.
+// IPv4 and IPv6 can be mixed. Blank lines, rows starting with '#' and a header row
+// are skipped; rows with an empty country are ignored.
+func ParseCountryDB(r io.Reader) (*CountryDB, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	db := &CountryDB{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed GeoIP database: %w", err)
+		}
+
+		rng, err := parseCountryRow(record)
+		if err != nil {
+			if line == 1 {
+				continue // header row
+			}
+			return nil, fmt.Errorf("GeoIP database line %d: %w", line, err)
+		}
+		if rng.country != "" {
+			db.ranges = append(db.ranges, rng)
+		}
+	}
+
+	slices.SortFunc(db.ranges, func(a, b countryRange) int { return a.first.Compare(b.first) })
+	for i := 1; i < len(db.ranges); i++ {
+		if db.ranges[i].first.Compare(db.ranges[i-1].last) <= 0 {
+			return nil, fmt.Errorf("GeoIP database has overlapping ranges at %s", db.ranges[i].first)
+		}
+	}
+	return db, nil
+}
+
+func parseCountryRow(record []string) (countryRange, error) {
+	var rng countryRange
+	switch len(record) {
+	case 2:
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+		if err != nil {
+			return rng, err
+		}
+		prefix = prefix.Masked()
+		rng.first, rng.last = prefix.Addr(), lastAddr(prefix)
+	case 3:
+		var err error
+		if rng.first, err = netip.ParseAddr(strings.TrimSpace(record[0])); err != nil {
+			return rng, err
+		}
+		if rng.last, err = netip.ParseAddr(strings.TrimSpace(record[1])); err != nil {
+			return rng, err
+		}
+		if rng.first.Is4() != rng.last.Is4() || rng.last.Less(rng.first) {
+			return rng, fmt.Errorf("invalid range %s-%s", rng.first, rng.last)
+		}
+	default:
+		return rng, fmt.Errorf("expected 2 or 3 columns, got %d", len(record))
+	}
+
+	rng.country = strings.ToUpper(strings.TrimSpace(record[len(record)-1]))
+	if rng.country != "" && len(rng.country) != 2 {
+		return rng, fmt.Errorf("invalid country code %q", rng.country)
+	}
+	return rng, nil
+}
+
+// lastAddr returns the highest address of a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 1 << (7 - bit%8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// Len returns the number of ranges in the database
+func (db *CountryDB) Len() int {
+	return len(db.ranges)
+}
+
+func (db *CountryDB) Country(ip netip.Addr) string {
+	if db == nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	// Index of the first range starting after ip; the one before may contain it
+	i, _ := slices.BinarySearchFunc(db.ranges, ip, func(r countryRange, ip netip.Addr) int {
+		if r.first.Compare(ip) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	if i == 0 {
+		return ""
+	}
+	if rng := db.ranges[i-1]; rng.last.Compare(ip) >= 0 && rng.first.Is4() == ip.Is4() {
+		return rng.country
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package routing
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Param is a query parameter a link adds to its destination. Value may hold
+// {name} placeholders that MergeQuery fills in.
+type Param struct {
+	Key   string `json:"k"`
+	Value string `json:"v"`
+}
+
+// MergeQuery adds the link's query parameters and the visitor's forwarded query
+// string to destination. When a key is set more than once, the first source wins:
+//
+//  1. parameters already in destination are never changed,
+//  2. then keys of the forwarded query, kept as received including repeats,
+//  3. then the link's params, with {name} placeholders replaced from vars.
+//
+// The existing query and fragment of destination are kept byte for byte, so
+// destinations that are already encoded are not encoded again. forwarded is a
+// raw query string without the leading '?', or "" to forward nothing.
+func MergeQuery(destination string, params []Param, forwarded string, vars map[string]string) string {
+	if len(params) == 0 && forwarded == "" {
+		return destination
+	}
+
+	base, fragment, hasFragment := strings.Cut(destination, "#")
+	path, query, _ := strings.Cut(base, "?")
+
+	seen := queryKeys(query)
+	var added []string
+
+	forwardedKeys := make(map[string]bool)
+	for _, part := range strings.Split(forwarded, "&") {
+		key, ok := queryKey(part)
+		if !ok || seen[key] {
+			continue
+		}
+		forwardedKeys[key] = true
+		added = append(added, part)
+	}
+	for key := range forwardedKeys {
+		seen[key] = true
+	}
+
+	for _, p := range params {
+		if p.Key == "" || seen[p.Key] {
+			continue
+		}
+		seen[p.Key] = true
+		added = append(added, url.QueryEscape(p.Key)+"="+url.QueryEscape(expandPlaceholders(p.Value, vars)))
+	}
+
+	if len(added) == 0 {
+		return destination
+	}
+	if query != "" {
+		query += "&"
+	}
+	merged := path + "?" + query + strings.Join(added, "&")
+	if hasFragment {
+		merged += "#" + fragment
+	}
+	return merged
+}
+
+// queryKeys returns the decoded keys of a raw query string
+func queryKeys(query string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, part := range strings.Split(query, "&") {
+		if key, ok := queryKey(part); ok {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// queryKey decodes the key of one "key=value" pair. Keys that are not valid
+// escapes are compared as written rather than rejected.
+func queryKey(part string) (string, bool) {
+	raw, _, _ := strings.Cut(part, "=")
+	if raw == "" {
+		return "", false
+	}
+	if key, err := url.QueryUnescape(raw); err == nil {
+		return key, true
+	}
+	return raw, true
+}
+
+// expandPlaceholders replaces every {name} in s that vars defines; other braces are left as they are
+func expandPlaceholders(s string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(s, "{") {
+		return s
+	}
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
@@ -0,0 +1,47 @@
+package routing
+
+import "testing"
+
+func TestMergeQuery(t *testing.T) {
+	utm := []Param{
+		{Key: "utm_source", Value: "newsletter"},
+		{Key: "utm_campaign", Value: "spring sale"},
+	}
+	vars := map[string]string{"short_id": "abc1234", "variant": "b"}
+
+	tests := []struct {
+		name        string
+		destination string
+		params      []Param
+		forwarded   string
+		want        string
+	}{
+		{"nothing to merge", "https://example.com/p?a=1", nil, "", "https://example.com/p?a=1"},
+		{"adds params", "https://example.com/p", utm, "", "https://example.com/p?utm_source=newsletter&utm_campaign=spring+sale"},
+		{"appends to existing query", "https://example.com/p?a=1", utm[:1], "", "https://example.com/p?a=1&utm_source=newsletter"},
+		{"empty query", "https://example.com/p?", utm[:1], "", "https://example.com/p?utm_source=newsletter"},
+		{"keeps fragment last", "https://example.com/p?a=1#top", utm[:1], "", "https://example.com/p?a=1&utm_source=newsletter#top"},
+		{"fragment without query", "https://example.com/p#top?x", utm[:1], "", "https://example.com/p?utm_source=newsletter#top?x"},
+		{"destination wins", "https://example.com/p?utm_source=site", utm, "", "https://example.com/p?utm_source=site&utm_campaign=spring+sale"},
+		{"destination wins over encoded key", "https://example.com/p?utm%5Fsource=site", utm[:1], "", "https://example.com/p?utm%5Fsource=site"},
+		{"keeps encoded destination", "https://example.com/a%2Fb?q=%E2%9C%93&r=a%26b", utm[:1], "", "https://example.com/a%2Fb?q=%E2%9C%93&r=a%26b&utm_source=newsletter"},
+		{"forwards query", "https://example.com/p", nil, "gclid=x1&ref=tw", "https://example.com/p?gclid=x1&ref=tw"},
+		{"forwarded wins over params", "https://example.com/p", utm, "utm_source=twitter", "https://example.com/p?utm_source=twitter&utm_campaign=spring+sale"},
+		{"destination wins over forwarded", "https://example.com/p?ref=site", nil, "ref=tw&x=1", "https://example.com/p?ref=site&x=1"},
+		{"forwarded repeats are kept", "https://example.com/p", nil, "tag=a&tag=b", "https://example.com/p?tag=a&tag=b"},
+		{"forwarded is not re-encoded", "https://example.com/p", nil, "q=a%20b&s=%2B", "https://example.com/p?q=a%20b&s=%2B"},
+		{"empty forwarded pairs are dropped", "https://example.com/p", nil, "&a=1&&=x", "https://example.com/p?a=1"},
+		{"escapes params", "https://example.com/p", []Param{{Key: "a b", Value: "x&y=z"}}, "", "https://example.com/p?a+b=x%26y%3Dz"},
+		{"expands placeholders", "https://example.com/p", []Param{{Key: "utm_content", Value: "{short_id}-{variant}"}}, "", "https://example.com/p?utm_content=abc1234-b"},
+		{"unknown placeholder is kept", "https://example.com/p", []Param{{Key: "x", Value: "{nope}"}}, "", "https://example.com/p?x=%7Bnope%7D"},
+		{"first param wins", "https://example.com/p", []Param{{Key: "a", Value: "1"}, {Key: "a", Value: "2"}}, "", "https://example.com/p?a=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeQuery(tt.destination, tt.params, tt.forwarded, vars); got != tt.want {
+				t.Errorf("MergeQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
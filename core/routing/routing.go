@@ -0,0 +1,101 @@
+// Package routing picks the destination of a short link for one visitor, from an
+// ordered list of conditional rules or a weighted A/B split. It has no I/O apart
+// from loading the GeoIP database, so the evaluator can be used and tested on its own.
+package routing
+
+import (
+	"slices"
+	"strings"
+)
+
+// Device classes a rule can match on. A visitor has one OS and is either
+// mobile or desktop, so "ios" and "mobile" can both match the same visitor.
+const (
+	DeviceIOS     = "ios"
+	DeviceAndroid = "android"
+	DeviceWindows = "windows"
+	DeviceMacOS   = "macos"
+	DeviceLinux   = "linux"
+	DeviceMobile  = "mobile"
+	DeviceDesktop = "desktop"
+)
+
+// Devices lists every value accepted in Rule.Devices
+var Devices = []string{DeviceIOS, DeviceAndroid, DeviceWindows, DeviceMacOS, DeviceLinux, DeviceMobile, DeviceDesktop}
+
+// Rule sends visitors that match every non-empty condition to URL. Within one
+// condition any listed value matches. Its JSON form is what links carry in Redis,
+// so the keys are kept short.
+type Rule struct {
+	Devices   []string `json:"d,omitempty"`
+	Countries []string `json:"c,omitempty"` // ISO 3166-1 alpha-2, upper case
+	Languages []string `json:"l,omitempty"` // BCP 47 tags, lower case
+	URL       string   `json:"u"`
+}
+
+// Visitor is what rules are matched against, derived from one request
+type Visitor struct {
+	OS       string // One of the OS device classes, or "" when unknown
+	Mobile   bool
+	Country  string // ISO 3166-1 alpha-2, or "" when unknown
+	Language string // Most preferred language tag, lower case, or "" when none
+}
+
+// Evaluate returns the URL of the first rule the visitor matches
+func Evaluate(rules []Rule, v Visitor) (string, bool) {
+	for _, rule := range rules {
+		if rule.Matches(v) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+// Matches reports whether v satisfies every condition of the rule
+func (r Rule) Matches(v Visitor) bool {
+	if len(r.Devices) > 0 && !slices.ContainsFunc(r.Devices, func(d string) bool { return matchDevice(d, v) }) {
+		return false
+	}
+	if len(r.Countries) > 0 && !slices.Contains(r.Countries, v.Country) {
+		return false
+	}
+	if len(r.Languages) > 0 && !slices.ContainsFunc(r.Languages, func(l string) bool { return matchLanguage(l, v.Language) }) {
+		return false
+	}
+	return true
+}
+
+// Normalize returns a copy of the rule with values in the case Matches expects
+func (r Rule) Normalize() Rule {
+	out := Rule{URL: r.URL}
+	for _, d := range r.Devices {
+		out.Devices = append(out.Devices, strings.ToLower(strings.TrimSpace(d)))
+	}
+	for _, c := range r.Countries {
+		out.Countries = append(out.Countries, strings.ToUpper(strings.TrimSpace(c)))
+	}
+	for _, l := range r.Languages {
+		out.Languages = append(out.Languages, strings.ToLower(strings.TrimSpace(l)))
+	}
+	return out
+}
+
+func matchDevice(device string, v Visitor) bool {
+	switch device {
+	case DeviceMobile:
+		return v.Mobile
+	case DeviceDesktop:
+		return !v.Mobile && v.OS != DeviceIOS && v.OS != DeviceAndroid
+	default:
+		return device == v.OS
+	}
+}
+
+// matchLanguage lets a bare language such as "en" match any of its regional
+// variants ("en-us"), while a regional tag only matches itself.
+func matchLanguage(want, have string) bool {
+	if have == "" {
+		return false
+	}
+	return want == have || strings.HasPrefix(have, want+"-")
+}
@@ -0,0 +1,277 @@
+package routing
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	macUA     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+	linuxUA   = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+)
+
+func TestEvaluate(t *testing.T) {
+	appRules := []Rule{
+		{Devices: []string{DeviceIOS}, URL: "https://apps.apple.com/app"},
+		{Devices: []string{DeviceAndroid}, URL: "https://play.google.com/app"},
+	}
+	localeRules := []Rule{
+		{Countries: []string{"DE", "AT"}, Languages: []string{"de"}, URL: "https://example.com/de"},
+		{Languages: []string{"pt-br"}, URL: "https://example.com/br"},
+		{Languages: []string{"pt"}, URL: "https://example.com/pt"},
+		{Devices: []string{DeviceMobile}, URL: "https://m.example.com"},
+	}
+
+	tests := []struct {
+		name    string
+		rules   []Rule
+		visitor Visitor
+		want    string
+		matched bool
+	}{
+		{"no rules", nil, Visitor{OS: DeviceIOS, Mobile: true}, "", false},
+		{"ios to app store", appRules, Visitor{OS: DeviceIOS, Mobile: true}, "https://apps.apple.com/app", true},
+		{"android to play", appRules, Visitor{OS: DeviceAndroid, Mobile: true}, "https://play.google.com/app", true},
+		{"desktop falls through", appRules, Visitor{OS: DeviceWindows}, "", false},
+		{"unknown device falls through", appRules, Visitor{}, "", false},
+		{"all conditions must match", localeRules, Visitor{Country: "DE", Language: "en-us"}, "", false},
+		{"country and language match", localeRules, Visitor{Country: "AT", Language: "de-at"}, "https://example.com/de", true},
+		{"regional tag before bare tag", localeRules, Visitor{Language: "pt-br"}, "https://example.com/br", true},
+		{"bare tag matches other regions", localeRules, Visitor{Language: "pt-pt"}, "https://example.com/pt", true},
+		{"bare tag matches itself", localeRules, Visitor{Language: "pt"}, "https://example.com/pt", true},
+		{"regional tag does not match bare visitor", localeRules[1:2], Visitor{Language: "pt"}, "", false},
+		{"tag prefix is not a language match", localeRules[2:3], Visitor{Language: "ptx"}, "", false},
+		{"mobile class", localeRules, Visitor{OS: DeviceAndroid, Mobile: true, Language: "en"}, "https://m.example.com", true},
+		{"first match wins", append(appRules, Rule{URL: "https://example.com/any"}), Visitor{OS: DeviceIOS, Mobile: true}, "https://apps.apple.com/app", true},
+		{"rule without conditions matches all", []Rule{{URL: "https://example.com/any"}}, Visitor{}, "https://example.com/any", true},
+		{"unknown country never matches", []Rule{{Countries: []string{"US"}, URL: "https://example.com/us"}}, Visitor{}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := Evaluate(tt.rules, tt.visitor)
+			if got != tt.want || matched != tt.matched {
+				t.Errorf("Evaluate() = (%q, %v), want (%q, %v)", got, matched, tt.want, tt.matched)
+			}
+		})
+	}
+}
+
+func TestDeviceClasses(t *testing.T) {
+	tests := []struct {
+		name    string
+		device  string
+		visitor Visitor
+		want    bool
+	}{
+		{"desktop matches windows", DeviceDesktop, Visitor{OS: DeviceWindows}, true},
+		{"desktop matches unknown", DeviceDesktop, Visitor{}, true},
+		{"desktop excludes mobile", DeviceDesktop, Visitor{OS: DeviceLinux, Mobile: true}, false},
+		{"desktop excludes ios", DeviceDesktop, Visitor{OS: DeviceIOS}, false},
+		{"mobile matches any mobile", DeviceMobile, Visitor{Mobile: true}, true},
+		{"os must be equal", DeviceMacOS, Visitor{OS: DeviceIOS, Mobile: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := Rule{Devices: []string{tt.device}}
+			if got := rule.Matches(tt.visitor); got != tt.want {
+				t.Errorf("Matches(%+v) = %v, want %v", tt.visitor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	rule := Rule{Devices: []string{" iOS"}, Countries: []string{"de"}, Languages: []string{"PT-BR"}, URL: "https://example.com"}.Normalize()
+	if !rule.Matches(Visitor{OS: DeviceIOS, Country: "DE", Language: "pt-br"}) {
+		t.Errorf("normalized rule %+v does not match", rule)
+	}
+}
+
+func TestDetectDevice(t *testing.T) {
+	tests := []struct {
+		name       string
+		userAgent  string
+		wantOS     string
+		wantMobile bool
+	}{
+		{"iphone", iPhoneUA, DeviceIOS, true},
+		{"android", androidUA, DeviceAndroid, true},
+		{"windows", windowsUA, DeviceWindows, false},
+		{"mac", macUA, DeviceMacOS, false},
+		{"linux", linuxUA, DeviceLinux, false},
+		{"empty", "", "", false},
+		{"bot", "curl/8.5.0", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os, mobile := DetectDevice(tt.userAgent)
+			if os != tt.wantOS || mobile != tt.wantMobile {
+				t.Errorf("DetectDevice() = (%q, %v), want (%q, %v)", os, mobile, tt.wantOS, tt.wantMobile)
+			}
+		})
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"en-US,en;q=0.9", "en-us"},
+		{"fr;q=0.5, de-CH, de;q=0.8", "de-ch"},
+		{"*;q=1, es;q=0.2", "es"},
+		{"it;q=0, nl;q=0.1", "nl"},
+		{"da, en-gb;q=0.8", "da"},
+		{"ja;q=abc", "ja"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := PreferredLanguage(tt.header); got != tt.want {
+				t.Errorf("PreferredLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountryDB(t *testing.T) {
+	db, err := ParseCountryDB(strings.NewReader(`network,country
+# comment
+1.0.0.0/24,AU
+2.16.0.0,2.16.255.255,de
+8.8.8.0/24,US
+2001:db8::/32,NL
+10.0.0.0/8,
+`))
+	if err != nil {
+		t.Fatalf("ParseCountryDB() error = %v", err)
+	}
+	if db.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", db.Len())
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.0.0.0", "AU"},
+		{"1.0.0.255", "AU"},
+		{"1.0.1.0", ""},
+		{"2.16.42.1", "DE"},
+		{"8.8.8.8", "US"},
+		{"::ffff:8.8.8.8", "US"},
+		{"9.9.9.9", ""},
+		{"0.0.0.1", ""},
+		{"10.1.2.3", ""},
+		{"2001:db8:ffff::1", "NL"},
+		{"2001:db9::1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := db.Country(netip.MustParseAddr(tt.ip)); got != tt.want {
+				t.Errorf("Country(%s) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	var missing *CountryDB
+	if got := missing.Country(netip.MustParseAddr("8.8.8.8")); got != "" {
+		t.Errorf("nil CountryDB returned %q", got)
+	}
+}
+
+func TestParseCountryDBErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{"bad network", "1.0.0.0/24,AU\nnot-an-ip/8,US\n"},
+		{"reversed range", "1.0.0.0/24,AU\n9.0.0.9,9.0.0.1,US\n"},
+		{"overlap", "1.0.0.0/16,AU\n1.0.5.0/24,NZ\n"},
+		{"bad country", "1.0.0.0/24,AU\n2.0.0.0/24,USA\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCountryDB(strings.NewReader(tt.csv)); err == nil {
+				t.Error("ParseCountryDB() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestNewVisitor(t *testing.T) {
+	db, err := ParseCountryDB(strings.NewReader("8.8.8.0/24,US\n"))
+	if err != nil {
+		t.Fatalf("ParseCountryDB() error = %v", err)
+	}
+
+	v := NewVisitor(iPhoneUA, "en-GB,en;q=0.8", "8.8.8.8", db)
+	want := Visitor{OS: DeviceIOS, Mobile: true, Country: "US", Language: "en-gb"}
+	if v != want {
+		t.Errorf("NewVisitor() = %+v, want %+v", v, want)
+	}
+
+	if v := NewVisitor(windowsUA, "", "not-an-ip", db); v.Country != "" {
+		t.Errorf("NewVisitor() with invalid IP has country %q", v.Country)
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []Variant{
+		{Name: "a", URL: "https://example.com/a", Weight: 70},
+		{Name: "off", URL: "https://example.com/off", Weight: 0},
+		{Name: "b", URL: "https://example.com/b", Weight: 30},
+	}
+	if got := TotalWeight(variants); got != 100 {
+		t.Fatalf("TotalWeight() = %d, want 100", got)
+	}
+
+	tests := []struct {
+		roll int
+		want int
+	}{
+		{-1, -1},
+		{0, 0},
+		{69, 0},
+		{70, 2},
+		{99, 2},
+		{100, -1},
+	}
+	for _, tt := range tests {
+		if got := PickVariant(variants, tt.roll); got != tt.want {
+			t.Errorf("PickVariant(%d) = %d, want %d", tt.roll, got, tt.want)
+		}
+	}
+
+	// Every roll lands on a weighted variant, in proportion to its weight
+	counts := make(map[int]int)
+	for roll := 0; roll < TotalWeight(variants); roll++ {
+		counts[PickVariant(variants, roll)]++
+	}
+	if counts[0] != 70 || counts[2] != 30 || len(counts) != 2 {
+		t.Errorf("PickVariant() distribution = %v, want 70/30", counts)
+	}
+
+	if got := PickVariant(nil, 0); got != -1 {
+		t.Errorf("PickVariant(nil) = %d, want -1", got)
+	}
+}
+
+func TestFindVariant(t *testing.T) {
+	variants := []Variant{{Name: "a"}, {Name: "b"}}
+	if got := FindVariant(variants, "b"); got != 1 {
+		t.Errorf("FindVariant(b) = %d, want 1", got)
+	}
+	if got := FindVariant(variants, "c"); got != -1 {
+		t.Errorf("FindVariant(c) = %d, want -1", got)
+	}
+}
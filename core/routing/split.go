@@ -0,0 +1,50 @@
+package routing
+
+// Variant is one destination of an A/B split. Visitors are spread across the
+// variants of a link in proportion to their weights, so weights of 70 and 30
+// send roughly 70% of visits to the first one.
+type Variant struct {
+	Name   string `json:"n"`
+	URL    string `json:"u"`
+	Weight int    `json:"w"`
+}
+
+// TotalWeight is the sum of all positive weights
+func TotalWeight(variants []Variant) int {
+	total := 0
+	for _, v := range variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	return total
+}
+
+// PickVariant returns the index of the variant that roll falls on, where roll is
+// drawn uniformly from [0, TotalWeight(variants)). Variants without a positive
+// weight are never picked. It returns -1 when roll is out of range.
+func PickVariant(variants []Variant, roll int) int {
+	if roll < 0 {
+		return -1
+	}
+	for i, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		if roll < v.Weight {
+			return i
+		}
+		roll -= v.Weight
+	}
+	return -1
+}
+
+// FindVariant returns the index of the variant called name, or -1
+func FindVariant(variants []Variant, name string) int {
+	for i, v := range variants {
+		if v.Name == name {
+			return i
+		}
+	}
+	return -1
+}
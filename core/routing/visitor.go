@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// NewVisitor derives a Visitor from request headers and the client IP. countries
+// may be nil, in which case rules on country never match.
+func NewVisitor(userAgent, acceptLanguage, clientIP string, countries CountryLookup) Visitor {
+	platform, mobile := DetectDevice(userAgent)
+	v := Visitor{
+		OS:       platform,
+		Mobile:   mobile,
+		Language: PreferredLanguage(acceptLanguage),
+	}
+	if countries != nil {
+		if ip, err := netip.ParseAddr(clientIP); err == nil {
+			v.Country = countries.Country(ip)
+		}
+	}
+	return v
+}
+
+// DetectDevice classifies a User-Agent by operating system and form factor.
+// iPadOS reports itself as macOS, so iPads that request desktop sites count as macOS.
+func DetectDevice(userAgent string) (platform string, mobile bool) {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return DeviceIOS, true
+	case strings.Contains(ua, "android"):
+		return DeviceAndroid, true
+	case strings.Contains(ua, "windows"):
+		return DeviceWindows, strings.Contains(ua, "mobile")
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		return DeviceMacOS, false
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return DeviceLinux, strings.Contains(ua, "mobile")
+	}
+	return "", strings.Contains(ua, "mobi")
+}
+
+// PreferredLanguage returns the highest weighted tag of an Accept-Language
+// header, lower cased. Wildcards and tags with q=0 are ignored.
+func PreferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		// Ties keep the earlier tag, as the header lists them in preference order
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
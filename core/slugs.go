@@ -0,0 +1,33 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reservedPaths are root-level paths that can never be used as short IDs
+var reservedPaths = []string{
+	"api", "health", "favicon.ico", "robots.txt", "sitemap.xml",
+	"admin", "dashboard", "login", "register", "static", "assets",
+	"js", "css", "img", "images", "fonts", "docs", "help", "about",
+	"contact", "privacy", "terms", "www", "ftp", "mail", "blog",
+}
+
+// IsReservedPath reports whether shortId collides with a known system path
+func IsReservedPath(shortId string) bool {
+	lowerShortId := strings.ToLower(shortId)
+	for _, reserved := range reservedPaths {
+		if lowerShortId == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+// shortIdPattern allows alphanumerics with inner hyphens
+var shortIdPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// IsValidShortIdFormat reports whether shortId can be served by the redirect routes
+func IsValidShortIdFormat(shortId string) bool {
+	return len(shortId) >= MinShortIDLength && len(shortId) <= MaxShortIDLength && shortIdPattern.MatchString(shortId)
+}
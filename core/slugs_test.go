@@ -0,0 +1,43 @@
+package core
+
+import "testing"
+
+func TestIsReservedPath(t *testing.T) {
+	tests := []struct {
+		shortId string
+		want    bool
+	}{
+		{"admin", true},
+		{"API", true},
+		{"robots.txt", true},
+		{"admins", false},
+		{"abc1234", false},
+	}
+	for _, tt := range tests {
+		if got := IsReservedPath(tt.shortId); got != tt.want {
+			t.Errorf("IsReservedPath(%q) = %t, want %t", tt.shortId, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidShortIdFormat(t *testing.T) {
+	tests := []struct {
+		shortId string
+		want    bool
+	}{
+		{"abc1", true},
+		{"my-link", true},
+		{"abcdefghijkl", true},
+		{"abc", false},
+		{"abcdefghijklm", false},
+		{"-abcd", false},
+		{"abcd-", false},
+		{"ab_cd", false},
+		{"ab cd", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidShortIdFormat(tt.shortId); got != tt.want {
+			t.Errorf("IsValidShortIdFormat(%q) = %t, want %t", tt.shortId, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultSqidsAlphabet matches the default alphabet of the Sqids libraries,
+// so IDs produced here decode with any of them (blocklist aside).
+const DefaultSqidsAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// SqidsEncoder is a reversible number <-> ID encoder following the Sqids algorithm.
+// Unlike the reference implementation it has no word blocklist.
+type SqidsEncoder struct {
+	alphabet []byte
+}
+
+// NewSqidsEncoder creates an encoder for the given alphabet. Reordering the
+// alphabet changes every generated ID, which makes it act as a salt.
+func NewSqidsEncoder(alphabet string) (*SqidsEncoder, error) {
+	if alphabet == "" {
+		alphabet = DefaultSqidsAlphabet
+	}
+	if len(alphabet) < 3 {
+		return nil, fmt.Errorf("sqids alphabet must contain at least 3 characters")
+	}
+
+	seen := make(map[byte]bool, len(alphabet))
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		// IDs must pass IsValidShortIdFormat, which a '-' at either end would fail
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return nil, fmt.Errorf("sqids alphabet may only contain letters and digits, got %q", c)
+		}
+		if seen[c] {
+			return nil, fmt.Errorf("sqids alphabet contains duplicate character %q", c)
+		}
+		seen[c] = true
+	}
+
+	return &SqidsEncoder{alphabet: sqidsShuffle([]byte(alphabet))}, nil
+}
+
+// Encode returns the ID for numbers, padded to at least minLength characters.
+func (s *SqidsEncoder) Encode(numbers []uint64, minLength int) string {
+	if len(numbers) == 0 {
+		return ""
+	}
+	return s.encode(numbers, minLength, 0)
+}
+
+func (s *SqidsEncoder) encode(numbers []uint64, minLength, increment int) string {
+	size := len(s.alphabet)
+
+	offset := len(numbers)
+	for i, n := range numbers {
+		offset += int(s.alphabet[n%uint64(size)]) + i
+	}
+	offset = (offset + increment) % size
+
+	alphabet := make([]byte, 0, size)
+	alphabet = append(alphabet, s.alphabet[offset:]...)
+	alphabet = append(alphabet, s.alphabet[:offset]...)
+	prefix := alphabet[0]
+	reverseBytes(alphabet)
+
+	var id strings.Builder
+	id.WriteByte(prefix)
+	for i, n := range numbers {
+		id.WriteString(sqidsToID(n, alphabet[1:]))
+		if i < len(numbers)-1 {
+			id.WriteByte(alphabet[0])
+			alphabet = sqidsShuffle(alphabet)
+		}
+	}
+
+	if minLength > id.Len() {
+		id.WriteByte(alphabet[0])
+		for minLength-id.Len() > 0 {
+			alphabet = sqidsShuffle(alphabet)
+			id.Write(alphabet[:min(minLength-id.Len(), size)])
+		}
+	}
+
+	return id.String()
+}
+
+// Decode returns the numbers encoded in id, or nil if id is not a valid ID.
+func (s *SqidsEncoder) Decode(id string) []uint64 {
+	if id == "" {
+		return nil
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(string(s.alphabet), id[i]) < 0 {
+			return nil
+		}
+	}
+
+	offset := strings.IndexByte(string(s.alphabet), id[0])
+	size := len(s.alphabet)
+
+	alphabet := make([]byte, 0, size)
+	alphabet = append(alphabet, s.alphabet[offset:]...)
+	alphabet = append(alphabet, s.alphabet[:offset]...)
+	reverseBytes(alphabet)
+
+	var numbers []uint64
+	rest := id[1:]
+	for rest != "" {
+		separator := alphabet[0]
+		chunk, tail, found := strings.Cut(rest, string(separator))
+		if chunk == "" {
+			// Everything after an empty chunk is min-length padding
+			return numbers
+		}
+		n, ok := sqidsToNumber(chunk, alphabet[1:])
+		if !ok {
+			return nil
+		}
+		numbers = append(numbers, n)
+		if !found {
+			break
+		}
+		alphabet = sqidsShuffle(alphabet)
+		rest = tail
+	}
+	return numbers
+}
+
+func sqidsShuffle(alphabet []byte) []byte {
+	chars := append([]byte(nil), alphabet...)
+	size := len(chars)
+	for i, j := 0, size-1; j > 0; i, j = i+1, j-1 {
+		r := (i*j + int(chars[i]) + int(chars[j])) % size
+		chars[i], chars[r] = chars[r], chars[i]
+	}
+	return chars
+}
+
+func sqidsToID(n uint64, alphabet []byte) string {
+	size := uint64(len(alphabet))
+	var id []byte
+	for {
+		id = append(id, alphabet[n%size])
+		n /= size
+		if n == 0 {
+			break
+		}
+	}
+	reverseBytes(id)
+	return string(id)
+}
+
+func sqidsToNumber(id string, alphabet []byte) (uint64, bool) {
+	size := uint64(len(alphabet))
+	var n uint64
+	for i := 0; i < len(id); i++ {
+		idx := strings.IndexByte(string(alphabet), id[i])
+		if idx < 0 {
+			return 0, false
+		}
+		n = n*size + uint64(idx)
+	}
+	return n, true
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -1,25 +1,164 @@
 package core
 
 import (
-	"crypto/md5"
-	"encoding/hex"
-	"strconv"
-	"time"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
 )
 
-func GenerateShortID(originalURL string) string {
-	// Add timestamp for extra entropy
-	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
-	data := originalURL + timestamp
+const (
+	// Base62Alphabet is the character set used by the random and sequence generators.
+	Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 
-	// Generate MD5 hash
-	hash := md5.Sum([]byte(data))
-	hashStr := hex.EncodeToString(hash[:])
+	DefaultShortIDLength = 7
+	MinShortIDLength     = 4
+	MaxShortIDLength     = 12 // Also bounds the custom short IDs accepted by IsValidShortIdFormat
+)
+
+// Supported values for the SHORT_ID_STRATEGY setting
+const (
+	StrategyRandom   = "random"
+	StrategySequence = "sequence"
+	StrategySqids    = "sqids"
+)
 
-	// Return substring of hash with specified length
-	if len(hashStr) > 5 {
-		return hashStr[:5]
+// ShortIDGenerator produces candidate short IDs. Callers are expected to retry
+// on unique violations, passing a larger length once a keyspace fills up.
+type ShortIDGenerator interface {
+	Generate(ctx context.Context, length int) (string, error)
+}
+
+// SequenceSource hands out unique, increasing numbers such as a Postgres sequence.
+type SequenceSource interface {
+	NextShortIDSequence(ctx context.Context) (int64, error)
+}
+
+// NewShortIDGenerator builds the generator selected by strategy.
+// An empty strategy falls back to random base62 IDs.
+func NewShortIDGenerator(strategy string, seq SequenceSource, alphabet string) (ShortIDGenerator, error) {
+	switch strings.ToLower(strategy) {
+	case "", StrategyRandom:
+		return NewRandomGenerator(), nil
+	case StrategySequence:
+		return NewSequenceGenerator(seq), nil
+	case StrategySqids:
+		encoder, err := NewSqidsEncoder(alphabet)
+		if err != nil {
+			return nil, err
+		}
+		return NewSqidsGenerator(seq, encoder), nil
+	default:
+		return nil, fmt.Errorf("unknown short ID strategy %q", strategy)
 	}
-	return hashStr
+}
+
+// RandomGenerator returns uniformly random base62 IDs from crypto/rand.
+type RandomGenerator struct{}
+
+func NewRandomGenerator() *RandomGenerator {
+	return &RandomGenerator{}
+}
+
+func (g *RandomGenerator) Generate(_ context.Context, length int) (string, error) {
+	if length <= 0 {
+		length = DefaultShortIDLength
+	}
+
+	// 248 is the largest multiple of 62 below 256; higher bytes are rejected
+	// so that every character is equally likely.
+	const limit = 248
+
+	id := make([]byte, 0, length)
+	buf := make([]byte, length*2)
+	for len(id) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		for _, b := range buf {
+			if b >= limit {
+				continue
+			}
+			id = append(id, Base62Alphabet[int(b)%len(Base62Alphabet)])
+			if len(id) == length {
+				break
+			}
+		}
+	}
+	return string(id), nil
+}
+
+// SequenceGenerator base62-encodes the next value of a sequence, left padded
+// to the requested length.
+type SequenceGenerator struct {
+	seq SequenceSource
+}
+
+func NewSequenceGenerator(seq SequenceSource) *SequenceGenerator {
+	return &SequenceGenerator{seq: seq}
+}
 
+func (g *SequenceGenerator) Generate(ctx context.Context, length int) (string, error) {
+	n, err := g.seq.NextShortIDSequence(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next sequence value: %w", err)
+	}
+
+	id := EncodeBase62(uint64(n))
+	if len(id) < length {
+		id = strings.Repeat(string(Base62Alphabet[0]), length-len(id)) + id
+	}
+	return id, nil
+}
+
+// SqidsGenerator turns sequence values into short, non-sequential looking IDs
+// that can be decoded back to the number.
+type SqidsGenerator struct {
+	seq     SequenceSource
+	encoder *SqidsEncoder
+}
+
+func NewSqidsGenerator(seq SequenceSource, encoder *SqidsEncoder) *SqidsGenerator {
+	return &SqidsGenerator{seq: seq, encoder: encoder}
+}
+
+func (g *SqidsGenerator) Generate(ctx context.Context, length int) (string, error) {
+	n, err := g.seq.NextShortIDSequence(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next sequence value: %w", err)
+	}
+	return g.encoder.Encode([]uint64{uint64(n)}, length), nil
+}
+
+// EncodeBase62 encodes n using Base62Alphabet.
+func EncodeBase62(n uint64) string {
+	if n == 0 {
+		return string(Base62Alphabet[0])
+	}
+
+	var buf [11]byte // 62^11 > 2^64
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = Base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
+
+// DecodeBase62 reverses EncodeBase62.
+func DecodeBase62(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty base62 string")
+	}
+
+	var n uint64
+	for _, c := range s {
+		idx := strings.IndexRune(Base62Alphabet, c)
+		if idx < 0 {
+			return 0, fmt.Errorf("invalid base62 character %q", c)
+		}
+		n = n*62 + uint64(idx)
+	}
+	return n, nil
 }
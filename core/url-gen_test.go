@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type counterSequence struct {
+	next int64
+	err  error
+}
+
+func (s *counterSequence) NextShortIDSequence(context.Context) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.next++
+	return s.next, nil
+}
+
+func TestRandomGenerator(t *testing.T) {
+	valid := regexp.MustCompile(`^[0-9A-Za-z]+$`)
+	gen := NewRandomGenerator()
+	seen := make(map[string]bool)
+
+	for _, length := range []int{MinShortIDLength, DefaultShortIDLength, MaxShortIDLength} {
+		for i := 0; i < 200; i++ {
+			id, err := gen.Generate(context.Background(), length)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if len(id) != length {
+				t.Fatalf("Generate(%d) returned %q with length %d", length, id, len(id))
+			}
+			if !valid.MatchString(id) {
+				t.Fatalf("Generate() returned non-base62 id %q", id)
+			}
+			seen[id] = true
+		}
+	}
+
+	if len(seen) < 590 {
+		t.Errorf("expected random ids to be mostly unique, got %d distinct of 600", len(seen))
+	}
+}
+
+func TestSequenceGenerator(t *testing.T) {
+	gen := NewSequenceGenerator(&counterSequence{next: 61})
+
+	first, err := gen.Generate(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if first != "0010" {
+		t.Errorf("expected sequence value 62 to encode as 0010, got %q", first)
+	}
+
+	second, _ := gen.Generate(context.Background(), 4)
+	if second == first {
+		t.Errorf("expected consecutive sequence ids to differ, both were %q", first)
+	}
+
+	failing := NewSequenceGenerator(&counterSequence{err: errors.New("db down")})
+	if _, err := failing.Generate(context.Background(), 4); err == nil {
+		t.Error("expected sequence errors to be returned")
+	}
+}
+
+func TestBase62RoundTrip(t *testing.T) {
+	for _, n := range []uint64{0, 1, 61, 62, 3843, 3844, 1 << 40, ^uint64(0)} {
+		decoded, err := DecodeBase62(EncodeBase62(n))
+		if err != nil {
+			t.Fatalf("DecodeBase62(EncodeBase62(%d)) error = %v", n, err)
+		}
+		if decoded != n {
+			t.Errorf("round trip of %d returned %d", n, decoded)
+		}
+	}
+}
+
+func TestSqidsEncoder(t *testing.T) {
+	encoder, err := NewSqidsEncoder("")
+	if err != nil {
+		t.Fatalf("NewSqidsEncoder() error = %v", err)
+	}
+
+	// Reference vectors from the Sqids specification
+	tests := []struct {
+		numbers   []uint64
+		minLength int
+		want      string
+	}{
+		{[]uint64{1, 2, 3}, 0, "86Rf07"},
+		{[]uint64{1, 2, 3}, len(DefaultSqidsAlphabet), "86Rf07xd4zBmiJXQG6otHEbew02c3PWsUOLZxADhCpKj7aVFv9I8RquYrNlSTM"},
+	}
+	for _, tt := range tests {
+		got := encoder.Encode(tt.numbers, tt.minLength)
+		if got != tt.want {
+			t.Errorf("Encode(%v, %d) = %q, want %q", tt.numbers, tt.minLength, got, tt.want)
+		}
+		if decoded := encoder.Decode(got); !reflect.DeepEqual(decoded, tt.numbers) {
+			t.Errorf("Decode(%q) = %v, want %v", got, decoded, tt.numbers)
+		}
+	}
+
+	for n := uint64(0); n < 2000; n += 37 {
+		id := encoder.Encode([]uint64{n}, DefaultShortIDLength)
+		if len(id) < DefaultShortIDLength {
+			t.Fatalf("Encode(%d) = %q is shorter than the minimum length", n, id)
+		}
+		if decoded := encoder.Decode(id); len(decoded) != 1 || decoded[0] != n {
+			t.Fatalf("Decode(%q) = %v, want [%d]", id, decoded, n)
+		}
+	}
+}
+
+func TestNewShortIDGenerator(t *testing.T) {
+	seq := &counterSequence{}
+	for _, strategy := range []string{"", StrategyRandom, StrategySequence, StrategySqids} {
+		if _, err := NewShortIDGenerator(strategy, seq, ""); err != nil {
+			t.Errorf("NewShortIDGenerator(%q) error = %v", strategy, err)
+		}
+	}
+	if _, err := NewShortIDGenerator("md5", seq, ""); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+	for _, alphabet := range []string{"aab", "ab", "abc-def", "abc/def", "abc_def", "abc.def", "abcdé"} {
+		if _, err := NewShortIDGenerator(StrategySqids, seq, alphabet); err == nil {
+			t.Errorf("expected alphabet %q to be rejected", alphabet)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package workspaces defines the member roles of a workspace and what each role
+// may do. Roles are ordered: every role can do what the roles below it can.
+package workspaces
+
+// Member roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// rank orders the roles; unknown roles rank below viewer
+var rank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// Valid reports whether role is one of the member roles
+func Valid(role string) bool {
+	return rank[role] > 0
+}
+
+// Allows reports whether role grants at least the rights of min. Viewers read
+// links, editors change them and admins manage members.
+func Allows(role, min string) bool {
+	return Valid(role) && rank[role] >= rank[min]
+}
+
+// CanManage reports whether a member with role actor may invite or remove a
+// member with role target. Owners manage everyone; admins manage editors and
+// viewers.
+func CanManage(actor, target string) bool {
+	if !Valid(actor) || !Valid(target) {
+		return false
+	}
+	if actor == RoleOwner {
+		return true
+	}
+	return Allows(actor, RoleAdmin) && rank[target] < rank[RoleAdmin]
+}
@@ -0,0 +1,50 @@
+package workspaces
+
+import "testing"
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		role string
+		min  string
+		want bool
+	}{
+		{RoleOwner, RoleAdmin, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleOwner, false},
+		{RoleEditor, RoleEditor, true},
+		{RoleEditor, RoleAdmin, false},
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleEditor, false},
+		{"", RoleViewer, false},
+		{"superuser", RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.role, tt.min); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %t, want %t", tt.role, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestCanManage(t *testing.T) {
+	tests := []struct {
+		actor  string
+		target string
+		want   bool
+	}{
+		{RoleOwner, RoleOwner, true},
+		{RoleOwner, RoleAdmin, true},
+		{RoleOwner, RoleViewer, true},
+		{RoleAdmin, RoleOwner, false},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleAdmin, RoleEditor, true},
+		{RoleAdmin, RoleViewer, true},
+		{RoleEditor, RoleViewer, false},
+		{RoleViewer, RoleViewer, false},
+		{RoleOwner, "superuser", false},
+	}
+	for _, tt := range tests {
+		if got := CanManage(tt.actor, tt.target); got != tt.want {
+			t.Errorf("CanManage(%q, %q) = %t, want %t", tt.actor, tt.target, got, tt.want)
+		}
+	}
+}
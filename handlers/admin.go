@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"U-235/models"
+	"U-235/services"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+type AdminHandlers interface {
+	ListUsersHandler(c echo.Context) error
+	ListUrlsHandler(c echo.Context) error
+	DisableUrlHandler(c echo.Context) error
+	SuspendUserHandler(c echo.Context) error
+	StatsHandler(c echo.Context) error
+	AuditLogHandler(c echo.Context) error
+}
+
+type AdminHandler struct {
+	AdminService services.AdminServices
+}
+
+func NewAdminHandler(AdminService services.AdminServices) AdminHandlers {
+	return &AdminHandler{
+		AdminService: AdminService,
+	}
+}
+
+// ListUsersHandler lists every user; ?q= searches email and name
+func (a *AdminHandler) ListUsersHandler(c echo.Context) error {
+	adminID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	res, err := a.AdminService.ListUsers(c.Request().Context(), adminID, c.QueryParam("q"), page, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ListUrlsHandler lists the links of every workspace; ?q= searches the slug,
+// destination and domain, and ?user_id=, ?status= and ?disabled=true narrow the list
+func (a *AdminHandler) ListUrlsHandler(c echo.Context) error {
+	adminID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	filter := models.AdminUrlFilter{
+		Search: c.QueryParam("q"),
+		Status: c.QueryParam("status"),
+	}
+	switch filter.Status {
+	case "", models.UrlStatusActive, models.UrlStatusInactive, models.UrlStatusScheduled:
+	default:
+		return models.ErrBadRequest.WithMessage("status must be active, inactive or scheduled")
+	}
+	if userId := c.QueryParam("user_id"); userId != "" {
+		id, err := uuid.Parse(userId)
+		if err != nil {
+			return models.ErrBadRequest.WithMessage("Invalid user UUID format")
+		}
+		filter.UserId = id
+	}
+	if disabled := c.QueryParam("disabled"); disabled != "" {
+		parsed, err := strconv.ParseBool(disabled)
+		if err != nil {
+			return models.ErrBadRequest.WithMessage("disabled must be true or false")
+		}
+		filter.Disabled = parsed
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	res, err := a.AdminService.ListUrls(c.Request().Context(), adminID, &filter, page, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// DisableUrlHandler force-disables an abusive link; the reason goes to the audit log
+func (a *AdminHandler) DisableUrlHandler(c echo.Context) error {
+	adminID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	urlId, err := uuid.Parse(c.Param("urlId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid URL UUID format")
+	}
+
+	var req models.AdminActionReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	urlInfo, err := a.AdminService.DisableUrl(c.Request().Context(), adminID, urlId, req.Reason)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, urlInfo)
+}
+
+// SuspendUserHandler suspends a user and disables their links; the reason goes to
+// the audit log
+func (a *AdminHandler) SuspendUserHandler(c echo.Context) error {
+	adminID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	userId, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid user UUID format")
+	}
+
+	var req models.AdminActionReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	disabled, err := a.AdminService.SuspendUser(c.Request().Context(), adminID, userId, req.Reason)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":        "successfully suspended user",
+		"links_disabled": disabled,
+	})
+}
+
+func (a *AdminHandler) StatsHandler(c echo.Context) error {
+	adminID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	stats, err := a.AdminService.GetStats(c.Request().Context(), adminID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
+func (a *AdminHandler) AuditLogHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	res, err := a.AdminService.ListAudit(c.Request().Context(), page, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"U-235/models"
+	"U-235/services"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AnalyticsHandlers interface {
+	UrlStatsHandler(c echo.Context) error
+}
+
+type AnalyticsHandler struct {
+	AnalyticsService services.AnalyticsServices
+}
+
+func NewAnalyticsHandler(AnalyticsService services.AnalyticsServices) AnalyticsHandlers {
+	return &AnalyticsHandler{
+		AnalyticsService: AnalyticsService,
+	}
+}
+
+// UrlStatsHandler answers GET /api/urls/:urlId/stats?from=&to=&bucket=&top=
+// with totals, a time series and top-N breakdowns for one link.
+func (a *AnalyticsHandler) UrlStatsHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	urlId, err := uuid.Parse(c.Param("urlId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid Url UUID format")
+	}
+
+	var req models.UrlStatsReq
+	if req.From, err = parseTimeParam(c, "from"); err != nil {
+		return models.ErrBadRequest.WithMessage("'from' must be an RFC 3339 timestamp")
+	}
+	if req.To, err = parseTimeParam(c, "to"); err != nil {
+		return models.ErrBadRequest.WithMessage("'to' must be an RFC 3339 timestamp")
+	}
+	req.Bucket = c.QueryParam("bucket")
+	req.Top, _ = strconv.Atoi(c.QueryParam("top"))
+
+	stats, err := a.AnalyticsService.GetUrlStats(c.Request().Context(), userID, urlId, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// parseTimeParam reads an optional RFC 3339 query parameter; missing values return the zero time
+func parseTimeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
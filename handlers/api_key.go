@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"U-235/models"
+	"U-235/services"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type ApiKeyHandlers interface {
+	CreateApiKeyHandler(c echo.Context) error
+	ListApiKeysHandler(c echo.Context) error
+	RenameApiKeyHandler(c echo.Context) error
+	RevokeApiKeyHandler(c echo.Context) error
+}
+
+type ApiKeyHandler struct {
+	ApiKeyService services.ApiKeyServices
+}
+
+func NewApiKeyHandler(ApiKeyService services.ApiKeyServices) ApiKeyHandlers {
+	return &ApiKeyHandler{
+		ApiKeyService: ApiKeyService,
+	}
+}
+
+func (a *ApiKeyHandler) CreateApiKeyHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	var req models.CreateApiKeyReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	res, err := a.ApiKeyService.CreateApiKey(c.Request().Context(), userID, &req)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	// The full key is only ever returned here
+	return c.JSON(http.StatusCreated, res)
+}
+
+func (a *ApiKeyHandler) ListApiKeysHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	keys, err := a.ApiKeyService.ListApiKeys(c.Request().Context(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"api_keys": keys,
+	})
+}
+
+func (a *ApiKeyHandler) RenameApiKeyHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	keyId, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid API key UUID format")
+	}
+
+	var req models.RenameApiKeyReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	if err := a.ApiKeyService.RenameApiKey(c.Request().Context(), userID, keyId, req.Name); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "successfully renamed API key",
+	})
+}
+
+func (a *ApiKeyHandler) RevokeApiKeyHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	keyId, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid API key UUID format")
+	}
+
+	if err := a.ApiKeyService.RevokeApiKey(c.Request().Context(), userID, keyId); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "successfully revoked API key",
+	})
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"U-235/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// urlExportColumns is the header row of CSV exports, also understood by imports
+var urlExportColumns = []string{"original_url", "short_url", "short_link", "domain", "expires_at", "is_active", "redirect_type", "created_at", "password_hash", "max_clicks", "activates_at", "routing_rules", "variants", "sticky_variants", "query_params", "forward_query", "preview"}
+
+// readCsvRows reads a CSV body whose first row names the columns. fn is called for
+// every data row with its line number and a lookup by column name; columns missing
+// from a row read as empty. required columns must appear in the header.
+func readCsvRows(r io.Reader, required []string, maxRows int, fn func(line int, field func(name string) string) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("CSV body must start with a header row")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return models.ErrBadRequest.WithMessage(fmt.Sprintf("CSV header is missing the %s column", name))
+		}
+	}
+
+	for line, rows := 2, 0; ; line, rows = line+1, rows+1 {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return models.ErrBadRequest.WithMessage(fmt.Sprintf("Malformed CSV: %v", err))
+		}
+		if rows == maxRows {
+			return models.ErrBadRequest.WithMessage(fmt.Sprintf("At most %d rows are allowed per request", maxRows))
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if err := fn(line, field); err != nil {
+			return err
+		}
+	}
+}
+
+// readBulkCsv parses a CSV upload for bulk link creation. original_url and
+// expire_time are required columns, custom_short_url, redirect_type, max_clicks,
+// activates_at (RFC 3339) and domain optional.
+func readBulkCsv(r io.Reader, maxItems int) ([]models.CreateShortUrlReq, error) {
+	var items []models.CreateShortUrlReq
+	err := readCsvRows(r, []string{"original_url", "expire_time"}, maxItems, func(line int, field func(string) string) error {
+		item := models.CreateShortUrlReq{
+			OriginalUrl:    field("original_url"),
+			CustomShortUrl: field("custom_short_url"),
+			Domain:         field("domain"),
+		}
+
+		var err error
+		if v := field("expire_time"); v != "" {
+			if item.ExpireTime, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: expire_time must be a number of hours", line))
+			}
+		}
+		if v := field("redirect_type"); v != "" {
+			if item.RedirectType, err = strconv.Atoi(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: redirect_type must be a status code", line))
+			}
+		}
+		if v := field("max_clicks"); v != "" {
+			if item.MaxClicks, err = strconv.Atoi(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: max_clicks must be a number", line))
+			}
+		}
+		if item.ActivatesAt, err = parseCsvTime(field("activates_at")); err != nil {
+			return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: activates_at must be an RFC 3339 timestamp", line))
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+// readImportRows parses an import upload in either export format. In CSV,
+// is_active defaults to true and redirect_type to the link default.
+func readImportRows(r io.Reader, format string, maxRows int) ([]models.UrlExportRow, error) {
+	var rows []models.UrlExportRow
+
+	if format == models.ExportFormatNdjson {
+		decoder := json.NewDecoder(r)
+		for line := 1; ; line++ {
+			var row models.UrlExportRow
+			err := decoder.Decode(&row)
+			if errors.Is(err, io.EOF) {
+				return rows, nil
+			}
+			if err != nil {
+				return nil, models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: %v", line, err))
+			}
+			if len(rows) == maxRows {
+				return nil, models.ErrBadRequest.WithMessage(fmt.Sprintf("At most %d rows are allowed per request", maxRows))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	err := readCsvRows(r, []string{"original_url", "short_url", "expires_at"}, maxRows, func(line int, field func(string) string) error {
+		row := models.UrlExportRow{
+			OriginalUrl:  field("original_url"),
+			ShortUrl:     field("short_url"),
+			Domain:       field("domain"),
+			IsActive:     true,
+			PasswordHash: field("password_hash"),
+		}
+
+		var err error
+		if v := field("expires_at"); v != "" {
+			if row.ExpiresAt, err = time.Parse(time.RFC3339, v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: expires_at must be an RFC 3339 timestamp", line))
+			}
+		}
+		if v := field("is_active"); v != "" {
+			if row.IsActive, err = strconv.ParseBool(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: is_active must be true or false", line))
+			}
+		}
+		if v := field("redirect_type"); v != "" {
+			if row.RedirectType, err = strconv.Atoi(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: redirect_type must be a status code", line))
+			}
+		}
+		if v := field("max_clicks"); v != "" {
+			if row.MaxClicks, err = strconv.Atoi(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: max_clicks must be a number", line))
+			}
+		}
+		if row.ActivatesAt, err = parseCsvTime(field("activates_at")); err != nil {
+			return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: activates_at must be an RFC 3339 timestamp", line))
+		}
+		if v := field("routing_rules"); v != "" {
+			if err := json.Unmarshal([]byte(v), &row.RoutingRules); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: routing_rules must be a JSON array of rules", line))
+			}
+		}
+		if v := field("variants"); v != "" {
+			if err := json.Unmarshal([]byte(v), &row.Variants); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: variants must be a JSON array of variants", line))
+			}
+		}
+		if v := field("sticky_variants"); v != "" {
+			if row.StickyVariants, err = strconv.ParseBool(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: sticky_variants must be true or false", line))
+			}
+		}
+		if v := field("query_params"); v != "" {
+			if err := json.Unmarshal([]byte(v), &row.QueryParams); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: query_params must be a JSON array of parameters", line))
+			}
+		}
+		if v := field("forward_query"); v != "" {
+			if row.ForwardQuery, err = strconv.ParseBool(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: forward_query must be true or false", line))
+			}
+		}
+		if v := field("preview"); v != "" {
+			if row.Preview, err = strconv.ParseBool(v); err != nil {
+				return models.ErrBadRequest.WithMessage(fmt.Sprintf("Line %d: preview must be true or false", line))
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// exportCsvRecord formats a row in urlExportColumns order
+func exportCsvRecord(row *models.UrlExportRow) []string {
+	return []string{
+		row.OriginalUrl,
+		row.ShortUrl,
+		row.ShortLink,
+		row.Domain,
+		row.ExpiresAt.UTC().Format(time.RFC3339),
+		strconv.FormatBool(row.IsActive),
+		strconv.Itoa(row.RedirectType),
+		row.CreatedAt.UTC().Format(time.RFC3339),
+		row.PasswordHash,
+		strconv.Itoa(row.MaxClicks),
+		formatCsvTime(row.ActivatesAt),
+		formatCsvList(row.RoutingRules),
+		formatCsvList(row.Variants),
+		strconv.FormatBool(row.StickyVariants),
+		formatCsvList(row.QueryParams),
+		strconv.FormatBool(row.ForwardQuery),
+		strconv.FormatBool(row.Preview),
+	}
+}
+
+// formatCsvList writes a list such as routing rules as a JSON cell, or an empty cell
+func formatCsvList[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// parseCsvTime reads an optional RFC 3339 cell; an empty cell is nil
+func parseCsvTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatCsvTime writes an optional time as RFC 3339, or an empty cell
+func formatCsvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
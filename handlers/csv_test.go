@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"U-235/models"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportCsvRoundTrip(t *testing.T) {
+	expires := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	activates := expires.Add(-24 * time.Hour)
+	rows := []models.UrlExportRow{
+		{
+			OriginalUrl:  `https://example.com/search?q="quoted",comma&lang=en`,
+			ShortUrl:     "sale-2026",
+			ShortLink:    "https://go.brand.com/sale-2026",
+			Domain:       "go.brand.com",
+			ExpiresAt:    expires,
+			IsActive:     true,
+			RedirectType: 301,
+			PasswordHash: "$2a$10$abc",
+			MaxClicks:    5,
+			ActivatesAt:  &activates,
+			Variants: []models.Variant{
+				{Name: "a, first", Destination: "https://example.com/a", Weight: 1},
+				{Name: "b\nsecond", Destination: "https://example.com/b", Weight: 3},
+			},
+			QueryParams:  []models.QueryParam{{Key: "utm_source", Value: `news, "weekly"`}},
+			ForwardQuery: true,
+		},
+		{
+			OriginalUrl:  "https://example.com/plain",
+			ShortUrl:     "abc1234",
+			ExpiresAt:    expires,
+			RedirectType: 302,
+		},
+	}
+
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	_ = w.Write(urlExportColumns)
+	for i := range rows {
+		if err := w.Write(exportCsvRecord(&rows[i])); err != nil {
+			t.Fatalf("writing row %d: %v", i, err)
+		}
+	}
+	w.Flush()
+
+	if !strings.Contains(out.String(), `"https://example.com/search?q=""quoted"",comma&lang=en"`) {
+		t.Errorf("destination with quotes and commas was not escaped:\n%s", out.String())
+	}
+
+	got, err := readImportRows(&out, models.ExportFormatCsv, 10)
+	if err != nil {
+		t.Fatalf("readImportRows() error = %v", err)
+	}
+	if len(got) != len(rows) {
+		t.Fatalf("read %d rows, want %d", len(got), len(rows))
+	}
+	for i := range rows {
+		// The short link and creation time are informational and not imported
+		want := rows[i]
+		want.ShortLink, want.CreatedAt = "", time.Time{}
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("row %d:\n got %+v\nwant %+v", i, got[i], want)
+		}
+	}
+}
+
+func TestReadImportRowsCsvErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing column", body: "original_url,short_url\nhttps://example.com,abc1234\n"},
+		{name: "bad timestamp", body: "original_url,short_url,expires_at\nhttps://example.com,abc1234,tomorrow\n"},
+		{name: "bad variants", body: "original_url,short_url,expires_at,variants\nhttps://example.com,abc1234,2026-11-01T12:00:00Z,[{\n"},
+		{name: "too many rows", body: "original_url,short_url,expires_at\na,b,2026-11-01T12:00:00Z\nc,d,2026-11-01T12:00:00Z\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readImportRows(strings.NewReader(tt.body), models.ExportFormatCsv, 1)
+			if !errors.Is(err, models.ErrBadRequest) {
+				t.Errorf("readImportRows() error = %v, want %v", err, models.ErrBadRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"U-235/models"
+	"U-235/services"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type DomainHandlers interface {
+	AddDomainHandler(c echo.Context) error
+	ListDomainsHandler(c echo.Context) error
+	VerifyDomainHandler(c echo.Context) error
+	DeleteDomainHandler(c echo.Context) error
+}
+
+type DomainHandler struct {
+	DomainService services.DomainServices
+}
+
+func NewDomainHandler(DomainService services.DomainServices) DomainHandlers {
+	return &DomainHandler{
+		DomainService: DomainService,
+	}
+}
+
+// AddDomainHandler registers a custom domain and returns the TXT record that verifies it
+func (d *DomainHandler) AddDomainHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	var req models.CreateDomainReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	res, err := d.DomainService.AddDomain(c.Request().Context(), userID, &req)
+	if err != nil {
+		return fmt.Errorf("failed to add domain: %w", err)
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+func (d *DomainHandler) ListDomainsHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	domains, err := d.DomainService.ListDomains(c.Request().Context(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to list domains: %w", err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"domains": domains,
+	})
+}
+
+// VerifyDomainHandler checks the domain's TXT record now; it can be retried
+// until DNS changes have propagated.
+func (d *DomainHandler) VerifyDomainHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	domainId, err := uuid.Parse(c.Param("domainId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid domain UUID format")
+	}
+
+	res, err := d.DomainService.VerifyDomain(c.Request().Context(), userID, domainId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (d *DomainHandler) DeleteDomainHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	domainId, err := uuid.Parse(c.Param("domainId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid domain UUID format")
+	}
+
+	if err := d.DomainService.DeleteDomain(c.Request().Context(), userID, domainId); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "successfully deleted domain",
+	})
+}
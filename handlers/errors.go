@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"U-235/models"
+	"U-235/repositories"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse is the single JSON envelope used for every error response
+type ErrorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestId string      `json:"request_id,omitempty"`
+}
+
+// FieldError describes one failed validation rule
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// HTTPErrorHandler is installed as echo's error handler so that handlers and
+// middleware can simply return errors. It maps AppErrors, echo.HTTPErrors,
+// validation errors and repository sentinel errors to an ErrorResponse.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	appErr := toAppError(err)
+	if appErr.StatusCode >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	res := ErrorResponse{
+		Code:      appErr.Code,
+		Message:   appErr.Message,
+		Details:   appErr.Details,
+		RequestId: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
+
+	var writeErr error
+	if c.Request().Method == http.MethodHead {
+		writeErr = c.NoContent(appErr.StatusCode)
+	} else {
+		writeErr = c.JSON(appErr.StatusCode, res)
+	}
+	if writeErr != nil {
+		c.Logger().Error(writeErr)
+	}
+}
+
+func toAppError(err error) *models.AppError {
+	var appErr *models.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		details := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			details = append(details, FieldError{
+				Field: fe.Field(),
+				Rule:  fe.Tag(),
+				Param: fe.Param(),
+			})
+		}
+		return models.ErrBadRequest.WithMessage("Invalid request payload").WithDetails(details)
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message := http.StatusText(httpErr.Code)
+		if httpErr.Message != nil {
+			message = fmt.Sprint(httpErr.Message)
+		}
+		return models.NewAppError(statusCode(httpErr.Code), message, httpErr.Code)
+	}
+
+	switch {
+	case errors.Is(err, repositories.ErrShortUrlExists):
+		return models.ErrShortUrlTaken
+	case errors.Is(err, repositories.ErrRefreshTokenReused):
+		return models.ErrUnauthorized
+	case errors.Is(err, sql.ErrNoRows):
+		return models.NewAppError("NOT_FOUND", "Resource not found", http.StatusNotFound)
+	}
+
+	return models.ErrInternal
+}
+
+// statusCode turns an HTTP status into an error code, e.g. 404 -> NOT_FOUND
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "ERROR"
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"U-235/models"
+	"U-235/repositories"
+	"U-235/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	validationErr := utils.NewValidator().Validate(&models.UnlockUrlReq{})
+
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantDetails interface{}
+	}{
+		{
+			name:        "app error",
+			err:         models.ErrUrlNotFound,
+			wantStatus:  http.StatusNotFound,
+			wantCode:    "URL_NOT_FOUND",
+			wantMessage: "The requested URL does not exist",
+		},
+		{
+			name:        "wrapped app error with details",
+			err:         fmt.Errorf("resolve: %w", models.ErrShortUrlTaken.WithDetails("abc1234")),
+			wantStatus:  http.StatusConflict,
+			wantCode:    "SHORT_URL_TAKEN",
+			wantMessage: "Custom short url already exists",
+			wantDetails: "abc1234",
+		},
+		{
+			name:        "cause is not sent",
+			err:         models.ErrInternal.Wrap(errors.New("connection refused")),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    "INTERNAL_ERROR",
+			wantMessage: "Internal server error",
+		},
+		{
+			name:        "validation errors",
+			err:         validationErr,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "BAD_REQUEST",
+			wantMessage: "Invalid request payload",
+			wantDetails: []interface{}{map[string]interface{}{"field": "password", "rule": "required"}},
+		},
+		{
+			name:        "echo error",
+			err:         echo.ErrMethodNotAllowed,
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantCode:    "METHOD_NOT_ALLOWED",
+			wantMessage: "Method Not Allowed",
+		},
+		{
+			name:        "repository sentinel",
+			err:         fmt.Errorf("insert: %w", repositories.ErrShortUrlExists),
+			wantStatus:  http.StatusConflict,
+			wantCode:    "SHORT_URL_TAKEN",
+			wantMessage: "Custom short url already exists",
+		},
+		{
+			name:        "no rows",
+			err:         sql.ErrNoRows,
+			wantStatus:  http.StatusNotFound,
+			wantCode:    "NOT_FOUND",
+			wantMessage: "Resource not found",
+		},
+		{
+			name:        "unknown error",
+			err:         errors.New("pq: deadlock detected"),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    "INTERNAL_ERROR",
+			wantMessage: "Internal server error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/urls", nil), rec)
+			c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+
+			HTTPErrorHandler(tt.err, c)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var res struct {
+				Code      string      `json:"code"`
+				Message   string      `json:"message"`
+				Details   interface{} `json:"details"`
+				RequestId string      `json:"request_id"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if res.Code != tt.wantCode || res.Message != tt.wantMessage || res.RequestId != "req-1" {
+				t.Errorf("response = %+v, want code %s, message %q and the request id", res, tt.wantCode, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(res.Details, tt.wantDetails) {
+				t.Errorf("details = %#v, want %#v", res.Details, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorHandlerHead(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodHead, "/abc1234", nil), rec)
+
+	HTTPErrorHandler(models.ErrUrlNotFound, c)
+
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("HEAD response = %d with %d byte body, want 404 without a body", rec.Code, rec.Body.Len())
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"U-235/core/routing"
+	"U-235/models"
+	"github.com/labstack/echo/v4"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// previewPage shows where a short link leads before the visitor goes there.
+// Continuing posts back to the short link, which then redirects as usual.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+</head>
+<body>
+<main>
+<h1>This link leads to</h1>
+{{if .Protected}}<p>A password protected page. You will be asked for the password when you continue.</p>
+{{else if .ClickLimited}}<p>A page that can only be opened a limited number of times. Continuing uses one of its visits.</p>
+{{else if eq (len .Destinations) 1}}<p><code>{{index .Destinations 0}}</code></p>
+{{else}}<p>One of these pages, picked for you when you continue:</p>
+<ul>{{range .Destinations}}<li><code>{{.}}</code></li>{{end}}</ul>
+{{end}}<p>Short link created on <time datetime="{{.CreatedAt.Format "2006-01-02"}}">{{.CreatedAt.Format "2 January 2006"}}</time>{{with .OwnerName}} by {{.}}{{end}}.</p>
+<form method="post" action="{{.Action}}">
+<button type="submit">Continue</button>
+</form>
+</main>
+</body>
+</html>
+`))
+
+type previewPageData struct {
+	Action       string
+	Protected    bool
+	ClickLimited bool
+	Destinations []string
+	CreatedAt    time.Time
+	OwnerName    string
+}
+
+// renderPreview answers "/<shortId>+", and GET requests for links in preview mode,
+// with the preview page instead of a redirect. Nothing is counted as a click, so
+// the destination of a protected or click-limited link is not shown.
+func (u *UrlHandler) renderPreview(c echo.Context, shortID string, key string) error {
+	preview, err := u.UrlService.PreviewShortUrl(c.Request().Context(), key)
+	if err != nil {
+		return err
+	}
+	entry := preview.Entry
+
+	data := previewPageData{
+		Action:       "/" + shortID,
+		Protected:    entry.Protected,
+		ClickLimited: entry.MaxClicks > 0,
+		CreatedAt:    preview.CreatedAt.UTC(),
+		OwnerName:    preview.OwnerName,
+	}
+	// The visitor's query string goes along, for links that forward it
+	if query := c.QueryString(); query != "" {
+		data.Action += "?" + query
+	}
+	if !data.Protected && !data.ClickLimited {
+		data.Destinations = u.previewDestinations(c, shortID, entry)
+	}
+
+	var body strings.Builder
+	if err := previewPage.Execute(&body, data); err != nil {
+		return models.ErrInternal.Wrap(err)
+	}
+	return c.HTML(http.StatusOK, body.String())
+}
+
+// previewDestinations lists where continuing can lead this visitor: the routing
+// rule it matches, every variant of a split link, or the original URL. Unlike a
+// redirect it picks no variant, so a sticky assignment is not made by looking.
+func (u *UrlHandler) previewDestinations(c echo.Context, shortID string, entry *models.CachedUrl) []string {
+	query := c.QueryString()
+	vars := map[string]string{"short_id": shortID}
+
+	if len(entry.Rules) > 0 {
+		if destination, ok := routing.Evaluate(entry.Rules, u.visitor(c)); ok {
+			return []string{entry.WithQuery(destination, query, vars)}
+		}
+	}
+	if len(entry.Variants) > 0 {
+		destinations := make([]string, 0, len(entry.Variants))
+		for _, variant := range entry.Variants {
+			destinations = append(destinations, entry.WithQuery(variant.URL, query, map[string]string{
+				"short_id": shortID,
+				"variant":  variant.Name,
+			}))
+		}
+		return destinations
+	}
+	return []string{entry.WithQuery(entry.OriginalUrl, query, vars)}
+}
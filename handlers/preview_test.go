@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"U-235/core/routing"
+	"U-235/models"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreviewPage(t *testing.T) {
+	entries := map[string]*models.CachedUrl{
+		"plain12": {Id: uuid.New(), OriginalUrl: "https://example.com/plain", RedirectType: http.StatusFound},
+		"split12": {Id: uuid.New(), OriginalUrl: "https://example.com/a", Variants: []routing.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 1},
+			{Name: "b", URL: "https://example.com/b", Weight: 1},
+		}},
+		"locked1": {Id: uuid.New(), OriginalUrl: "https://example.com/secret", Protected: true},
+		"limited": {Id: uuid.New(), OriginalUrl: "https://example.com/once", MaxClicks: 1},
+		"preview": {Id: uuid.New(), OriginalUrl: "https://example.com/careful", Preview: true},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		want     []string
+		wantNot  []string
+		redirect bool
+	}{
+		{name: "plus suffix", path: "/plain12+", want: []string{"<code>https://example.com/plain</code>", `action="/plain12"`, "2 January 2026", "by Ada"}},
+		{name: "query string is kept", path: "/plain12+?ref=mail", want: []string{`action="/plain12?ref=mail"`}},
+		{name: "every variant is listed", path: "/split12+", want: []string{"<code>https://example.com/a</code>", "<code>https://example.com/b</code>"}},
+		{name: "protected link hides its destination", path: "/locked1+", want: []string{"password protected"}, wantNot: []string{"example.com/secret"}},
+		{name: "click-limited link hides its destination", path: "/limited+", want: []string{"limited number of times"}, wantNot: []string{"example.com/once"}},
+		{name: "link in preview mode", path: "/preview", want: []string{"<code>https://example.com/careful</code>"}},
+		{name: "no suffix redirects", path: "/plain12", redirect: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, recorder := newTestUrlHandler(entries)
+
+			rec, err := serveShortLink(h, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatalf("ShortLinkRedirectHandler() error = %v", err)
+			}
+			if tt.redirect {
+				if rec.Code != http.StatusFound {
+					t.Errorf("status = %d, want %d", rec.Code, http.StatusFound)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if len(recorder.clicks) != 0 {
+				t.Errorf("clicks recorded for a preview: %+v", recorder.clicks)
+			}
+			body := rec.Body.String()
+			for _, s := range tt.want {
+				if !strings.Contains(body, s) {
+					t.Errorf("preview page is missing %q:\n%s", s, body)
+				}
+			}
+			for _, s := range tt.wantNot {
+				if strings.Contains(body, s) {
+					t.Errorf("preview page shows %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"U-235/core/qr"
+	"U-235/models"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// UrlQrCodeHandler answers GET /api/urls/:urlId/qr?format=&size=&margin=&level=&fg=&bg=
+// with a QR code of the link's full short URL, as PNG or SVG.
+func (u *UrlHandler) UrlQrCodeHandler(c echo.Context) error {
+	userId, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	urlId, err := uuid.Parse(c.Param("urlId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid Url UUID format")
+	}
+
+	req := models.QrCodeReq{
+		Format:     c.QueryParam("format"),
+		Level:      c.QueryParam("level"),
+		Foreground: c.QueryParam("fg"),
+		Background: c.QueryParam("bg"),
+	}
+	if req.Size, err = parseIntParam(c, "size", 0); err != nil {
+		return models.ErrBadRequest.WithMessage("size must be a number of pixels")
+	}
+	if req.Margin, err = parseIntParam(c, "margin", qr.DefaultMargin); err != nil {
+		return models.ErrBadRequest.WithMessage("margin must be a number of modules")
+	}
+
+	res, err := u.UrlService.UrlQrCodeService(userId, urlId, &req, c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", res.ShortUrl+"."+req.Format))
+	return c.Blob(http.StatusOK, res.ContentType, res.Image)
+}
+
+// parseIntParam reads an optional integer query parameter; missing values return def
+func parseIntParam(c echo.Context, name string, def int) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
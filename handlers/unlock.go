@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"U-235/middleware"
+	"U-235/models"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// unlockPage is the password form served to browsers that open a protected link
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+</head>
+<body>
+<main>
+<h1>This link is password protected</h1>
+{{if .Invalid}}<p role="alert">The password is incorrect.</p>{{end}}
+<form method="post" action="/api/redirect/{{.ShortID}}/unlock">
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</main>
+</body>
+</html>
+`))
+
+type unlockPageData struct {
+	ShortID string
+	Invalid bool
+}
+
+// UnlockUrlHandler answers POST /api/redirect/:shortId/unlock. A correct password
+// sets the link's unlock cookie; JSON clients also receive the token for the
+// X-Unlock-Token header, while form posts are sent back to the short link.
+// Like RedirectHandler, the link is looked up on the domain query parameter
+// or the request's host.
+func (u *UrlHandler) UnlockUrlHandler(c echo.Context) error {
+	shortID := c.Param("shortId")
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	var req models.UnlockUrlReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	entry, err := u.UrlService.UnlockShortUrl(c.Request().Context(), u.linkKey(c, apiHost(c), shortID), req.Password)
+	if err != nil {
+		if isFormPost(c) && errors.Is(err, models.ErrInvalidPassword) {
+			return renderUnlockPage(c, shortID, true)
+		}
+		return err
+	}
+
+	token, expiresAt, err := middleware.CreateUnlockToken(entry.Id, entry.PasswordVer)
+	if err != nil {
+		return models.ErrInternal.Wrap(err)
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     middleware.UnlockCookiePrefix + shortID,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if !isFormPost(c) {
+		return c.JSON(http.StatusOK, models.UnlockUrlRes{
+			UnlockToken: token,
+			ExpiresIn:   int64(middleware.UnlockTokenExpiration.Seconds()),
+		})
+	}
+	return c.Redirect(http.StatusSeeOther, "/"+shortID)
+}
+
+// passwordChallenge answers a request for a protected link that carries no valid
+// unlock token: browsers get the password form, API clients a 401 error.
+func passwordChallenge(c echo.Context, shortID string, allowHTML bool) error {
+	if allowHTML && strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+		return renderUnlockPage(c, shortID, false)
+	}
+	return models.ErrPasswordRequired
+}
+
+func renderUnlockPage(c echo.Context, shortID string, invalid bool) error {
+	var body strings.Builder
+	if err := unlockPage.Execute(&body, unlockPageData{ShortID: shortID, Invalid: invalid}); err != nil {
+		return models.ErrInternal.Wrap(err)
+	}
+	return c.HTML(http.StatusUnauthorized, body.String())
+}
+
+func isFormPost(c echo.Context) bool {
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	return strings.HasPrefix(contentType, echo.MIMEApplicationForm) || strings.HasPrefix(contentType, echo.MIMEMultipartForm)
+}
@@ -1,32 +1,65 @@
 package handlers
 
 import (
+	"U-235/core/routing"
 	"U-235/middleware"
 	"U-235/models"
 	"U-235/services"
 	"U-235/utils"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// exportFlushRows is how many exported rows are buffered before being sent
+const exportFlushRows = 200
+
 type UrlHandlers interface {
 	CreateUrlHandler(c echo.Context) error
 	GetUrlHandler(c echo.Context) error
 	DeleteUrlHandler(c echo.Context) error
 	ExtendExpiryHandler(c echo.Context) error
+	UpdateUrlHandler(c echo.Context) error
+	BulkCreateUrlHandler(c echo.Context) error
+	ExportUrlsHandler(c echo.Context) error
+	ImportUrlsHandler(c echo.Context) error
+	UrlQrCodeHandler(c echo.Context) error
 	RedirectHandler(c echo.Context) error
+	UnlockUrlHandler(c echo.Context) error
+	ShortLinkRedirectHandler(c echo.Context) error
 }
 
+// A/B split assignments of sticky links are kept in a per-link cookie
+const (
+	variantCookiePrefix = "u235_variant_"
+	variantCookieTTL    = 30 * 24 * time.Hour
+)
+
 type UrlHandler struct {
-	UrlService services.UrlServices
+	UrlService    services.UrlServices
+	ClickRecorder services.ClickRecorder
+	BulkMaxItems  int
+	ImportMaxRows int
+	Countries     routing.CountryLookup // nil when no GeoIP database is configured
 }
 
-func NewUrlHandler(UrlService services.UrlServices) UrlHandlers {
+func NewUrlHandler(UrlService services.UrlServices, ClickRecorder services.ClickRecorder, BulkMaxItems int, ImportMaxRows int, Countries routing.CountryLookup) UrlHandlers {
 	return &UrlHandler{
-		UrlService: UrlService,
+		UrlService:    UrlService,
+		ClickRecorder: ClickRecorder,
+		BulkMaxItems:  BulkMaxItems,
+		ImportMaxRows: ImportMaxRows,
+		Countries:     Countries,
 	}
 }
 
@@ -34,41 +67,35 @@ func (u *UrlHandler) CreateUrlHandler(c echo.Context) error {
 	var url models.CreateShortUrlReq
 	if err := c.Bind(&url); err != nil {
 		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+		return models.ErrBadRequest
 	}
 
 	if err := c.Validate(&url); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request payload: %v", err))
+		return err
 	}
 
-	//Extract Token
-	token, err := utils.ExtractTokenFromHeader(c)
-	if err != nil {
-		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	//Extract Claims from token for UserId
-	claims, err := middleware.ValidateToken(token)
-	if err != nil {
-		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	// Set by the auth middleware for both JWTs and API keys
+	userId, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
 	}
 
-	userId := claims.UserID
-
 	// Validate user's UUID
 	if !utils.IsValidUUID(userId.String()) {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user id")
+		return models.ErrBadRequest.WithMessage("Invalid user id")
+	}
+
+	workspaceId, err := workspaceParam(c)
+	if err != nil {
+		return err
 	}
 
 	ctx := c.Request().Context()
 
 	// Get the final response here (models/ShortenedUrlInfoRes)
-	res, err := u.UrlService.CreateUrlService(userId, &url, ctx)
+	res, err := u.UrlService.CreateUrlService(userId, workspaceId, &url, ctx)
 	if err != nil {
-		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
 	return c.JSON(http.StatusOK, res)
 }
@@ -77,30 +104,39 @@ func (u *UrlHandler) GetUrlHandler(c echo.Context) error {
 	// Get user ID from context (assuming it's set by authentication middleware)
 	userID, ok := c.Get("userID").(uuid.UUID)
 	if !ok {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "unauthorized access",
-		})
+		return models.ErrUnauthorized
+	}
+
+	workspaceId, err := workspaceParam(c)
+	if err != nil {
+		return err
 	}
 
 	// Parse pagination parameters
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 
-	// Parse active status filter if provided
-	var isActive *bool
-	if activeStr := c.QueryParam("active"); activeStr != "" {
+	// Parse status filter if provided; ?active=true|false is kept for older clients
+	status := c.QueryParam("status")
+	switch status {
+	case "", models.UrlStatusActive, models.UrlStatusInactive, models.UrlStatusScheduled:
+	default:
+		return models.ErrBadRequest.WithMessage("status must be active, inactive or scheduled")
+	}
+	if activeStr := c.QueryParam("active"); activeStr != "" && status == "" {
 		active, err := strconv.ParseBool(activeStr)
 		if err == nil {
-			isActive = &active
+			status = models.UrlStatusInactive
+			if active {
+				status = models.UrlStatusActive
+			}
 		}
 	}
 
 	// Call the service to get URLs
-	response, err := u.UrlService.GetUserUrls(c.Request().Context(), userID, page, limit, isActive)
+	response, err := u.UrlService.GetUserUrls(c.Request().Context(), userID, workspaceId, page, limit, status)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to retrieve URLs: " + err.Error(),
-		})
+		return fmt.Errorf("failed to retrieve URLs: %w", err)
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -111,30 +147,26 @@ func (u *UrlHandler) DeleteUrlHandler(c echo.Context) error {
 
 	userID, ok := c.Get("userID").(uuid.UUID)
 	if !ok {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "unauthorized access",
-		})
+		return models.ErrUnauthorized
 	}
 	DelReq.UserId = userID
 
 	paramStr := c.Param("urlId")
 	paramUUID, err := uuid.Parse(paramStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid Url UUID format"})
+		return models.ErrBadRequest.WithMessage("Invalid Url UUID format")
 	}
 	DelReq.UrlRecordId = paramUUID
 
 	if err := c.Validate(&DelReq); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request payload: %v", err))
+		return err
 	}
 
 	ctx := c.Request().Context()
 
 	err = u.UrlService.SoftDeleteUrlService(&DelReq, ctx)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to delete URL: " + err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -147,44 +179,478 @@ func (u *UrlHandler) ExtendExpiryHandler(c echo.Context) error {
 	err := c.Bind(&ExtendExpiry)
 	if err != nil {
 		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+		return models.ErrBadRequest
 	}
 
 	err = c.Validate(&ExtendExpiry)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request payload: %v", err))
+		return err
 	}
 	userId, ok := c.Get("userID").(uuid.UUID)
 	if !ok {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "unauthorized access",
-		})
+		return models.ErrUnauthorized
 	}
 	ctx := c.Request().Context()
 
 	err = u.UrlService.ExtendExpiryService(userId, &ExtendExpiry, ctx)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err)
+		return err
+	}
+	message := fmt.Sprintf("Expiry extended by %d hour(s).", ExtendExpiry.Hours)
+	if ExtendExpiry.Hours == 0 {
+		message = fmt.Sprintf("Click limit raised by %d click(s).", ExtendExpiry.Clicks)
+	} else if ExtendExpiry.Clicks > 0 {
+		message = fmt.Sprintf("Expiry extended by %d hour(s) and click limit raised by %d click(s).", ExtendExpiry.Hours, ExtendExpiry.Clicks)
 	}
 	return c.JSON(http.StatusOK, map[string]string{
-		"message": fmt.Sprintf("Expiry extended by %d hour(s).", ExtendExpiry.Hours),
+		"message": message,
+	})
+}
+
+// BulkCreateUrlHandler answers POST /api/urls/bulk. The body is either JSON
+// ({"items": [...], "atomic": bool}) or CSV with a header row; ?atomic=true
+// works for both. Every item is reported on, by its position in the request.
+func (u *UrlHandler) BulkCreateUrlHandler(c echo.Context) error {
+	userId, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+	workspaceId, err := workspaceParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.BulkCreateUrlReq
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		items, err := readBulkCsv(c.Request().Body, u.BulkMaxItems)
+		if err != nil {
+			return err
+		}
+		req.Items = items
+	} else if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if atomic, err := strconv.ParseBool(c.QueryParam("atomic")); err == nil {
+		req.Atomic = req.Atomic || atomic
+	}
+
+	if len(req.Items) == 0 {
+		return models.ErrBadRequest.WithMessage("At least one item is required")
+	}
+	if len(req.Items) > u.BulkMaxItems {
+		return models.ErrBadRequest.WithMessage(fmt.Sprintf("At most %d items are allowed per request", u.BulkMaxItems))
+	}
+
+	// Items that fail validation are reported without reaching the service
+	results := make([]models.BulkCreateItemRes, len(req.Items))
+	valid := make([]models.CreateShortUrlReq, 0, len(req.Items))
+	validIdx := make([]int, 0, len(req.Items))
+	for i := range req.Items {
+		results[i].Index = i
+		if err := c.Validate(&req.Items[i]); err != nil {
+			results[i].Error = toAppError(err)
+			continue
+		}
+		valid = append(valid, req.Items[i])
+		validIdx = append(validIdx, i)
+	}
+	if req.Atomic && len(valid) < len(req.Items) {
+		return services.ErrBulkAborted.WithDetails(results)
+	}
+
+	created, err := u.UrlService.BulkCreateUrlService(userId, workspaceId, valid, req.Atomic, c.Request().Context())
+	for j, res := range created {
+		res.Index = validIdx[j]
+		results[validIdx[j]] = res
+	}
+	if errors.Is(err, services.ErrBulkAborted) {
+		return services.ErrBulkAborted.WithDetails(results)
+	}
+	if err != nil {
+		return err
+	}
+
+	res := models.BulkCreateUrlRes{Results: results}
+	for _, item := range results {
+		if item.Success {
+			res.Created++
+		} else {
+			res.Failed++
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ExportUrlsHandler answers GET /api/urls/export?format=csv|ndjson by streaming
+// every link of the workspace, including inactive ones, as a download.
+func (u *UrlHandler) ExportUrlsHandler(c echo.Context) error {
+	userId, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+	workspaceId, err := workspaceParam(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = models.ExportFormatCsv
+	}
+
+	res := c.Response()
+	switch format {
+	case models.ExportFormatCsv:
+		res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	case models.ExportFormatNdjson:
+		res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	default:
+		return models.ErrBadRequest.WithMessage("format must be csv or ndjson")
+	}
+	res.Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="urls-%s.%s"`, time.Now().UTC().Format("2006-01-02"), format))
+
+	// Output is buffered, so a failure before anything was sent still gets a
+	// regular error response
+	out := bufio.NewWriter(res)
+	csvOut := csv.NewWriter(out)
+	ndjsonOut := json.NewEncoder(out)
+	if format == models.ExportFormatCsv {
+		_ = csvOut.Write(urlExportColumns)
+	}
+
+	rows := 0
+	err = u.UrlService.ExportUrlsService(c.Request().Context(), userId, workspaceId, func(row *models.UrlExportRow) error {
+		if format == models.ExportFormatCsv {
+			if err := csvOut.Write(exportCsvRecord(row)); err != nil {
+				return err
+			}
+		} else if err := ndjsonOut.Encode(row); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			csvOut.Flush()
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			res.Flush()
+		}
+		return nil
 	})
+	if err != nil {
+		if res.Committed {
+			// Too late for an error response; the client sees a truncated file
+			c.Logger().Errorf("url export aborted after %d rows: %v", rows, err)
+			return nil
+		}
+		return err
+	}
+
+	csvOut.Flush()
+	if !res.Committed {
+		res.WriteHeader(http.StatusOK)
+	}
+	return out.Flush()
+}
+
+// ImportUrlsHandler answers POST /api/urls/import?on_conflict=skip|rename|fail. The
+// body is CSV (text/csv) or NDJSON in the export format, and every row is reported on.
+func (u *UrlHandler) ImportUrlsHandler(c echo.Context) error {
+	userId, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+	workspaceId, err := workspaceParam(c)
+	if err != nil {
+		return err
+	}
+
+	onConflict := c.QueryParam("on_conflict")
+	if onConflict == "" {
+		onConflict = models.ImportConflictSkip
+	}
+	if onConflict != models.ImportConflictSkip && onConflict != models.ImportConflictRename && onConflict != models.ImportConflictFail {
+		return models.ErrBadRequest.WithMessage("on_conflict must be skip, rename or fail")
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = models.ExportFormatNdjson
+		if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+			format = models.ExportFormatCsv
+		}
+	}
+	if format != models.ExportFormatCsv && format != models.ExportFormatNdjson {
+		return models.ErrBadRequest.WithMessage("format must be csv or ndjson")
+	}
+
+	rows, err := readImportRows(c.Request().Body, format, u.ImportMaxRows)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return models.ErrBadRequest.WithMessage("At least one row is required")
+	}
+
+	// Rows that fail validation are reported without reaching the service
+	results := make([]models.ImportRowRes, len(rows))
+	valid := make([]models.UrlExportRow, 0, len(rows))
+	validIdx := make([]int, 0, len(rows))
+	for i := range rows {
+		if err := c.Validate(&rows[i]); err != nil {
+			results[i] = models.ImportRowRes{Index: i, Status: models.ImportStatusFailed, ShortUrl: rows[i].ShortUrl, Error: toAppError(err)}
+			continue
+		}
+		valid = append(valid, rows[i])
+		validIdx = append(validIdx, i)
+	}
+
+	aborted := onConflict == models.ImportConflictFail && len(valid) < len(rows)
+	if !aborted {
+		imported, err := u.UrlService.ImportUrlsService(userId, workspaceId, valid, onConflict, c.Request().Context())
+		for j, row := range imported {
+			row.Index = validIdx[j]
+			results[validIdx[j]] = row
+		}
+		if err != nil && !errors.Is(err, services.ErrBulkAborted) {
+			return err
+		}
+		aborted = err != nil
+	}
+	if aborted {
+		for _, i := range validIdx {
+			if results[i].Status == "" {
+				results[i] = models.ImportRowRes{Index: i, Status: models.ImportStatusSkipped, ShortUrl: rows[i].ShortUrl}
+			}
+		}
+		return services.ErrBulkAborted.WithDetails(results)
+	}
+
+	res := models.ImportUrlsRes{Rows: results}
+	for _, row := range results {
+		switch row.Status {
+		case models.ImportStatusCreated:
+			res.Created++
+		case models.ImportStatusRenamed:
+			res.Renamed++
+		case models.ImportStatusSkipped:
+			res.Skipped++
+		default:
+			res.Failed++
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// UpdateUrlHandler answers PATCH /api/urls/:urlId; only the fields present in the body change.
+func (u *UrlHandler) UpdateUrlHandler(c echo.Context) error {
+	userId, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	urlId, err := uuid.Parse(c.Param("urlId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid Url UUID format")
+	}
+
+	var req models.UpdateUrlReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	res, err := u.UrlService.UpdateUrlService(userId, urlId, &req, c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
 }
 
 func (u *UrlHandler) RedirectHandler(c echo.Context) error {
 	shortID := c.Param("shortId")
 	if shortID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Missing short URL"})
+		return models.ErrBadRequest.WithMessage("Missing short URL")
 	}
 
 	// Get original URL from service
-	originalURL, err := u.UrlService.GetOriginalUrl(c.Request().Context(), shortID)
-	//fmt.Print(originalURL) # for Debugging
+	key := u.linkKey(c, apiHost(c), shortID)
+	entry, err := u.UrlService.ResolveShortUrl(c.Request().Context(), key)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "URL not found"})
+		return err
+	}
+	if entry.Protected && !middleware.IsUnlocked(c, shortID, entry.Id, entry.PasswordVer) {
+		return passwordChallenge(c, shortID, false)
+	}
+	if err := u.consumeClick(c, key, entry); err != nil {
+		return err
 	}
 
+	destination, variant := u.destination(c, shortID, entry)
+
+	// SPA clients perform the redirect themselves, so the lookup counts as the click
+	u.recordClick(c, key, entry, variant)
+
 	return c.JSON(http.StatusOK, map[string]string{
-		"originalUrl": originalURL,
+		"originalUrl": destination,
+	})
+}
+
+// ShortLinkRedirectHandler answers GET /:shortId with a real HTTP redirect,
+// using the status code configured on the link. The link is looked up on the
+// domain the request was made to. Links in preview mode, and "/:shortId+",
+// show the preview page instead; its Continue button posts back here and is
+// answered with a 303 redirect.
+func (u *UrlHandler) ShortLinkRedirectHandler(c echo.Context) error {
+	shortID := c.Param("shortId")
+	if shortID == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	key := u.linkKey(c, requestHost(c), shortID)
+	continued := c.Request().Method == http.MethodPost
+	if c.Get(middleware.PreviewContextKey) == true && !continued {
+		return u.renderPreview(c, shortID, key)
+	}
+
+	entry, err := u.UrlService.ResolveShortUrl(c.Request().Context(), key)
+	if err != nil {
+		return err
+	}
+	// Unlocking comes after the preview, and lands back here with a GET
+	unlocked := entry.Protected && middleware.IsUnlocked(c, shortID, entry.Id, entry.PasswordVer)
+	if entry.Preview && !continued && !unlocked {
+		return u.renderPreview(c, shortID, key)
+	}
+	if entry.Protected && !unlocked {
+		return passwordChallenge(c, shortID, true)
+	}
+	if err := u.consumeClick(c, key, entry); err != nil {
+		return err
+	}
+
+	destination, variant := u.destination(c, shortID, entry)
+	u.recordClick(c, key, entry, variant)
+
+	if continued {
+		return c.Redirect(http.StatusSeeOther, destination)
+	}
+	return c.Redirect(entry.RedirectType, destination)
+}
+
+// destination applies the link's routing rules and A/B split to the visitor, and
+// returns the variant picked, if any. The link's query parameters, and the
+// request's own query string when forwarded, are merged into the result.
+func (u *UrlHandler) destination(c echo.Context, shortID string, entry *models.CachedUrl) (string, string) {
+	destination, variant := entry.OriginalUrl, ""
+	if len(entry.Rules) > 0 || len(entry.Variants) > 0 {
+		destination, variant = entry.Destination(u.visitor(c), func(variants []routing.Variant) int {
+			return pickVariant(c, shortID, entry.Sticky, variants)
+		})
+	}
+
+	vars := map[string]string{"short_id": shortID, "variant": variant}
+	return entry.WithQuery(destination, c.QueryString(), vars), variant
+}
+
+// linkKey returns the key of the link shortID on host, see UrlServices.LinkKey
+func (u *UrlHandler) linkKey(c echo.Context, host string, shortID string) string {
+	return u.UrlService.LinkKey(c.Request().Context(), host, shortID)
+}
+
+// workspaceParam reads the workspace_id query parameter of the link routes;
+// without one, uuid.Nil selects the user's personal workspace
+func workspaceParam(c echo.Context) (uuid.UUID, error) {
+	v := c.QueryParam("workspace_id")
+	if v == "" {
+		return uuid.Nil, nil
+	}
+	workspaceId, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.Nil, models.ErrBadRequest.WithMessage("Invalid workspace UUID format")
+	}
+	return workspaceId, nil
+}
+
+// requestHost is the host name the request was made to, without a port
+func requestHost(c echo.Context) string {
+	host := c.Request().Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
+
+// apiHost is the domain API clients ask about in the domain query parameter,
+// e.g. an app on the canonical domain resolving a link on a custom domain,
+// and otherwise the host of the request
+func apiHost(c echo.Context) string {
+	if domain := c.QueryParam("domain"); domain != "" {
+		return domain
+	}
+	return requestHost(c)
+}
+
+// visitor describes the client making the request, for routing rules
+func (u *UrlHandler) visitor(c echo.Context) routing.Visitor {
+	req := c.Request()
+	return routing.NewVisitor(req.UserAgent(), req.Header.Get("Accept-Language"), c.RealIP(), u.Countries)
+}
+
+// pickVariant draws a variant by weight. On sticky links a visitor whose cookie
+// names a variant keeps it, and a fresh pick is remembered in the cookie.
+func pickVariant(c echo.Context, shortID string, sticky bool, variants []routing.Variant) int {
+	cookieName := variantCookiePrefix + shortID
+	if sticky {
+		if cookie, err := c.Cookie(cookieName); err == nil {
+			if i := routing.FindVariant(variants, cookie.Value); i >= 0 && variants[i].Weight > 0 {
+				return i
+			}
+		}
+	}
+
+	total := routing.TotalWeight(variants)
+	if total == 0 {
+		return -1
+	}
+	i := routing.PickVariant(variants, rand.IntN(total))
+	if sticky && i >= 0 {
+		c.SetCookie(&http.Cookie{
+			Name:     cookieName,
+			Value:    variants[i].Name,
+			Path:     "/",
+			MaxAge:   int(variantCookieTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   c.IsTLS(),
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return i
+}
+
+// consumeClick counts a visit against the limit of a click-limited link
+func (u *UrlHandler) consumeClick(c echo.Context, key string, entry *models.CachedUrl) error {
+	if entry.MaxClicks == 0 {
+		return nil
+	}
+	return u.UrlService.ConsumeClick(c.Request().Context(), key, entry)
+}
+
+// recordClick hands the click on the link key to the analytics pipeline; it never
+// blocks the redirect.
+func (u *UrlHandler) recordClick(c echo.Context, key string, entry *models.CachedUrl, variant string) {
+	req := c.Request()
+	u.ClickRecorder.Record(models.ClickEvent{
+		UrlId:          entry.Id,
+		ShortUrl:       key,
+		ClickedAt:      time.Now(),
+		Referrer:       req.Referer(),
+		UserAgent:      req.UserAgent(),
+		AcceptLanguage: req.Header.Get("Accept-Language"),
+		ClientIP:       c.RealIP(),
+		Variant:        variant,
 	})
 }
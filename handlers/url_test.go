@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"U-235/middleware"
+	"U-235/models"
+	"U-235/services"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeUrlService serves links from memory by link key; every host serves the
+// default domains
+type fakeUrlService struct {
+	services.UrlServices
+
+	entries map[string]*models.CachedUrl
+}
+
+func (f *fakeUrlService) LinkKey(ctx context.Context, host string, slug string) string {
+	return slug
+}
+
+func (f *fakeUrlService) ResolveShortUrl(ctx context.Context, key string) (*models.CachedUrl, error) {
+	entry, ok := f.entries[key]
+	if !ok {
+		return nil, models.ErrUrlNotFound
+	}
+	shared := *entry
+	return &shared, nil
+}
+
+func (f *fakeUrlService) PreviewShortUrl(ctx context.Context, key string) (*models.UrlPreview, error) {
+	entry, err := f.ResolveShortUrl(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UrlPreview{Entry: entry, CreatedAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), OwnerName: "Ada"}, nil
+}
+
+func (f *fakeUrlService) ConsumeClick(ctx context.Context, key string, entry *models.CachedUrl) error {
+	return nil
+}
+
+// fakeClickRecorder keeps recorded clicks in memory
+type fakeClickRecorder struct {
+	clicks []models.ClickEvent
+}
+
+func (f *fakeClickRecorder) Record(event models.ClickEvent) {
+	f.clicks = append(f.clicks, event)
+}
+
+func (f *fakeClickRecorder) Close() {}
+
+func newTestUrlHandler(entries map[string]*models.CachedUrl) (*UrlHandler, *fakeClickRecorder) {
+	recorder := &fakeClickRecorder{}
+	return &UrlHandler{UrlService: &fakeUrlService{entries: entries}, ClickRecorder: recorder}, recorder
+}
+
+// serveShortLink runs a request for /:shortId through the handler and its
+// short link middleware
+func serveShortLink(h *UrlHandler, req *http.Request) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/:shortId")
+	c.SetParamNames("shortId")
+	c.SetParamValues(req.URL.Path[1:])
+
+	handler := middleware.NoStore(h.ShortLinkRedirectHandler)
+	if req.Method == http.MethodGet {
+		handler = middleware.NoStore(middleware.PreviewSuffix(h.ShortLinkRedirectHandler))
+	}
+	return rec, handler(c)
+}
+
+func TestShortLinkRedirectHandler(t *testing.T) {
+	entries := map[string]*models.CachedUrl{
+		"perm123": {Id: uuid.New(), OriginalUrl: "https://example.com/moved", RedirectType: http.StatusMovedPermanently},
+		"temp123": {Id: uuid.New(), OriginalUrl: "https://example.com/temp", RedirectType: http.StatusFound},
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		wantStatus   int
+		wantLocation string
+		wantErr      error
+	}{
+		{name: "permanent", method: http.MethodGet, path: "/perm123", wantStatus: http.StatusMovedPermanently, wantLocation: "https://example.com/moved"},
+		{name: "temporary", method: http.MethodGet, path: "/temp123", wantStatus: http.StatusFound, wantLocation: "https://example.com/temp"},
+		{name: "query string is not forwarded", method: http.MethodGet, path: "/temp123?ref=mail", wantStatus: http.StatusFound, wantLocation: "https://example.com/temp"},
+		{name: "continue from the preview page", method: http.MethodPost, path: "/perm123", wantStatus: http.StatusSeeOther, wantLocation: "https://example.com/moved"},
+		{name: "unknown link", method: http.MethodGet, path: "/missing", wantErr: models.ErrUrlNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, recorder := newTestUrlHandler(entries)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+
+			rec, err := serveShortLink(h, req)
+			if got := rec.Header().Get("Cache-Control"); got != "private, no-store" {
+				t.Errorf("Cache-Control = %q, want private, no-store", got)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ShortLinkRedirectHandler() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(recorder.clicks) != 0 {
+					t.Errorf("clicks recorded for a failed redirect: %+v", recorder.clicks)
+				}
+				return
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get(echo.HeaderLocation); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+			if len(recorder.clicks) != 1 || recorder.clicks[0].ShortUrl != req.URL.Path[1:] {
+				t.Errorf("clicks recorded = %+v, want one for %s", recorder.clicks, req.URL.Path[1:])
+			}
+		})
+	}
+}
@@ -1,19 +1,21 @@
 package handlers
 
 import (
+	"U-235/middleware"
 	"U-235/models"
 	"U-235/services"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"net/http"
-	"strings"
 )
 
 type UserHandlers interface {
 	UserRegistrationHandler(c echo.Context) error
 	UserLoginHandler(c echo.Context) error
 	UserProfileHandler(c echo.Context) error
+	RefreshTokenHandler(c echo.Context) error
+	LogoutHandler(c echo.Context) error
 }
 
 type userHandler struct {
@@ -30,12 +32,12 @@ func (u *userHandler) UserRegistrationHandler(c echo.Context) error {
 	// Bind the request body to the UserRegister model
 	var userRegister models.UserRegister
 	if err := c.Bind(&userRegister); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+		return models.ErrBadRequest
 	}
 
 	// Validate the user input
 	if err := c.Validate(&userRegister); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request payload: %v", err))
+		return err
 	}
 
 	// Get the request context
@@ -44,13 +46,7 @@ func (u *userHandler) UserRegistrationHandler(c echo.Context) error {
 	// Call the service layer to register the user
 	registeredUser, err := u.UserService.UserRegistrationService(userRegister, ctx)
 	if err != nil {
-		// Check for specific errors to provide better responses
-		if strings.Contains(err.Error(), "already exists") {
-			return echo.NewHTTPError(http.StatusConflict, "User with this email already exists")
-		}
-		// Log the actual error for debugging
-		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to register user")
+		return err
 	}
 
 	// Create response object - we don't want to return the UserRegister object
@@ -71,24 +67,25 @@ func (u *userHandler) UserLoginHandler(c echo.Context) error {
 	err := c.Bind(&user)
 	if err != nil {
 		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+		return models.ErrBadRequest
 	}
 	err = c.Validate(user)
 	if err != nil {
-		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request payload: %v", err))
+		return err
 	}
 	ctx := c.Request().Context()
 	authResponse, err := u.UserService.UserLoginService(user, ctx)
 	if err != nil {
-		c.Logger().Error(err)
-		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		return err
 	}
 	return c.JSON(http.StatusOK, authResponse)
 }
 
 func (u *userHandler) UserProfileHandler(c echo.Context) error {
-	userId := c.Get("userID").(uuid.UUID)
+	userId, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
 	ctx := c.Request().Context()
 
 	profile, err := u.UserService.UserProfileService(userId, ctx)
@@ -97,3 +94,35 @@ func (u *userHandler) UserProfileHandler(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, profile)
 }
+
+func (u *userHandler) RefreshTokenHandler(c echo.Context) error {
+	var req models.RefreshTokenReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	tokens, err := u.UserService.RefreshTokenService(req.RefreshToken, c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (u *userHandler) LogoutHandler(c echo.Context) error {
+	var req models.LogoutReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+
+	claims, _ := c.Get("tokenClaims").(*middleware.TokenClaims)
+
+	if err := u.UserService.LogoutService(claims, req.RefreshToken, c.Request().Context()); err != nil {
+		return fmt.Errorf("failed to log out: %w", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "successfully logged out",
+	})
+}
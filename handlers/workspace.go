@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"U-235/models"
+	"U-235/services"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type WorkspaceHandlers interface {
+	CreateWorkspaceHandler(c echo.Context) error
+	ListWorkspacesHandler(c echo.Context) error
+	ListMembersHandler(c echo.Context) error
+	RemoveMemberHandler(c echo.Context) error
+	CreateInvitationHandler(c echo.Context) error
+	ListInvitationsHandler(c echo.Context) error
+	RevokeInvitationHandler(c echo.Context) error
+	AcceptInvitationHandler(c echo.Context) error
+}
+
+type WorkspaceHandler struct {
+	WorkspaceService services.WorkspaceServices
+}
+
+func NewWorkspaceHandler(WorkspaceService services.WorkspaceServices) WorkspaceHandlers {
+	return &WorkspaceHandler{
+		WorkspaceService: WorkspaceService,
+	}
+}
+
+// CreateWorkspaceHandler creates a shared workspace owned by the caller
+func (w *WorkspaceHandler) CreateWorkspaceHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	var req models.CreateWorkspaceReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	workspace, err := w.WorkspaceService.CreateWorkspace(c.Request().Context(), userID, &req)
+	if err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return c.JSON(http.StatusCreated, workspace)
+}
+
+func (w *WorkspaceHandler) ListWorkspacesHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	list, err := w.WorkspaceService.ListWorkspaces(c.Request().Context(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"workspaces": list,
+	})
+}
+
+func (w *WorkspaceHandler) ListMembersHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	workspaceId, err := uuid.Parse(c.Param("workspaceId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid workspace UUID format")
+	}
+
+	members, err := w.WorkspaceService.ListMembers(c.Request().Context(), userID, workspaceId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"members": members,
+	})
+}
+
+// RemoveMemberHandler removes a member; members remove themselves to leave
+func (w *WorkspaceHandler) RemoveMemberHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	workspaceId, err := uuid.Parse(c.Param("workspaceId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid workspace UUID format")
+	}
+	memberId, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid user UUID format")
+	}
+
+	if err := w.WorkspaceService.RemoveMember(c.Request().Context(), userID, workspaceId, memberId); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "successfully removed member",
+	})
+}
+
+// CreateInvitationHandler invites an email address; the response holds the only
+// copy of the invitation token
+func (w *WorkspaceHandler) CreateInvitationHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	workspaceId, err := uuid.Parse(c.Param("workspaceId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid workspace UUID format")
+	}
+
+	var req models.CreateInvitationReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	res, err := w.WorkspaceService.CreateInvitation(c.Request().Context(), userID, workspaceId, &req)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+func (w *WorkspaceHandler) ListInvitationsHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	workspaceId, err := uuid.Parse(c.Param("workspaceId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid workspace UUID format")
+	}
+
+	invitations, err := w.WorkspaceService.ListInvitations(c.Request().Context(), userID, workspaceId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"invitations": invitations,
+	})
+}
+
+func (w *WorkspaceHandler) RevokeInvitationHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	workspaceId, err := uuid.Parse(c.Param("workspaceId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid workspace UUID format")
+	}
+	invitationId, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		return models.ErrBadRequest.WithMessage("Invalid invitation UUID format")
+	}
+
+	if err := w.WorkspaceService.RevokeInvitation(c.Request().Context(), userID, workspaceId, invitationId); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "successfully revoked invitation",
+	})
+}
+
+// AcceptInvitationHandler joins the workspace an invitation token was issued for
+func (w *WorkspaceHandler) AcceptInvitationHandler(c echo.Context) error {
+	userID, ok := c.Get("userID").(uuid.UUID)
+	if !ok {
+		return models.ErrUnauthorized
+	}
+
+	var req models.AcceptInvitationReq
+	if err := c.Bind(&req); err != nil {
+		return models.ErrBadRequest
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	workspace, err := w.WorkspaceService.AcceptInvitation(c.Request().Context(), userID, req.Token)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, workspace)
+}
@@ -1,14 +1,24 @@
 package server
 
 import (
+	"U-235/core"
+	"U-235/core/domains"
+	"U-235/core/links"
+	"U-235/core/policy"
+	"U-235/core/routing"
 	"U-235/handlers"
 	"U-235/internal/database"
+	"U-235/models"
 	"U-235/repositories"
 	"U-235/services"
 	"U-235/utils"
 	"context"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	CustomMiddleware "U-235/middleware"
 	"github.com/labstack/echo/v4"
@@ -22,17 +32,92 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	//Dependencies Initialization
 	e.Validator = utils.NewValidator()
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	// Client IPs key rate limits and country routing, so forwarding headers are
+	// only believed when they come from a configured proxy
+	ipExtractor, err := loadIPExtractor(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	e.IPExtractor = ipExtractor
 	db := database.NewPsqlDB()
 	gormDB := database.NewGormPostgresDB()
 	redisDB, _ := database.NewRedisDatabase()
 	userRepo := repositories.NewUserRepo(db)
-	userService := services.NewUserService(userRepo)
+	refreshTokenRepo := repositories.NewRefreshTokenPsql(db)
+	revocationRepo := repositories.NewTokenRevocationRedis(redisDB)
+	refreshTokenTTL := utils.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	userService := services.NewUserService(userRepo, refreshTokenRepo, revocationRepo, refreshTokenTTL)
 	userHandler := handlers.NewUserHandler(userService)
 
+	apiKeyRepo := repositories.NewApiKeyPsql(db)
+	apiKeyService := services.NewApiKeyService(apiKeyRepo)
+	apiKeyHandler := handlers.NewApiKeyHandler(apiKeyService)
+
+	authMiddleware := CustomMiddleware.NewAuthMiddleware(revocationRepo, apiKeyService)
+
 	psqlRepo := repositories.NewUrlsPsql(db, gormDB)
 	redisRepo, _ := repositories.NewUrlRedis(redisDB)
-	urlService := services.NewShortUrlService(redisRepo, psqlRepo)
-	urlHandler := handlers.NewUrlHandler(urlService)
+	idGenerator, err := core.NewShortIDGenerator(os.Getenv("SHORT_ID_STRATEGY"), psqlRepo, os.Getenv("SHORT_ID_ALPHABET"))
+	if err != nil {
+		log.Fatalf("Invalid short ID configuration: %v", err)
+	}
+	idLength := utils.GetEnvInt("SHORT_ID_LENGTH", core.DefaultShortIDLength)
+	// The first DOMAIN is canonical; any others are served as aliases
+	shortLinks, err := links.NewBuilder(os.Getenv("DOMAIN"))
+	if err != nil {
+		log.Fatalf("Invalid DOMAIN: %v", err)
+	}
+
+	// Custom domains are verified with a DNS TXT record; verified ones serve their
+	// own links, routed on the Host header
+	domainRepo := repositories.NewDomainPsql(db)
+	verifiedDomains := services.NewVerifiedDomains(domainRepo, shortLinks,
+		utils.GetEnvDuration("VERIFIED_DOMAINS_TTL", time.Minute))
+	domainVerifier := domains.NewVerifier(net.DefaultResolver,
+		utils.GetEnvDuration("DOMAIN_VERIFY_TIMEOUT", 5*time.Second))
+	domainService := services.NewDomainService(domainRepo, domainVerifier, verifiedDomains, shortLinks)
+	domainHandler := handlers.NewDomainHandler(domainService)
+
+	// Links belong to workspaces; members act on them according to their role
+	workspaceRepo := repositories.NewWorkspacePsql(db)
+	workspaceService := services.NewWorkspaceService(workspaceRepo, userRepo,
+		utils.GetEnvDuration("WORKSPACE_INVITATION_TTL", 7*24*time.Hour))
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService)
+
+	urlService := services.NewShortUrlService(redisRepo, psqlRepo, idGenerator, idLength,
+		loadDestinationPolicy(shortLinks.Hosts(), verifiedDomains), shortLinks, verifiedDomains, workspaceService)
+
+	// Click analytics are batched in-process so redirects never wait on Postgres
+	clickRepo := repositories.NewClickPsql(db)
+	clickRecorder := services.NewBatchClickRecorder(clickRepo, os.Getenv("CLICK_IP_SALT"), services.BatchClickOptions{
+		BufferSize:    utils.GetEnvInt("CLICK_BUFFER_SIZE", 10000),
+		BatchSize:     utils.GetEnvInt("CLICK_BATCH_SIZE", 500),
+		FlushInterval: utils.GetEnvDuration("CLICK_FLUSH_INTERVAL", 2*time.Second),
+	})
+	s.onShutdown = append(s.onShutdown, clickRecorder.Close)
+
+	// Country rules only match when a GeoIP database is configured
+	var countries routing.CountryLookup
+	if path := os.Getenv("GEOIP_DB_PATH"); path != "" {
+		countryDB, err := routing.LoadCountryDB(path)
+		if err != nil {
+			log.Fatalf("Invalid GeoIP database: %v", err)
+		}
+		log.Printf("Loaded GeoIP database with %d ranges", countryDB.Len())
+		countries = countryDB
+	}
+
+	urlHandler := handlers.NewUrlHandler(urlService, clickRecorder,
+		utils.GetEnvInt("BULK_MAX_ITEMS", 1000), utils.GetEnvInt("IMPORT_MAX_ROWS", 10000), countries)
+
+	analyticsService := services.NewAnalyticsService(psqlRepo, clickRepo, workspaceService)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
+
+	// Platform operators; admin actions are audited
+	adminRepo := repositories.NewAdminPsql(db, gormDB)
+	adminService := services.NewAdminService(adminRepo, userRepo, redisRepo, revocationRepo, CustomMiddleware.TokenExpiration, shortLinks)
+	adminHandler := handlers.NewAdminHandler(adminService)
 
 	// Add expiration service initialization
 	expirationService := services.NewRedisExpirationService(redisDB, psqlRepo)
@@ -45,7 +130,14 @@ func (s *Server) RegisterRoutes() http.Handler {
 		expirationService.StartExpirationListener(ctx)
 	}
 
+	// Scheduled links are pushed into Redis once their activation time has passed
+	activationScheduler := services.NewUrlActivationScheduler(redisRepo, psqlRepo,
+		utils.GetEnvDuration("ACTIVATION_POLL_INTERVAL", 30*time.Second))
+	activationScheduler.Start(ctx)
+	s.onShutdown = append(s.onShutdown, activationScheduler.Stop)
+
 	// Global middleware
+	e.Use(middleware.RequestID())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
@@ -65,39 +157,204 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Global API config - All API routes under /api
 	api := e.Group("/api")
 
+	// Rate limits are shared across instances through Redis and configured per route group
+	rateLimiter := repositories.NewRateLimitRedis(redisDB)
+	loginIPLimit := CustomMiddleware.NewRateLimit(rateLimiter,
+		loadRateLimitPolicy("login_ip", "RATE_LIMIT_LOGIN_IP", "20/1m"), CustomMiddleware.RateLimitByIP)
+	loginEmailLimit := CustomMiddleware.NewRateLimit(rateLimiter,
+		loadRateLimitPolicy("login_email", "RATE_LIMIT_LOGIN_EMAIL", "5/1m"), CustomMiddleware.RateLimitByJSONField("email"))
+	createUrlLimit := CustomMiddleware.NewRateLimit(rateLimiter,
+		loadRateLimitPolicy("create_url", "RATE_LIMIT_CREATE_URL", "60/1m"), CustomMiddleware.RateLimitByUser)
+	redirectLimit := CustomMiddleware.NewRateLimit(rateLimiter,
+		loadRateLimitPolicy("redirect", "RATE_LIMIT_REDIRECT", "300/1m"), CustomMiddleware.RateLimitByIP)
+	unlockLimit := CustomMiddleware.NewRateLimit(rateLimiter,
+		loadRateLimitPolicy("unlock", "RATE_LIMIT_UNLOCK", "10/1m"), CustomMiddleware.RateLimitByIPAndParam("shortId"))
+
+	// API key scopes; JWT sessions pass all of them
+	readScope := CustomMiddleware.RequireScope(models.ScopeRead)
+	writeScope := CustomMiddleware.RequireScope(models.ScopeWrite)
+	deleteScope := CustomMiddleware.RequireScope(models.ScopeDelete)
+
 	// Authentication routes
 	{
 		auth := api.Group("/auth")
 		auth.POST("/register", userHandler.UserRegistrationHandler)
-		auth.POST("/login", userHandler.UserLoginHandler)
+		auth.POST("/login", userHandler.UserLoginHandler, loginIPLimit, loginEmailLimit)
+		auth.POST("/refresh", userHandler.RefreshTokenHandler)
+		auth.POST("/logout", userHandler.LogoutHandler, authMiddleware)
 		// auth.POST("/forgot-password", userHandler.ForgotPasswordHandler)
 	}
 
 	// URL Management Routes (authenticated)
 	{
 		urlRoutes := api.Group("/urls")
-		urlRoutes.Use(CustomMiddleware.AuthMiddleware)
-		urlRoutes.GET("", urlHandler.GetUrlHandler)
-		urlRoutes.POST("", urlHandler.CreateUrlHandler)
-		urlRoutes.DELETE("/:urlId", urlHandler.DeleteUrlHandler)
-		urlRoutes.POST("/expiry", urlHandler.ExtendExpiryHandler)
+		urlRoutes.Use(authMiddleware)
+		urlRoutes.GET("", urlHandler.GetUrlHandler, readScope)
+		urlRoutes.POST("", urlHandler.CreateUrlHandler, writeScope, createUrlLimit)
+		urlRoutes.POST("/bulk", urlHandler.BulkCreateUrlHandler, writeScope, createUrlLimit, middleware.BodyLimit("10M"))
+		urlRoutes.GET("/export", urlHandler.ExportUrlsHandler, readScope)
+		urlRoutes.POST("/import", urlHandler.ImportUrlsHandler, writeScope, createUrlLimit, middleware.BodyLimit("50M"))
+		urlRoutes.PATCH("/:urlId", urlHandler.UpdateUrlHandler, writeScope)
+		urlRoutes.DELETE("/:urlId", urlHandler.DeleteUrlHandler, deleteScope)
+		urlRoutes.POST("/expiry", urlHandler.ExtendExpiryHandler, writeScope)
+		urlRoutes.GET("/:urlId/stats", analyticsHandler.UrlStatsHandler, readScope)
+		urlRoutes.GET("/:urlId/qr", urlHandler.UrlQrCodeHandler, readScope)
+	}
+
+	// API Key Management Routes (session only - a key cannot manage keys)
+	{
+		keyRoutes := api.Group("/keys")
+		keyRoutes.Use(authMiddleware, CustomMiddleware.RequireSession)
+		keyRoutes.GET("", apiKeyHandler.ListApiKeysHandler)
+		keyRoutes.POST("", apiKeyHandler.CreateApiKeyHandler)
+		keyRoutes.PATCH("/:keyId", apiKeyHandler.RenameApiKeyHandler)
+		keyRoutes.DELETE("/:keyId", apiKeyHandler.RevokeApiKeyHandler)
+	}
+
+	// Custom Domain Routes (authenticated)
+	{
+		domainRoutes := api.Group("/domains")
+		domainRoutes.Use(authMiddleware)
+		domainRoutes.GET("", domainHandler.ListDomainsHandler, readScope)
+		domainRoutes.POST("", domainHandler.AddDomainHandler, writeScope)
+		domainRoutes.POST("/:domainId/verify", domainHandler.VerifyDomainHandler, writeScope)
+		domainRoutes.DELETE("/:domainId", domainHandler.DeleteDomainHandler, deleteScope)
+	}
+
+	// Workspace Routes (authenticated)
+	{
+		workspaceRoutes := api.Group("/workspaces")
+		workspaceRoutes.Use(authMiddleware)
+		workspaceRoutes.GET("", workspaceHandler.ListWorkspacesHandler, readScope)
+		workspaceRoutes.POST("", workspaceHandler.CreateWorkspaceHandler, writeScope)
+		workspaceRoutes.POST("/invitations/accept", workspaceHandler.AcceptInvitationHandler, writeScope)
+		workspaceRoutes.GET("/:workspaceId/members", workspaceHandler.ListMembersHandler, readScope)
+		workspaceRoutes.DELETE("/:workspaceId/members/:userId", workspaceHandler.RemoveMemberHandler, deleteScope)
+		workspaceRoutes.GET("/:workspaceId/invitations", workspaceHandler.ListInvitationsHandler, readScope)
+		workspaceRoutes.POST("/:workspaceId/invitations", workspaceHandler.CreateInvitationHandler, writeScope)
+		workspaceRoutes.DELETE("/:workspaceId/invitations/:invitationId", workspaceHandler.RevokeInvitationHandler, deleteScope)
+	}
+
+	// Admin Routes (session only, access tokens with the admin role claim)
+	{
+		adminRoutes := api.Group("/admin")
+		adminRoutes.Use(authMiddleware, CustomMiddleware.RequireSession, CustomMiddleware.RequireRole(models.RoleAdmin))
+		adminRoutes.GET("/users", adminHandler.ListUsersHandler)
+		adminRoutes.POST("/users/:userId/suspend", adminHandler.SuspendUserHandler)
+		adminRoutes.GET("/urls", adminHandler.ListUrlsHandler)
+		adminRoutes.POST("/urls/:urlId/disable", adminHandler.DisableUrlHandler)
+		adminRoutes.GET("/stats", adminHandler.StatsHandler)
+		adminRoutes.GET("/audit", adminHandler.AuditLogHandler)
 	}
 
 	// User Profile Routes (authenticated)
 	{
 		userRoutes := api.Group("/user")
-		userRoutes.Use(CustomMiddleware.AuthMiddleware)
-		userRoutes.GET("/profile", userHandler.UserProfileHandler)
+		userRoutes.Use(authMiddleware)
+		userRoutes.GET("/profile", userHandler.UserProfileHandler, readScope)
 	}
 
+	// JSON lookup for SPA clients that perform the redirect themselves
 	api.GET("/redirect/:shortId", urlHandler.RedirectHandler,
+		CustomMiddleware.NoStore,
+		redirectLimit,
+		CustomMiddleware.ValidateShortId,
+	)
+	// Password check for protected links; sets the unlock cookie on success
+	api.POST("/redirect/:shortId/unlock", urlHandler.UnlockUrlHandler,
+		unlockLimit,
+		CustomMiddleware.ValidateShortId,
+	)
+
+	// Root-level short links - registered last so static routes above take precedence
+	e.GET("/:shortId", urlHandler.ShortLinkRedirectHandler,
+		CustomMiddleware.NoStore,
+		redirectLimit,
+		CustomMiddleware.PreviewSuffix,
+		CustomMiddleware.ValidateShortId,
+	)
+	// Continue button of the preview page; answered with a 303 redirect
+	e.POST("/:shortId", urlHandler.ShortLinkRedirectHandler,
+		CustomMiddleware.NoStore,
+		redirectLimit,
 		CustomMiddleware.ValidateShortId,
-		CustomMiddleware.UrlCache,
 	)
 
 	return e
 }
 
+// loadDestinationPolicy configures which destinations short links may redirect to.
+// selfHosts, the DOMAIN hosts, and customHosts, the verified custom domains, are
+// always treated as our own, so links to them are rejected.
+func loadDestinationPolicy(selfHosts []string, customHosts policy.HostSet) policy.DestinationPolicy {
+	opts := policy.Options{
+		AllowPrivate: utils.GetEnvBool("DESTINATION_ALLOW_PRIVATE", false),
+		CustomHosts:  customHosts,
+	}
+	if schemes := os.Getenv("DESTINATION_SCHEMES"); schemes != "" {
+		opts.Schemes = strings.Split(strings.ToLower(strings.ReplaceAll(schemes, " ", "")), ",")
+	}
+
+	opts.SelfHosts = append(selfHosts, strings.Split(os.Getenv("DESTINATION_SELF_HOSTS"), ",")...)
+
+	if path := os.Getenv("DESTINATION_BLOCKLIST_PATH"); path != "" {
+		blocklist, err := policy.LoadBlocklist(path)
+		if err != nil {
+			log.Fatalf("Invalid DESTINATION_BLOCKLIST_PATH: %v", err)
+		}
+		log.Printf("Loaded destination blocklist with %d domains", blocklist.Len())
+		opts.Blocklist = blocklist
+	}
+	return policy.New(opts)
+}
+
+// loadIPExtractor decides where c.RealIP() comes from. Without trusted proxies it is
+// the address of the connection and X-Forwarded-For / X-Real-IP are ignored. With a
+// comma separated list of proxy IPs or CIDR ranges, X-Forwarded-For is read from
+// the right, skipping those proxies, so entries a client prepends are never used.
+func loadIPExtractor(proxies string) (echo.IPExtractor, error) {
+	var options []echo.TrustOption
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	if len(options) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	// Only the configured proxies are trusted, not every private address
+	options = append(options, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// loadRateLimitPolicy reads a "<limit>/<window>" spec from the environment, falling back to def
+func loadRateLimitPolicy(name, envKey, def string) models.RateLimitPolicy {
+	spec := os.Getenv(envKey)
+	if spec == "" {
+		spec = def
+	}
+
+	policy, err := models.ParseRateLimitPolicy(name, spec)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", envKey, err)
+	}
+	return policy
+}
+
 func (s *Server) HelloWorldHandler(c echo.Context) error {
 	resp := map[string]string{
 		"message": "Hello World",
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"U-235/middleware"
 	"encoding/json"
 	"github.com/labstack/echo/v4"
 	"net/http"
@@ -37,3 +38,79 @@ func TestHandler(t *testing.T) {
 		return
 	}
 }
+
+func TestLoadIPExtractor(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "direct ignores forwarded for",
+			remoteAddr: "203.0.113.9:4000",
+			headers:    map[string]string{echo.HeaderXForwardedFor: "1.2.3.4"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "direct ignores real ip",
+			remoteAddr: "203.0.113.9:4000",
+			headers:    map[string]string{echo.HeaderXRealIP: "1.2.3.4"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "untrusted peer cannot forward",
+			proxies:    "10.0.0.0/8",
+			remoteAddr: "203.0.113.9:4000",
+			headers:    map[string]string{echo.HeaderXForwardedFor: "1.2.3.4"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "trusted proxy forwards client",
+			proxies:    "10.0.0.0/8",
+			remoteAddr: "10.0.0.1:4000",
+			headers:    map[string]string{echo.HeaderXForwardedFor: "203.0.113.9"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "spoofed entries before the proxy are skipped",
+			proxies:    "10.0.0.1, 10.0.0.2",
+			remoteAddr: "10.0.0.1:4000",
+			headers:    map[string]string{echo.HeaderXForwardedFor: "1.2.3.4, 203.0.113.9, 10.0.0.2"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "private addresses are not trusted by default",
+			proxies:    "10.0.0.1",
+			remoteAddr: "10.0.0.1:4000",
+			headers:    map[string]string{echo.HeaderXForwardedFor: "1.2.3.4, 192.168.1.5"},
+			want:       "192.168.1.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor, err := loadIPExtractor(tt.proxies)
+			if err != nil {
+				t.Fatalf("loadIPExtractor(%q) error = %v", tt.proxies, err)
+			}
+			e := echo.New()
+			e.IPExtractor = extractor
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			c := e.NewContext(req, httptest.NewRecorder())
+			if got := middleware.RateLimitByIP(c); got != tt.want {
+				t.Errorf("RateLimitByIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIPExtractorInvalid(t *testing.T) {
+	if _, err := loadIPExtractor("10.0.0.0/8, not-an-ip"); err == nil {
+		t.Error("loadIPExtractor() expected an error for an invalid range")
+	}
+}
@@ -16,9 +16,15 @@ type Server struct {
 	port int
 
 	db database.Service
+
+	// onShutdown holds cleanup hooks registered while wiring routes
+	onShutdown []func()
 }
 
-func NewServer() *http.Server {
+// NewServer wires the HTTP server. The returned cleanup function runs the
+// shutdown hooks, such as the final click flush, and must be called once the
+// server has shut down and no handler is running any more.
+func NewServer() (*http.Server, func()) {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	NewServer := &Server{
 		port: port,
@@ -35,5 +41,11 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	return server
+	cleanup := func() {
+		for _, hook := range NewServer.onShutdown {
+			hook()
+		}
+	}
+
+	return server, cleanup
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"U-235/models"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -15,8 +17,9 @@ import (
 
 // JWT Configuration
 var (
-	jwtSecret       []byte
-	TokenExpiration = 72 * time.Hour
+	jwtSecret []byte
+	// TokenExpiration is the access token lifetime; sessions are extended with refresh tokens
+	TokenExpiration = 15 * time.Minute
 )
 
 func init() {
@@ -25,8 +28,33 @@ func init() {
 		panic("JWT_SECRET environment variable not set")
 	}
 	jwtSecret = []byte(secret)
+
+	if ttl := os.Getenv("ACCESS_TOKEN_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			panic("ACCESS_TOKEN_TTL must be a positive duration such as 15m")
+		}
+		TokenExpiration = d
+	}
+}
+
+// TokenRevocationList reports whether an access token has been revoked, by its jti
+// or together with all tokens of its user
+type TokenRevocationList interface {
+	IsRevoked(ctx context.Context, jti string, userID uuid.UUID) (bool, error)
 }
 
+// ApiKeyAuthenticator resolves a personal API key to its owner and scopes
+type ApiKeyAuthenticator interface {
+	AuthenticateApiKey(ctx context.Context, key string) (uuid.UUID, []string, error)
+}
+
+// Values stored under the "authMethod" context key
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodApiKey = "api_key"
+)
+
 // Custom errors
 var (
 	ErrInvalidToken         = errors.New("invalid authentication credentials")
@@ -34,11 +62,17 @@ var (
 	ErrMissingToken         = errors.New("missing authentication credentials")
 	ErrInvalidSigningMethod = errors.New("unexpected token signing method")
 	ErrInvalidClaims        = errors.New("invalid token claims")
+	ErrRevokedToken         = errors.New("authentication credentials revoked")
+	ErrInvalidApiKey        = errors.New("invalid API key")
+	ErrInsufficientScope    = errors.New("API key lacks the required scope")
+	ErrSessionRequired      = errors.New("this endpoint requires a user session")
+	ErrInsufficientRole     = errors.New("your role does not allow this endpoint")
 )
 
 // TokenClaims represents the JWT claims structure
 type TokenClaims struct {
 	UserID uuid.UUID `json:"userId"`
+	Role   string    `json:"role,omitempty"` // Platform role, see models.RoleAdmin
 	jwt.RegisteredClaims
 }
 
@@ -74,15 +108,17 @@ func ValidateToken(tokenString string) (*TokenClaims, error) {
 	return claims, nil
 }
 
-// CreateToken generates a new JWT token
-func CreateToken(userID uuid.UUID) (string, error) {
+// CreateToken generates a new JWT token for a user with the given platform role
+func CreateToken(userID uuid.UUID, role string) (string, error) {
 	if userID == uuid.Nil {
 		return "", errors.New("cannot create token without valid user ID")
 	}
 
 	claims := &TokenClaims{
 		UserID: userID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -94,63 +130,127 @@ func CreateToken(userID uuid.UUID) (string, error) {
 	return token.SignedString(jwtSecret)
 }
 
-// AuthMiddleware validates JWT tokens in incoming requests
-func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+// NewAuthMiddleware validates JWT tokens or personal API keys (u235_...) in
+// incoming requests and rejects tokens that are on the revocation list, by their
+// jti or their user.
+// Either way the owner ends up in the "userID" context key.
+func NewAuthMiddleware(revocations TokenRevocationList, apiKeys ApiKeyAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return authenticate(next, revocations, apiKeys)
+	}
+}
+
+func authenticate(next echo.HandlerFunc, revocations TokenRevocationList, apiKeys ApiKeyAuthenticator) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		authHeader := c.Request().Header.Get("Authorization")
 
 		// Validate header format
 		if authHeader == "" {
 			c.Response().Header().Set("WWW-Authenticate", `Bearer realm="Restricted"`)
-			return c.JSON(http.StatusUnauthorized, echo.Map{
-				"error":   ErrMissingToken.Error(),
-				"code":    "missing_auth_header",
-				"details": "Authorization header required with 'Bearer <token>' format",
-			})
+			return models.NewAppError("MISSING_AUTH_HEADER", ErrMissingToken.Error(), http.StatusUnauthorized).WithDetails("Authorization header required with 'Bearer <token>' format")
 		}
 
 		if !strings.HasPrefix(authHeader, "Bearer ") {
 			c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
-			return c.JSON(http.StatusUnauthorized, echo.Map{
-				"error":   "Invalid authorization format",
-				"code":    "invalid_auth_header",
-				"details": "Expected format: 'Bearer <token>'",
-			})
+			return models.NewAppError("INVALID_AUTH_HEADER", "Invalid authorization format", http.StatusUnauthorized).WithDetails("Expected format: 'Bearer <token>'")
 		}
 
 		tokenStr := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
 		if tokenStr == "" {
 			c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
-			return c.JSON(http.StatusUnauthorized, echo.Map{
-				"error":   "Empty bearer token",
-				"code":    "empty_bearer_token",
-				"details": "Token cannot be empty",
-			})
+			return models.NewAppError("EMPTY_BEARER_TOKEN", "Empty bearer token", http.StatusUnauthorized).WithDetails("Token cannot be empty")
+		}
+
+		if strings.HasPrefix(tokenStr, models.ApiKeyPrefix) {
+			userID, scopes, err := apiKeys.AuthenticateApiKey(c.Request().Context(), tokenStr)
+			if err != nil {
+				c.Logger().Error(err)
+				c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				return models.NewAppError("INVALID_API_KEY", ErrInvalidApiKey.Error(), http.StatusUnauthorized).WithDetails("API key is unknown or has been revoked")
+			}
+
+			c.Set("userID", userID)
+			c.Set("apiKeyScopes", scopes)
+			c.Set("authMethod", AuthMethodApiKey)
+			return next(c)
 		}
 
 		// Validate token
 		claims, err := ValidateToken(tokenStr)
 		if err != nil {
-			status := http.StatusUnauthorized
-			errorCode := "invalid_token"
+			errorCode := "INVALID_TOKEN"
 			wwwAuthHeader := `Bearer error="invalid_token"`
 
 			if errors.Is(err, ErrExpiredToken) {
-				errorCode = "expired_token"
+				errorCode = "EXPIRED_TOKEN"
 				wwwAuthHeader = `Bearer error="invalid_token", error_description="Token expired"`
 			}
 
 			c.Response().Header().Set("WWW-Authenticate", wwwAuthHeader)
-			return c.JSON(status, echo.Map{
-				"error":   err.Error(),
-				"code":    errorCode,
-				"details": "Requires valid authentication credentials",
-			})
+			return models.NewAppError(errorCode, err.Error(), http.StatusUnauthorized).WithDetails("Requires valid authentication credentials")
+		}
+
+		// Tokens are short-lived, so a Redis outage fails open rather than locking everyone out
+		revoked, err := revocations.IsRevoked(c.Request().Context(), claims.ID, claims.UserID)
+		if err != nil {
+			c.Logger().Errorf("token revocation check failed: %v", err)
+		} else if revoked {
+			c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="Token revoked"`)
+			return models.NewAppError("REVOKED_TOKEN", ErrRevokedToken.Error(), http.StatusUnauthorized).WithDetails("Requires valid authentication credentials")
 		}
 
 		// Set user context
 		c.Set("userID", claims.UserID)
+		c.Set("tokenClaims", claims)
+		c.Set("authMethod", AuthMethodJWT)
 
 		return next(c)
 	}
 }
+
+// RequireScope restricts a route to API keys holding scope. JWT sessions carry
+// full access and always pass. Must run after the auth middleware.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Get("authMethod") != AuthMethodApiKey {
+				return next(c)
+			}
+
+			scopes, _ := c.Get("apiKeyScopes").([]string)
+			for _, s := range scopes {
+				if s == scope {
+					return next(c)
+				}
+			}
+
+			c.Response().Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+			return models.NewAppError("INSUFFICIENT_SCOPE", ErrInsufficientScope.Error(), http.StatusForbidden).WithDetails(fmt.Sprintf("Requires the '%s' scope", scope))
+		}
+	}
+}
+
+// RequireSession rejects API keys, e.g. so that a key cannot mint further keys.
+// Must run after the auth middleware.
+func RequireSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Get("authMethod") == AuthMethodApiKey {
+			return models.NewAppError("SESSION_REQUIRED", ErrSessionRequired.Error(), http.StatusForbidden).WithDetails("Log in and use an access token for this endpoint")
+		}
+		return next(c)
+	}
+}
+
+// RequireRole restricts a route to access tokens carrying the role claim. API keys
+// carry no role and are rejected. Must run after the auth middleware.
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := c.Get("tokenClaims").(*TokenClaims)
+			if !ok || claims.Role != role {
+				return models.NewAppError("INSUFFICIENT_ROLE", ErrInsufficientRole.Error(), http.StatusForbidden).WithDetails(fmt.Sprintf("Requires the '%s' role", role))
+			}
+			return next(c)
+		}
+	}
+}
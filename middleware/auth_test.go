@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"U-235/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeApiKeys knows API keys by their raw value
+type fakeApiKeys struct {
+	keys map[string][]string
+	user uuid.UUID
+}
+
+func (f *fakeApiKeys) AuthenticateApiKey(ctx context.Context, key string) (uuid.UUID, []string, error) {
+	scopes, ok := f.keys[key]
+	if !ok {
+		return uuid.Nil, nil, errors.New("unknown key")
+	}
+	return f.user, scopes, nil
+}
+
+// noRevocations revokes nothing
+type noRevocations struct{}
+
+func (noRevocations) IsRevoked(ctx context.Context, jti string, userID uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+func TestApiKeyAuth(t *testing.T) {
+	userID := uuid.New()
+	apiKeys := &fakeApiKeys{
+		user: userID,
+		keys: map[string][]string{
+			"u235_reader": {models.ScopeRead},
+			"u235_writer": {models.ScopeRead, models.ScopeWrite},
+		},
+	}
+	accessToken, err := CreateToken(userID, models.RoleUser)
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		route    []echo.MiddlewareFunc
+		wantCode string
+	}{
+		{name: "key with the scope", token: "u235_writer", route: []echo.MiddlewareFunc{RequireScope(models.ScopeWrite)}},
+		{name: "key without the scope", token: "u235_reader", route: []echo.MiddlewareFunc{RequireScope(models.ScopeWrite)}, wantCode: "INSUFFICIENT_SCOPE"},
+		{name: "unknown key", token: "u235_unknown", route: []echo.MiddlewareFunc{RequireScope(models.ScopeRead)}, wantCode: "INVALID_API_KEY"},
+		{name: "key on a session route", token: "u235_writer", route: []echo.MiddlewareFunc{RequireSession}, wantCode: "SESSION_REQUIRED"},
+		{name: "access token passes scope checks", token: accessToken, route: []echo.MiddlewareFunc{RequireScope(models.ScopeDelete), RequireSession}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(c echo.Context) error {
+				if c.Get("userID") != userID {
+					t.Errorf("userID = %v, want %v", c.Get("userID"), userID)
+				}
+				return c.NoContent(http.StatusNoContent)
+			}
+			for i := len(tt.route) - 1; i >= 0; i-- {
+				handler = tt.route[i](handler)
+			}
+			handler = NewAuthMiddleware(noRevocations{}, apiKeys)(handler)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/urls", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			err := handler(e.NewContext(req, httptest.NewRecorder()))
+
+			var appErr *models.AppError
+			switch {
+			case tt.wantCode == "" && err != nil:
+				t.Errorf("request error = %v, want success", err)
+			case tt.wantCode != "" && (!errors.As(err, &appErr) || appErr.Code != tt.wantCode):
+				t.Errorf("request error = %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
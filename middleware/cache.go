@@ -2,11 +2,12 @@ package middleware
 
 import "github.com/labstack/echo/v4"
 
-func UrlCache(next echo.HandlerFunc) echo.HandlerFunc {
+// NoStore keeps clients and CDNs from caching short link responses, errors
+// included. Every visit must reach the server to be counted and to see edits,
+// deletions, click limits and disabled links.
+func NoStore(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		if c.Path() == "/:shortId" {
-			c.Response().Header().Set("Cache-Control", "public, max-age=3600")
-		}
+		c.Response().Header().Set("Cache-Control", "private, no-store")
 		return next(c)
 	}
 }
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"U-235/models"
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLimiter allows the first limit requests per key within a test
+type fakeLimiter struct {
+	counts map[string]int
+	err    error
+}
+
+func (f *fakeLimiter) Allow(ctx context.Context, key string, policy models.RateLimitPolicy) (models.RateLimitResult, error) {
+	if f.err != nil {
+		return models.RateLimitResult{}, f.err
+	}
+	f.counts[key]++
+	used := f.counts[key]
+	return models.RateLimitResult{
+		Allowed:    used <= policy.Limit,
+		Limit:      policy.Limit,
+		Remaining:  max(policy.Limit-used, 0),
+		ResetAfter: 1500 * time.Millisecond,
+	}, nil
+}
+
+func TestNewRateLimit(t *testing.T) {
+	policy := models.RateLimitPolicy{Name: "test", Limit: 2, Window: time.Minute}
+	limiter := &fakeLimiter{counts: map[string]int{}}
+	handler := NewRateLimit(limiter, policy, RateLimitByIP)(func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		remoteAddr    string
+		wantAllowed   bool
+		wantRemaining string
+	}{
+		{"192.0.2.1:1000", true, "1"},
+		{"192.0.2.1:1001", true, "0"},
+		{"192.0.2.1:1002", false, "0"},
+		{"192.0.2.2:1000", true, "1"},
+	}
+	e := echo.New()
+	e.IPExtractor = echo.ExtractIPDirect()
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		err := handler(e.NewContext(req, rec))
+
+		if tt.wantAllowed && err != nil {
+			t.Fatalf("request %d: error = %v, want allowed", i, err)
+		}
+		if !tt.wantAllowed {
+			if !errors.Is(err, models.ErrRateLimited) {
+				t.Fatalf("request %d: error = %v, want %v", i, err, models.ErrRateLimited)
+			}
+			if got := rec.Header().Get("Retry-After"); got != "2" {
+				t.Errorf("request %d: Retry-After = %q, want %q", i, got, "2")
+			}
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i, got, tt.wantRemaining)
+		}
+		if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+			t.Errorf("request %d: RateLimit-Policy = %q, want %q", i, got, "2;w=60")
+		}
+	}
+}
+
+func TestNewRateLimitFailsOpen(t *testing.T) {
+	policy := models.RateLimitPolicy{Name: "test", Limit: 1, Window: time.Minute}
+	limiter := &fakeLimiter{err: errors.New("redis down")}
+	called := false
+	handler := NewRateLimit(limiter, policy, RateLimitByIP)(func(c echo.Context) error {
+		called = true
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := handler(echo.New().NewContext(req, httptest.NewRecorder())); err != nil || !called {
+		t.Errorf("handler error = %v, called = %t; want the request let through", err, called)
+	}
+}
+
+func TestNewRateLimitSkips(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  models.RateLimitPolicy
+		keyFunc RateLimitKeyFunc
+	}{
+		{"disabled policy", models.RateLimitPolicy{Name: "off"}, RateLimitByIP},
+		{"empty key", models.RateLimitPolicy{Name: "test", Limit: 1, Window: time.Minute}, RateLimitByUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &fakeLimiter{counts: map[string]int{}}
+			handler := NewRateLimit(limiter, tt.policy, tt.keyFunc)(func(c echo.Context) error {
+				return nil
+			})
+			for i := 0; i < 3; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if err := handler(echo.New().NewContext(req, httptest.NewRecorder())); err != nil {
+					t.Fatalf("request %d: error = %v", i, err)
+				}
+			}
+			if len(limiter.counts) != 0 {
+				t.Errorf("limiter was called: %v", limiter.counts)
+			}
+		})
+	}
+}
+
+func TestRateLimitByJSONField(t *testing.T) {
+	body := `{"email": " Someone@Example.com ", "password": "secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	key := RateLimitByJSONField("email")(c)
+	other := RateLimitByJSONField("email")(echo.New().NewContext(
+		httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email": "someone@example.com"}`)),
+		httptest.NewRecorder()))
+	if key == "" || key != other {
+		t.Errorf("keys = %q and %q, want the same non-empty key for one address", key, other)
+	}
+	if strings.Contains(key, "example") {
+		t.Errorf("key %q contains the address", key)
+	}
+
+	restored, err := io.ReadAll(c.Request().Body)
+	if err != nil || string(restored) != body {
+		t.Errorf("body after reading the key = %q, %v; want %q", restored, err, body)
+	}
+
+	missing := RateLimitByJSONField("email")(echo.New().NewContext(
+		httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "x"}`)),
+		httptest.NewRecorder()))
+	if missing != "" {
+		t.Errorf("key without the field = %q, want empty", missing)
+	}
+}
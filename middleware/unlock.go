@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// unlockAudience keeps unlock tokens from being accepted as access tokens and vice versa
+	unlockAudience = "link-unlock"
+	// UnlockCookiePrefix plus the short URL names the cookie holding a link's unlock token
+	UnlockCookiePrefix = "u235_unlock_"
+	// UnlockTokenHeader carries an unlock token for clients that do not keep cookies
+	UnlockTokenHeader = "X-Unlock-Token"
+)
+
+// UnlockTokenExpiration is how long a password-protected link stays unlocked
+var UnlockTokenExpiration = 30 * time.Minute
+
+var ErrInvalidUnlockToken = errors.New("invalid unlock token")
+
+func init() {
+	if ttl := os.Getenv("UNLOCK_TOKEN_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			panic("UNLOCK_TOKEN_TTL must be a positive duration such as 30m")
+		}
+		UnlockTokenExpiration = d
+	}
+}
+
+// UnlockClaims grant access to one protected link for as long as its password is
+// unchanged; PasswordVer is the utils.PasswordVersion of the password entered
+type UnlockClaims struct {
+	UrlID       uuid.UUID `json:"urlId"`
+	PasswordVer string    `json:"pv"`
+	jwt.RegisteredClaims
+}
+
+// CreateUnlockToken signs a token proving the visitor entered the password of urlID
+// whose version is passwordVer
+func CreateUnlockToken(urlID uuid.UUID, passwordVer string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(UnlockTokenExpiration)
+	claims := &UnlockClaims{
+		UrlID:       urlID,
+		PasswordVer: passwordVer,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{unlockAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	return token, expiresAt, err
+}
+
+// ValidateUnlockToken reports whether tokenString unlocks urlID while its password
+// version is passwordVer
+func ValidateUnlockToken(tokenString string, urlID uuid.UUID, passwordVer string) error {
+	token, err := jwt.ParseWithClaims(tokenString, &UnlockClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidSigningMethod
+		}
+		return jwtSecret, nil
+	}, jwt.WithAudience(unlockAudience), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return ErrInvalidUnlockToken
+	}
+
+	claims, ok := token.Claims.(*UnlockClaims)
+	if !ok || claims.UrlID != urlID || claims.PasswordVer != passwordVer {
+		return ErrInvalidUnlockToken
+	}
+	return nil
+}
+
+// IsUnlocked reports whether the request carries a valid unlock token for urlID and
+// its current password version, either in the link's cookie or in the
+// X-Unlock-Token header.
+func IsUnlocked(c echo.Context, shortID string, urlID uuid.UUID, passwordVer string) bool {
+	if token := c.Request().Header.Get(UnlockTokenHeader); token != "" && ValidateUnlockToken(token, urlID, passwordVer) == nil {
+		return true
+	}
+	cookie, err := c.Cookie(UnlockCookiePrefix + shortID)
+	return err == nil && ValidateUnlockToken(cookie.Value, urlID, passwordVer) == nil
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"U-235/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUnlockToken(t *testing.T) {
+	urlID := uuid.New()
+	version := utils.PasswordVersion("$2a$10$first")
+	token, _, err := CreateUnlockToken(urlID, version)
+	if err != nil {
+		t.Fatalf("CreateUnlockToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		urlID   uuid.UUID
+		version string
+		wantErr bool
+	}{
+		{"same link and password", urlID, version, false},
+		{"other link", uuid.New(), version, true},
+		{"password changed", urlID, utils.PasswordVersion("$2a$10$second"), true},
+		{"password removed", urlID, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUnlockToken(token, tt.urlID, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUnlockToken() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnlockTokenAudience(t *testing.T) {
+	userID := uuid.New()
+	accessToken, err := CreateToken(userID, "user")
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	if err := ValidateUnlockToken(accessToken, userID, ""); err == nil {
+		t.Error("ValidateUnlockToken() accepted an access token")
+	}
+
+	unlockToken, _, err := CreateUnlockToken(uuid.New(), "")
+	if err != nil {
+		t.Fatalf("CreateUnlockToken() error = %v", err)
+	}
+	if _, err := ValidateToken(unlockToken); err == nil {
+		t.Error("ValidateToken() accepted an unlock token")
+	}
+}
+
+func TestIsUnlocked(t *testing.T) {
+	urlID := uuid.New()
+	version := utils.PasswordVersion("$2a$10$first")
+	token, _, err := CreateUnlockToken(urlID, version)
+	if err != nil {
+		t.Fatalf("CreateUnlockToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		cookie *http.Cookie
+		want   bool
+	}{
+		{"no token", "", nil, false},
+		{"header", token, nil, true},
+		{"cookie", "", &http.Cookie{Name: UnlockCookiePrefix + "abc1234", Value: token}, true},
+		{"cookie of another short link", "", &http.Cookie{Name: UnlockCookiePrefix + "xyz9876", Value: token}, false},
+		{"garbage header", "not-a-token", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/abc1234", nil)
+			if tt.header != "" {
+				req.Header.Set(UnlockTokenHeader, tt.header)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			if got := IsUnlocked(c, "abc1234", urlID, version); got != tt.want {
+				t.Errorf("IsUnlocked() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,41 +1,39 @@
 package middleware
 
 import (
+	"U-235/core"
 	"github.com/labstack/echo/v4"
 	"net/http"
-	"regexp"
 	"strings"
 )
 
+// PreviewContextKey is set on requests for "/<shortId>+", which ask for the preview page of a link
+const PreviewContextKey = "preview"
+
+// PreviewSuffix strips the "+" of a preview request, so ValidateShortId and the
+// handler see the plain short ID, and marks the request with PreviewContextKey.
+func PreviewSuffix(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if shortId, ok := strings.CutSuffix(c.Param("shortId"), "+"); ok {
+			c.SetParamValues(shortId)
+			c.Set(PreviewContextKey, true)
+		}
+		return next(c)
+	}
+}
+
 // ValidateShortId Middleware to validate short ID and reject known system paths
 func ValidateShortId(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		shortId := c.Param("shortId")
 
 		// Reject known system paths that might conflict
-		reservedPaths := []string{
-			"api", "health", "favicon.ico", "robots.txt", "sitemap.xml",
-			"admin", "dashboard", "login", "register", "static", "assets",
-			"js", "css", "img", "images", "fonts", "docs", "help", "about",
-			"contact", "privacy", "terms", "www", "ftp", "mail", "blog",
-		}
-
-		lowerShortId := strings.ToLower(shortId)
-		for _, reserved := range reservedPaths {
-			if lowerShortId == reserved {
-				return echo.NewHTTPError(http.StatusNotFound, "Page not found")
-			}
-		}
-
-		// Validate short ID format (adjust based on your short ID generation)
-		// Example: 6-10 alphanumeric characters
-		if len(shortId) < 4 || len(shortId) > 12 {
-			return echo.NewHTTPError(http.StatusNotFound, "Invalid short URL")
+		if core.IsReservedPath(shortId) {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
 		}
 
-		// Only allow alphanumeric characters (adjust based on your encoding)
-		// Don't allow hyphens at start or end
-		if matched, _ := regexp.MatchString(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`, shortId); !matched {
+		// Generated and custom short IDs share one format, see core.IsValidShortIdFormat
+		if !core.IsValidShortIdFormat(shortId) {
 			return echo.NewHTTPError(http.StatusNotFound, "Invalid short URL format")
 		}
 
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Actions recorded in the admin audit log
+const (
+	AdminActionListUsers   = "list_users"
+	AdminActionListUrls    = "list_urls"
+	AdminActionViewStats   = "view_stats"
+	AdminActionDisableUrl  = "disable_url"
+	AdminActionSuspendUser = "suspend_user"
+)
+
+// AdminUser is a user as listed to platform admins
+type AdminUser struct {
+	Id          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	LinkCount   int64      `json:"link_count"` // Links the user created, in any workspace
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type PaginatedAdminUsersResponse struct {
+	Users []AdminUser    `json:"users"`
+	Meta  PaginationMeta `json:"meta"`
+}
+
+// AdminUrlFilter narrows the admin link search; zero values match everything
+type AdminUrlFilter struct {
+	Search   string    // Matched against the slug, destination and domain
+	UserId   uuid.UUID // Creator of the link
+	Status   string    // One of the UrlStatus values
+	Disabled bool      // Only links disabled by an admin
+}
+
+// AdminStats is a snapshot of the whole platform
+type AdminStats struct {
+	Users           int64 `json:"users"`
+	SuspendedUsers  int64 `json:"suspended_users"`
+	Workspaces      int64 `json:"workspaces"` // Shared workspaces; personal ones are not counted
+	Links           int64 `json:"links"`
+	LiveLinks       int64 `json:"live_links"`
+	DisabledLinks   int64 `json:"disabled_links"`
+	Clicks          int64 `json:"clicks"`
+	Clicks24h       int64 `json:"clicks_24h"`
+	VerifiedDomains int64 `json:"verified_domains"`
+}
+
+// AdminAuditEntry records one admin action. TargetId is empty for actions such as
+// searches that have no single target.
+type AdminAuditEntry struct {
+	Id         int64                  `json:"id"`
+	AdminId    uuid.UUID              `json:"admin_id"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type,omitempty"` // "user" or "url"
+	TargetId   *uuid.UUID             `json:"target_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type PaginatedAuditResponse struct {
+	Entries []AdminAuditEntry `json:"entries"`
+	Meta    PaginationMeta    `json:"meta"`
+}
+
+// AdminActionReq is the body of admin actions taken against a user or link
+type AdminActionReq struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ApiKeyPrefix marks bearer credentials that are API keys rather than JWTs
+const ApiKeyPrefix = "u235_"
+
+// API key scopes
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+)
+
+type ApiKey struct {
+	Id         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // First characters of the key, safe to display
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateApiKeyReq struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=read write delete"`
+}
+
+// CreateApiKeyRes is the only response that ever contains the full key
+type CreateApiKeyRes struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+type RenameApiKeyReq struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
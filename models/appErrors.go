@@ -1,17 +1,57 @@
 package models
 
+import "net/http"
+
 // AppError represents a structured application error
 type AppError struct {
-	Code       string `json:"code"`
-	Message    string `json:"message"`
-	StatusCode int    `json:"-"` // HTTP status code, not sent in JSON response
+	Code       string      `json:"code"`
+	Message    string      `json:"message"`
+	Details    interface{} `json:"details,omitempty"`
+	StatusCode int         `json:"-"` // HTTP status code, not sent in JSON response
+	Err        error       `json:"-"` // Underlying cause, logged but never sent
 }
 
 // Error implements the error interface for AppError
 func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
 	return e.Message
 }
 
+// Unwrap exposes the underlying cause to errors.Is / errors.As
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any AppError with the same code, so copies made by the With*
+// helpers still satisfy errors.Is against the shared values below
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// WithDetails returns a copy of the error carrying extra client-facing details
+func (e *AppError) WithDetails(details interface{}) *AppError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// WithMessage returns a copy of the error with a more specific message
+func (e *AppError) WithMessage(message string) *AppError {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// Wrap returns a copy of the error that records err as its cause
+func (e *AppError) Wrap(err error) *AppError {
+	c := *e
+	c.Err = err
+	return &c
+}
+
 // NewAppError creates a new application error
 func NewAppError(code, message string, statusCode int) *AppError {
 	return &AppError{
@@ -20,3 +60,26 @@ func NewAppError(code, message string, statusCode int) *AppError {
 		StatusCode: statusCode,
 	}
 }
+
+// Errors shared across services and handlers. Never modify these in place;
+// use WithDetails, WithMessage or Wrap to specialise them.
+var (
+	ErrBadRequest         = NewAppError("BAD_REQUEST", "Invalid request format", http.StatusBadRequest)
+	ErrUnauthorized       = NewAppError("UNAUTHORIZED", "Unauthorized access", http.StatusUnauthorized)
+	ErrInternal           = NewAppError("INTERNAL_ERROR", "Internal server error", http.StatusInternalServerError)
+	ErrInvalidCredentials = NewAppError("INVALID_CREDENTIALS", "Invalid email or password", http.StatusUnauthorized)
+	ErrUserExists         = NewAppError("USER_EXISTS", "User with this email already exists", http.StatusConflict)
+	ErrUserNotFound       = NewAppError("USER_NOT_FOUND", "User not found", http.StatusNotFound)
+	ErrAccountSuspended   = NewAppError("ACCOUNT_SUSPENDED", "This account has been suspended", http.StatusForbidden)
+	ErrUrlNotFound        = NewAppError("URL_NOT_FOUND", "The requested URL does not exist", http.StatusNotFound)
+	ErrUrlAccessDenied    = NewAppError("ACCESS_DENIED", "You do not have permission to access this URL", http.StatusForbidden)
+	ErrUrlInactive        = NewAppError("URL_INACTIVE", "URL is inactive or deleted", http.StatusConflict)
+	ErrUrlDisabled        = NewAppError("URL_DISABLED", "This URL was disabled by an administrator", http.StatusForbidden)
+	ErrShortUrlTaken      = NewAppError("SHORT_URL_TAKEN", "Custom short url already exists", http.StatusConflict)
+	ErrInvalidShortUrl    = NewAppError("INVALID_SHORT_URL", "Invalid custom short url", http.StatusBadRequest)
+	ErrRateLimited        = NewAppError("RATE_LIMITED", "Too many requests", http.StatusTooManyRequests)
+	ErrPasswordRequired   = NewAppError("PASSWORD_REQUIRED", "This link is password protected", http.StatusUnauthorized)
+	ErrInvalidPassword    = NewAppError("INVALID_PASSWORD", "Incorrect password for this link", http.StatusUnauthorized)
+	ErrUrlNotYetActive    = NewAppError("URL_NOT_YET_ACTIVE", "This link is not available yet", http.StatusNotFound)
+	ErrUnsafeDestination  = NewAppError("UNSAFE_DESTINATION", "The destination URL is not allowed", http.StatusUnprocessableEntity)
+)
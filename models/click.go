@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ClickEvent is a single redirect, as written to the url_clicks table
+type ClickEvent struct {
+	UrlId          uuid.UUID `json:"url_id"`    // uuid.Nil for links cached before ids were carried in Redis
+	ShortUrl       string    `json:"short_url"` // Link key, see LinkKey
+	ClickedAt      time.Time `json:"clicked_at"`
+	Referrer       string    `json:"referrer"`
+	UserAgent      string    `json:"user_agent"`
+	IpHash         string    `json:"ip_hash"`
+	AcceptLanguage string    `json:"accept_language"`
+	Browser        string    `json:"browser"`
+	Os             string    `json:"os"`
+	Variant        string    `json:"variant"` // A/B split variant the visitor was sent to, "" when not split
+
+	// ClientIP is only used to compute IpHash and is never stored
+	ClientIP string `json:"-"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Domain is a custom domain a user serves short links on. Links can only be
+// created on it once VerifiedAt is set.
+type Domain struct {
+	Id         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"user_id"`
+	Hostname   string     `json:"hostname"`
+	Token      string     `json:"-"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// DomainVerification is the DNS TXT record that proves ownership of a domain
+type DomainVerification struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// DomainRes is a domain as returned by the API; Verification is only set while
+// the domain is unverified.
+type DomainRes struct {
+	Domain
+	Verified     bool                `json:"verified"`
+	Verification *DomainVerification `json:"verification,omitempty"`
+}
+
+type CreateDomainReq struct {
+	Hostname string `json:"hostname" validate:"required,max=253"`
+}
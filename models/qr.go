@@ -0,0 +1,24 @@
+package models
+
+// QR code image formats
+const (
+	QrFormatPng = "png"
+	QrFormatSvg = "svg"
+)
+
+// QrCodeReq holds the options of GET /api/urls/:urlId/qr. An empty Format, Level
+// or colour and a zero Size take the defaults.
+type QrCodeReq struct {
+	Format     string
+	Size       int // Width and height in pixels
+	Margin     int // Quiet zone in modules
+	Level      string
+	Foreground string // Hex colours, "rrggbb" or "rrggbbaa"
+	Background string
+}
+
+type QrCodeRes struct {
+	ShortUrl    string
+	ContentType string
+	Image       []byte
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitPolicy allows Limit requests per sliding Window. A zero Limit disables it.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is when the oldest counted request leaves the window
+	ResetAfter time.Duration
+}
+
+// ParseRateLimitPolicy reads specs such as "20/1m" or "1000/1h"; "off" disables the policy.
+func ParseRateLimitPolicy(name, spec string) (RateLimitPolicy, error) {
+	policy := RateLimitPolicy{Name: name}
+
+	spec = strings.TrimSpace(spec)
+	if strings.EqualFold(spec, "off") {
+		return policy, nil
+	}
+
+	limitStr, windowStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return policy, fmt.Errorf("rate limit %q must look like <limit>/<window>, e.g. 20/1m", spec)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit <= 0 {
+		return policy, fmt.Errorf("rate limit %q has an invalid limit", spec)
+	}
+
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil || window < time.Second {
+		return policy, fmt.Errorf("rate limit %q has an invalid window (minimum 1s)", spec)
+	}
+
+	policy.Limit = limit
+	policy.Window = window
+	return policy, nil
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Supported time series bucket sizes
+const (
+	StatsBucketHour = "hour"
+	StatsBucketDay  = "day"
+	StatsBucketWeek = "week"
+)
+
+type UrlStatsReq struct {
+	From   time.Time
+	To     time.Time
+	Bucket string
+	Top    int
+}
+
+type StatsBucket struct {
+	Start        time.Time `json:"start"`
+	Clicks       int64     `json:"clicks"`
+	UniqueClicks int64     `json:"unique_clicks"`
+}
+
+type StatsCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type UrlStatsRes struct {
+	UrlId         uuid.UUID     `json:"url_id"`
+	ShortUrl      string        `json:"short_url"`
+	From          time.Time     `json:"from"`
+	To            time.Time     `json:"to"`
+	Bucket        string        `json:"bucket"`
+	TotalClicks   int64         `json:"total_clicks"`
+	UniqueClicks  int64         `json:"unique_clicks"`
+	TimeSeries    []StatsBucket `json:"time_series"`
+	TopReferrers  []StatsCount  `json:"top_referrers"`
+	TopUserAgents []StatsCount  `json:"top_user_agents"`
+	TopBrowsers   []StatsCount  `json:"top_browsers"`
+	TopOs         []StatsCount  `json:"top_os"`
+	Variants      []StatsCount  `json:"variants,omitempty"` // Clicks per A/B split variant, only for split links
+}
@@ -1,32 +1,311 @@
 package models
 
 import (
+	"U-235/core/routing"
 	"github.com/google/uuid"
+	"net/http"
+	"strings"
 	"time"
 )
 
+// DefaultRedirectType is the status code used when a link does not pick one.
+const DefaultRedirectType = http.StatusFound
+
+// Link states reported and filtered on by the list API
+const (
+	UrlStatusActive    = "active"
+	UrlStatusInactive  = "inactive"
+	UrlStatusScheduled = "scheduled"
+)
+
 type ShortenedUrlInfoRes struct {
-	Id          uuid.UUID `json:"id"`
-	UserId      uuid.UUID `json:"user_id"`
-	OriginalUrl string    `json:"original_url" validate:"required,url"`
-	ShortUrl    string    `json:"short_url" validate:"required,url"`
-	ExpiresAt   time.Time `json:"expires_at" validate:"required,min=0"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
+	Id              uuid.UUID     `json:"id"`
+	UserId          uuid.UUID     `json:"user_id"` // Member who created the link
+	WorkspaceId     uuid.UUID     `json:"workspace_id"`
+	OriginalUrl     string        `json:"original_url" validate:"required,url"`
+	ShortUrl        string        `json:"short_url" validate:"required,url"` // Same as Slug, kept for older clients
+	Slug            string        `json:"slug" gorm:"-"`
+	ShortLink       string        `json:"short_link" gorm:"-"` // Absolute URL on the link's domain
+	Domain          string        `json:"domain,omitempty"`    // Custom domain of the link; empty for the default domains
+	ExpiresAt       time.Time     `json:"expires_at" validate:"required,min=0"`
+	IsActive        bool          `json:"is_active"`
+	RedirectType    int           `json:"redirect_type"`
+	CreatedAt       time.Time     `json:"created_at"`
+	IsProtected     bool          `json:"is_protected" gorm:"-"`
+	PasswordHash    string        `json:"-"`
+	MaxClicks       int           `json:"max_clicks,omitempty"`                // 0 means unlimited
+	ClicksUsed      int64         `json:"-"`                                   // Clicks counted against MaxClicks
+	RemainingClicks *int64        `json:"remaining_clicks,omitempty" gorm:"-"` // Only set by the list API
+	ActivatesAt     *time.Time    `json:"activates_at,omitempty"`              // nil means live from creation
+	Status          string        `json:"status" gorm:"-"`
+	RoutingRules    []RoutingRule `json:"routing_rules,omitempty" gorm:"serializer:json"`
+	Variants        []Variant     `json:"variants,omitempty" gorm:"serializer:json"`
+	StickyVariants  bool          `json:"sticky_variants,omitempty"`
+	QueryParams     []QueryParam  `json:"query_params,omitempty" gorm:"serializer:json"`
+	ForwardQuery    bool          `json:"forward_query,omitempty"`
+	Preview         bool          `json:"preview,omitempty"`
+	DisabledAt      *time.Time    `json:"disabled_at,omitempty"` // Set when an admin disabled the link
+}
+
+// LinkKey is the Redis key of the link, see LinkKey
+func (u *ShortenedUrlInfoRes) LinkKey() string {
+	return LinkKey(u.Domain, u.ShortUrl)
+}
+
+// IsScheduled reports whether an active link is still waiting for its activation time
+func (u *ShortenedUrlInfoRes) IsScheduled(now time.Time) bool {
+	return u.IsActive && u.ActivatesAt != nil && u.ActivatesAt.After(now)
+}
+
+// IsLive reports whether a link should currently redirect, and so be cached
+func (u *ShortenedUrlInfoRes) IsLive(now time.Time) bool {
+	return u.IsActive && !u.IsScheduled(now) && u.ExpiresAt.After(now)
+}
+
+// CurrentStatus derives the list API state of a link
+func (u *ShortenedUrlInfoRes) CurrentStatus(now time.Time) string {
+	switch {
+	case !u.IsActive:
+		return UrlStatusInactive
+	case u.IsScheduled(now):
+		return UrlStatusScheduled
+	default:
+		return UrlStatusActive
+	}
 }
 
 type ShortenedUrlInfoReq struct {
-	UserId      uuid.UUID `json:"user_id"`
-	OriginalUrl string    `json:"original_url" validate:"required,url"`
-	ShortUrl    string    `json:"short_url" validate:"required,url"`
-	ExpiresAt   time.Time `json:"expires_at" validate:"required,min=0"`
-	IsActive    bool      `json:"is_active"`
+	UserId         uuid.UUID     `json:"user_id"`
+	WorkspaceId    uuid.UUID     `json:"workspace_id"`
+	Domain         string        `json:"domain"` // Verified custom domain, empty for the default domains
+	OriginalUrl    string        `json:"original_url" validate:"required,url"`
+	ShortUrl       string        `json:"short_url" validate:"required,url"`
+	ExpiresAt      time.Time     `json:"expires_at" validate:"required,min=0"`
+	IsActive       bool          `json:"is_active"`
+	RedirectType   int           `json:"redirect_type"`
+	PasswordHash   string        `json:"-"` // bcrypt hash; empty for unprotected links
+	MaxClicks      int           `json:"max_clicks"`
+	ActivatesAt    *time.Time    `json:"activates_at"`
+	RoutingRules   []RoutingRule `json:"routing_rules"`
+	Variants       []Variant     `json:"variants"`
+	StickyVariants bool          `json:"sticky_variants"`
+	QueryParams    []QueryParam  `json:"query_params"`
+	ForwardQuery   bool          `json:"forward_query"`
+	Preview        bool          `json:"preview"`
+}
+
+// QueryParam is added to the query string of the destination on every redirect,
+// unless the destination or the forwarded query already sets the key. Value may
+// use the placeholders {short_id} and {variant}.
+type QueryParam struct {
+	Key   string `json:"key" validate:"required,max=100"`
+	Value string `json:"value" validate:"max=500"`
+}
+
+// CompileQueryParams converts query parameters to the form cached in Redis
+func CompileQueryParams(params []QueryParam) []routing.Param {
+	if len(params) == 0 {
+		return nil
+	}
+	compiled := make([]routing.Param, len(params))
+	for i, p := range params {
+		compiled[i] = routing.Param{Key: p.Key, Value: p.Value}
+	}
+	return compiled
+}
+
+// RoutingRule sends visitors matching every given condition to Destination instead
+// of the link's original URL. Rules are tried in order and the first match wins;
+// within one condition any listed value matches. A link has at most 20 rules.
+type RoutingRule struct {
+	Devices     []string `json:"devices,omitempty" validate:"dive,oneof=ios android windows macos linux mobile desktop"`
+	Countries   []string `json:"countries,omitempty" validate:"dive,len=2,alpha"`  // ISO 3166-1 alpha-2
+	Languages   []string `json:"languages,omitempty" validate:"dive,min=2,max=35"` // e.g. "en" or "pt-BR"
+	Destination string   `json:"destination" validate:"required,url"`
+}
+
+// CompileRoutingRules converts rules to the form cached in Redis and evaluated on redirect
+func CompileRoutingRules(rules []RoutingRule) []routing.Rule {
+	if len(rules) == 0 {
+		return nil
+	}
+	compiled := make([]routing.Rule, len(rules))
+	for i, rule := range rules {
+		compiled[i] = routing.Rule{
+			Devices:   rule.Devices,
+			Countries: rule.Countries,
+			Languages: rule.Languages,
+			URL:       rule.Destination,
+		}.Normalize()
+	}
+	return compiled
+}
+
+// Variant is one destination of an A/B split. Visitors that no routing rule
+// matches are spread across a link's variants in proportion to their weights,
+// and each click records the variant it was sent to.
+type Variant struct {
+	Name        string `json:"name" validate:"required,max=32,alphanum"`
+	Destination string `json:"destination" validate:"required,url"`
+	Weight      int    `json:"weight" validate:"required,min=1,max=1000"`
+}
+
+// CompileVariants converts variants to the form cached in Redis and picked from on redirect
+func CompileVariants(variants []Variant) []routing.Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+	compiled := make([]routing.Variant, len(variants))
+	for i, v := range variants {
+		compiled[i] = routing.Variant{Name: v.Name, URL: v.Destination, Weight: v.Weight}
+	}
+	return compiled
 }
 
 type CreateShortUrlReq struct {
 	OriginalUrl    string `json:"original_url" validate:"required,url"`
 	ExpireTime     int64  `json:"expire_time" validate:"required"`
-	CustomShortUrl string `json:"custom_short_url"` //Optional
+	CustomShortUrl string `json:"custom_short_url"`                                         //Optional
+	RedirectType   int    `json:"redirect_type" validate:"omitempty,oneof=301 302 307 308"` //Optional, defaults to 302
+	Password       string `json:"password" validate:"link_password"`                        //Optional, visitors must enter it
+	MaxClicks      int    `json:"max_clicks" validate:"omitempty,min=1"`                    //Optional, deactivates the link after this many clicks
+	// Optional, the link goes live at this time and expire_time counts from it
+	ActivatesAt *time.Time `json:"activates_at"`
+	// Optional, per-visitor destinations tried before original_url
+	RoutingRules []RoutingRule `json:"routing_rules" validate:"max=20,dive"`
+	// Optional, splits visitors across 2 to 10 weighted destinations instead of original_url
+	Variants []Variant `json:"variants" validate:"omitempty,min=2,max=10,unique=Name,dive"`
+	// Optional, keeps returning visitors on the variant they saw first
+	StickyVariants bool `json:"sticky_variants"`
+	// Optional, e.g. utm_source and utm_campaign, merged into every destination
+	QueryParams []QueryParam `json:"query_params" validate:"max=20,unique=Key,dive"`
+	// Optional, passes the query string of the short link on to the destination
+	ForwardQuery bool `json:"forward_query"`
+	// Optional, visitors see a preview page with the destination before being redirected
+	Preview bool `json:"preview"`
+	// Optional, a verified custom domain of the user to serve the link on
+	Domain string `json:"domain" validate:"omitempty,max=253"`
+}
+
+// BulkCreateUrlReq is the JSON body of POST /api/urls/bulk. In atomic mode a
+// single failing item means no links are created.
+type BulkCreateUrlReq struct {
+	Items  []CreateShortUrlReq `json:"items" validate:"required,min=1"`
+	Atomic bool                `json:"atomic"`
+}
+
+// BulkCreateItemRes reports the outcome of one bulk item, by its position in the request
+type BulkCreateItemRes struct {
+	Index   int                  `json:"index"`
+	Success bool                 `json:"success"`
+	Url     *ShortenedUrlInfoRes `json:"url,omitempty"`
+	Error   *AppError            `json:"error,omitempty"`
+}
+
+type BulkCreateUrlRes struct {
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+	Results []BulkCreateItemRes `json:"results"`
+}
+
+// UpdateUrlReq is a partial update of a link; omitted fields are left unchanged.
+type UpdateUrlReq struct {
+	OriginalUrl  *string    `json:"original_url" validate:"omitempty,url"`
+	ShortUrl     *string    `json:"short_url"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	ActivatesAt  *time.Time `json:"activates_at"` // A past time makes a scheduled link live now
+	RedirectType *int       `json:"redirect_type" validate:"omitempty,oneof=301 302 307 308"`
+	Reactivate   bool       `json:"reactivate"`                                // Reactivates a deleted or expired link
+	Password     *string    `json:"password" validate:"omitnil,link_password"` // An empty string removes the password
+	// Replaces every rule; an empty list removes them
+	RoutingRules *[]RoutingRule `json:"routing_rules" validate:"omitempty,max=20,dive"`
+	// Replaces every variant; an empty list ends the split
+	Variants       *[]Variant `json:"variants" validate:"omitempty,ne=1,max=10,unique=Name,dive"`
+	StickyVariants *bool      `json:"sticky_variants"`
+	// Replaces every query parameter; an empty list removes them
+	QueryParams  *[]QueryParam `json:"query_params" validate:"omitempty,max=20,unique=Key,dive"`
+	ForwardQuery *bool         `json:"forward_query"`
+	Preview      *bool         `json:"preview"`
+}
+
+// UrlPreview is what the preview page shows about a link besides its destination
+type UrlPreview struct {
+	Entry     *CachedUrl
+	CreatedAt time.Time
+	OwnerName string // Display name of the link's owner, may be empty
+}
+
+// UnlockUrlReq is the password a visitor enters for a protected link
+type UnlockUrlReq struct {
+	Password string `json:"password" form:"password" validate:"required,max=72"`
+}
+
+// UnlockUrlRes is returned to API clients after a successful unlock. It leaves
+// out the destination: clients follow the short link with the token, so the
+// click limit, routing and click recording still apply.
+type UnlockUrlRes struct {
+	UnlockToken string `json:"unlock_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// LinkKey identifies a link across domains, and is its Redis key. Slugs are unique
+// per domain: links on the default domains are keyed by the bare slug, and links
+// on a custom domain by "<hostname>/<slug>". Neither form contains ':', which
+// internal Redis keys are namespaced with.
+func LinkKey(domain, slug string) string {
+	if domain == "" {
+		return slug
+	}
+	return domain + "/" + slug
+}
+
+// SplitLinkKey is the inverse of LinkKey
+func SplitLinkKey(key string) (domain, slug string) {
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// CachedUrl is the value stored in Redis under a short URL key.
+// Field names are kept short since every active link carries one.
+type CachedUrl struct {
+	Id           uuid.UUID         `json:"i"`
+	OriginalUrl  string            `json:"u"`
+	RedirectType int               `json:"t,omitempty"`
+	Protected    bool              `json:"p,omitempty"`  // Visitors must unlock the link first
+	PasswordVer  string            `json:"pv,omitempty"` // Changes with the password, ending earlier unlocks
+	MaxClicks    int               `json:"m,omitempty"`  // Clicks allowed before the link deactivates
+	Rules        []routing.Rule    `json:"r,omitempty"`  // Conditional destinations, see RoutingRule
+	Variants     []routing.Variant `json:"v,omitempty"`  // A/B split destinations, see Variant
+	Sticky       bool              `json:"s,omitempty"`  // Visitors keep their variant through a cookie
+	Query        []routing.Param   `json:"q,omitempty"`  // Query parameters merged into the destination
+	ForwardQuery bool              `json:"f,omitempty"`  // The visitor's query string is passed on
+	Preview      bool              `json:"w,omitempty"`  // Visitors see the preview page first
+}
+
+// Destination returns where v should be redirected, and the name of the variant
+// when the visit was split. Routing rules come first; visitors matching none go
+// to the variant pick chooses, or to OriginalUrl when the link has no split.
+func (c *CachedUrl) Destination(v routing.Visitor, pick func(variants []routing.Variant) int) (url string, variant string) {
+	if url, ok := routing.Evaluate(c.Rules, v); ok {
+		return url, ""
+	}
+	if len(c.Variants) > 0 {
+		if i := pick(c.Variants); i >= 0 && i < len(c.Variants) {
+			return c.Variants[i].URL, c.Variants[i].Name
+		}
+	}
+	return c.OriginalUrl, ""
+}
+
+// WithQuery merges the link's query parameters into destination, along with the
+// visitor's raw query string when the link forwards it. See routing.MergeQuery.
+func (c *CachedUrl) WithQuery(destination string, incoming string, vars map[string]string) string {
+	if !c.ForwardQuery {
+		incoming = ""
+	}
+	return routing.MergeQuery(destination, c.Query, incoming, vars)
 }
 
 type DeleteShortUrlReq struct {
@@ -39,7 +318,9 @@ type PsqlRollback struct {
 	UrlRecordId uuid.UUID `json:"url_record_id"`
 }
 
+// ExtendExpiry adds hours to a link's lifetime and/or clicks to its click limit
 type ExtendExpiry struct {
-	UrlId uuid.UUID `json:"url_id"`
-	Hours int       `json:"hours" validate:"required,lt=73"`
+	UrlId  uuid.UUID `json:"url_id"`
+	Hours  int       `json:"hours" validate:"required_without=Clicks,gte=0,lt=73"`
+	Clicks int       `json:"clicks" validate:"gte=0"`
 }
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Export and import file formats
+const (
+	ExportFormatCsv    = "csv"
+	ExportFormatNdjson = "ndjson"
+)
+
+// What an import does with a row whose short URL is already taken
+const (
+	ImportConflictSkip   = "skip"
+	ImportConflictRename = "rename"
+	ImportConflictFail   = "fail"
+)
+
+// Outcome of one import row
+const (
+	ImportStatusCreated = "created"
+	ImportStatusRenamed = "renamed"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+)
+
+// UrlExportRow is one link in an export file, and the row format accepted by import.
+// ShortLink and CreatedAt are informational: imports ignore the link, built from the
+// link's domain, and give links a new creation time. Domain must be a verified
+// custom domain of the importing user, or empty for the default domains. PasswordHash
+// is the bcrypt hash of a protected link, so exports must be stored securely.
+// MaxClicks is the click limit; imported links start with no clicks used.
+type UrlExportRow struct {
+	OriginalUrl    string        `json:"original_url" validate:"required,url"`
+	ShortUrl       string        `json:"short_url" validate:"required"`
+	ShortLink      string        `json:"short_link,omitempty"`
+	Domain         string        `json:"domain,omitempty"`
+	ExpiresAt      time.Time     `json:"expires_at" validate:"required"`
+	IsActive       bool          `json:"is_active"`
+	RedirectType   int           `json:"redirect_type" validate:"omitempty,oneof=301 302 307 308"`
+	CreatedAt      time.Time     `json:"created_at,omitempty"`
+	PasswordHash   string        `json:"password_hash,omitempty"`
+	MaxClicks      int           `json:"max_clicks,omitempty" validate:"gte=0"`
+	ActivatesAt    *time.Time    `json:"activates_at,omitempty"`
+	RoutingRules   []RoutingRule `json:"routing_rules,omitempty" validate:"max=20,dive"`
+	Variants       []Variant     `json:"variants,omitempty" validate:"omitempty,min=2,max=10,unique=Name,dive"`
+	StickyVariants bool          `json:"sticky_variants,omitempty"`
+	QueryParams    []QueryParam  `json:"query_params,omitempty" validate:"max=20,unique=Key,dive"`
+	ForwardQuery   bool          `json:"forward_query,omitempty"`
+	Preview        bool          `json:"preview,omitempty"`
+}
+
+// ImportRowRes reports the outcome of one import row, by its position in the file
+type ImportRowRes struct {
+	Index     int       `json:"index"`
+	Status    string    `json:"status"`
+	ShortUrl  string    `json:"short_url,omitempty"`
+	ShortLink string    `json:"short_link,omitempty"` // Set for rows that were created or renamed
+	Error     *AppError `json:"error,omitempty"`
+}
+
+type ImportUrlsRes struct {
+	Created int            `json:"created"`
+	Renamed int            `json:"renamed"`
+	Skipped int            `json:"skipped"`
+	Failed  int            `json:"failed"`
+	Rows    []ImportRowRes `json:"rows"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Workspace owns links on behalf of its members. Every user has a personal
+// workspace, created on first use, where links go when no workspace is chosen.
+type Workspace struct {
+	Id        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Personal  bool      `json:"personal"`
+	Role      string    `json:"role,omitempty"` // Role of the requesting user
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	UserId    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceInvitation lets the holder of its token join a workspace with Role,
+// once, while signed in with Email
+type WorkspaceInvitation struct {
+	Id          uuid.UUID `json:"id"`
+	WorkspaceId uuid.UUID `json:"workspace_id"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	InvitedBy   uuid.UUID `json:"invited_by"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateWorkspaceReq struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type CreateInvitationReq struct {
+	Email string `json:"email" validate:"required,email,max=500"`
+	Role  string `json:"role" validate:"required,oneof=owner admin editor viewer"`
+}
+
+// CreateInvitationRes is the only response that ever contains the invitation token
+type CreateInvitationRes struct {
+	WorkspaceInvitation
+	Token string `json:"token"`
+}
+
+type AcceptInvitationReq struct {
+	Token string `json:"token" validate:"required"`
+}
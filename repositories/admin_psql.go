@@ -0,0 +1,308 @@
+package repositories
+
+import (
+	"U-235/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// likeEscaper escapes the LIKE wildcards of user input so that it matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// AdminRepo serves the platform admin API. It reads across all users and
+// workspaces, so it must only be reached through admin routes.
+type AdminRepo interface {
+	ListUsers(ctx context.Context, search string, offset, limit int) ([]models.AdminUser, int64, error)
+	ListUrls(ctx context.Context, filter *models.AdminUrlFilter, offset, limit int) ([]models.ShortenedUrlInfoRes, int64, error)
+	DisableUrl(ctx context.Context, urlId uuid.UUID, entry *models.AdminAuditEntry) (*models.ShortenedUrlInfoRes, error)
+	SuspendUser(ctx context.Context, userId uuid.UUID, entry *models.AdminAuditEntry) ([]string, error)
+	GetStats(ctx context.Context) (*models.AdminStats, error)
+	RecordAudit(ctx context.Context, entry *models.AdminAuditEntry) error
+	ListAudit(ctx context.Context, offset, limit int) ([]models.AdminAuditEntry, int64, error)
+}
+
+type AdminPsqlImpl struct {
+	db     *sql.DB
+	gormDB *gorm.DB
+}
+
+func NewAdminPsql(db *sql.DB, gormDB *gorm.DB) AdminRepo {
+	return &AdminPsqlImpl{
+		db:     db,
+		gormDB: gormDB,
+	}
+}
+
+// ListUsers pages through users, newest first, whose email or name contains search
+func (a *AdminPsqlImpl) ListUsers(ctx context.Context, search string, offset, limit int) ([]models.AdminUser, int64, error) {
+	query := func() *gorm.DB {
+		q := a.gormDB.WithContext(ctx).Table("users")
+		if search != "" {
+			pattern := "%" + likeEscaper.Replace(search) + "%"
+			q = q.Where("email ILIKE ? OR name ILIKE ?", pattern, pattern)
+		}
+		return q
+	}
+
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []models.AdminUser
+	err := query().
+		Select(`id, COALESCE(name, '') AS name, email, role, suspended_at, created_at,
+			(SELECT COUNT(*) FROM shortened_urls s WHERE s.user_id = users.id) AS link_count`).
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, total, nil
+}
+
+// ListUrls pages through the links of every workspace, newest first
+func (a *AdminPsqlImpl) ListUrls(ctx context.Context, filter *models.AdminUrlFilter, offset, limit int) ([]models.ShortenedUrlInfoRes, int64, error) {
+	query := func() *gorm.DB {
+		q := a.gormDB.WithContext(ctx).Table("shortened_urls")
+		if filter.Search != "" {
+			pattern := "%" + likeEscaper.Replace(filter.Search) + "%"
+			q = q.Where("short_url ILIKE ? OR original_url ILIKE ? OR domain ILIKE ?", pattern, pattern, pattern)
+		}
+		if filter.UserId != uuid.Nil {
+			q = q.Where("user_id = ?", filter.UserId)
+		}
+		if filter.Disabled {
+			q = q.Where("disabled_at IS NOT NULL")
+		}
+		return filterUrlStatus(q, filter.Status)
+	}
+
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count urls: %w", err)
+	}
+
+	var urls []models.ShortenedUrlInfoRes
+	err := query().
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&urls).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list urls: %w", err)
+	}
+
+	now := time.Now()
+	for i := range urls {
+		urls[i].IsProtected = urls[i].PasswordHash != ""
+		urls[i].Status = urls[i].CurrentStatus(now)
+	}
+	return urls, total, nil
+}
+
+// DisableUrl deactivates a link for good and records entry in the same transaction.
+// Disabling an already disabled link keeps its original disabled_at.
+func (a *AdminPsqlImpl) DisableUrl(ctx context.Context, urlId uuid.UUID, entry *models.AdminAuditEntry) (*models.ShortenedUrlInfoRes, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+		UPDATE shortened_urls
+		SET is_active = false, disabled_at = COALESCE(disabled_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + urlInfoColumns
+
+	var urlInfo models.ShortenedUrlInfoRes
+	if err := scanUrlInfo(tx.QueryRowContext(ctx, query, urlId), &urlInfo); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to disable url: %w", err)
+	}
+
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &urlInfo, nil
+}
+
+// SuspendUser marks the user suspended, disables every link they created, revokes
+// their refresh tokens and records entry, all in one transaction. It returns the
+// link keys of all of the user's links so they can be removed from Redis; a repeated
+// suspension returns them again, so a failed Redis cleanup can be retried.
+func (a *AdminPsqlImpl) SuspendUser(ctx context.Context, userId uuid.UUID, entry *models.AdminAuditEntry) ([]string, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE users SET suspended_at = COALESCE(suspended_at, NOW()), updated_at = NOW() WHERE id = $1`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suspend user: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE shortened_urls
+		SET is_active = false, disabled_at = COALESCE(disabled_at, NOW()), updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING COALESCE(domain, ''), short_url`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to disable user urls: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var domain, shortUrl string
+		if err := rows.Scan(&domain, &shortUrl); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan disabled url: %w", err)
+		}
+		keys = append(keys, models.LinkKey(domain, shortUrl))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := revokeUserRefreshTokens(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+
+	if entry.Details == nil {
+		entry.Details = map[string]interface{}{}
+	}
+	entry.Details["links_disabled"] = len(keys)
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return keys, nil
+}
+
+func (a *AdminPsqlImpl) GetStats(ctx context.Context) (*models.AdminStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL),
+			(SELECT COUNT(*) FROM workspaces WHERE personal_user_id IS NULL),
+			(SELECT COUNT(*) FROM shortened_urls),
+			(SELECT COUNT(*) FROM shortened_urls
+				WHERE is_active = true AND expires_at > NOW() AND (activates_at IS NULL OR activates_at <= NOW())),
+			(SELECT COUNT(*) FROM shortened_urls WHERE disabled_at IS NOT NULL),
+			(SELECT COUNT(*) FROM url_clicks),
+			(SELECT COUNT(*) FROM url_clicks WHERE clicked_at > NOW() - INTERVAL '24 hours'),
+			(SELECT COUNT(*) FROM domains WHERE verified_at IS NOT NULL)
+	`
+
+	var stats models.AdminStats
+	err := a.db.QueryRowContext(ctx, query).Scan(
+		&stats.Users,
+		&stats.SuspendedUsers,
+		&stats.Workspaces,
+		&stats.Links,
+		&stats.LiveLinks,
+		&stats.DisabledLinks,
+		&stats.Clicks,
+		&stats.Clicks24h,
+		&stats.VerifiedDomains,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+	return &stats, nil
+}
+
+func (a *AdminPsqlImpl) RecordAudit(ctx context.Context, entry *models.AdminAuditEntry) error {
+	return insertAudit(ctx, a.db, entry)
+}
+
+// ListAudit pages through the audit log, newest first
+func (a *AdminPsqlImpl) ListAudit(ctx context.Context, offset, limit int) ([]models.AdminAuditEntry, int64, error) {
+	var total int64
+	if err := a.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM admin_audit_log`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	query := `
+		SELECT id, admin_id, action, COALESCE(target_type, ''), target_id, details, created_at
+		FROM admin_audit_log
+		ORDER BY id DESC
+		OFFSET $1 LIMIT $2
+	`
+	rows, err := a.db.QueryContext(ctx, query, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AdminAuditEntry{}
+	for rows.Next() {
+		var (
+			entry   models.AdminAuditEntry
+			details []byte
+		)
+		if err := rows.Scan(&entry.Id, &entry.AdminId, &entry.Action, &entry.TargetType, &entry.TargetId, &details, &entry.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &entry.Details); err != nil {
+				return nil, 0, fmt.Errorf("failed to decode audit details: %w", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// insertAudit writes entry through db, which may be the transaction of the action
+func insertAudit(ctx context.Context, db execQuerier, entry *models.AdminAuditEntry) error {
+	var details interface{}
+	if len(entry.Details) > 0 {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit details: %w", err)
+		}
+		details = string(encoded)
+	}
+
+	query := `
+		INSERT INTO admin_audit_log (admin_id, action, target_type, target_id, details)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id, created_at
+	`
+	err := db.QueryRowContext(ctx, query, entry.AdminId, entry.Action, entry.TargetType, entry.TargetId, details).
+		Scan(&entry.Id, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
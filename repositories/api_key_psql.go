@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"U-235/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+)
+
+type ApiKeyRepo interface {
+	CreateApiKey(ctx context.Context, key *models.ApiKey, keyHash string) error
+	ListApiKeys(ctx context.Context, userId uuid.UUID) ([]models.ApiKey, error)
+	RenameApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID, name string) error
+	RevokeApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID) error
+	GetActiveApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error)
+	TouchApiKey(ctx context.Context, keyId uuid.UUID) error
+}
+
+type ApiKeyPsqlImpl struct {
+	db *sql.DB
+}
+
+func NewApiKeyPsql(db *sql.DB) ApiKeyRepo {
+	return &ApiKeyPsqlImpl{
+		db: db,
+	}
+}
+
+// Scopes are stored as a comma separated list, e.g. "read,write"
+func (a *ApiKeyPsqlImpl) CreateApiKey(ctx context.Context, key *models.ApiKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err := a.db.QueryRowContext(ctx, query, key.UserId, key.Name, key.Prefix, keyHash, strings.Join(key.Scopes, ",")).
+		Scan(&key.Id, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+func (a *ApiKeyPsqlImpl) ListApiKeys(ctx context.Context, userId uuid.UUID) ([]models.ApiKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, scopes, last_used_at, revoked_at, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := a.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.ApiKey, 0)
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (a *ApiKeyPsqlImpl) RenameApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID, name string) error {
+	query := `UPDATE api_keys SET name = $1 WHERE id = $2 AND user_id = $3`
+	return a.execOwned(ctx, query, name, keyId, userId)
+}
+
+func (a *ApiKeyPsqlImpl) RevokeApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1 AND user_id = $2`
+	return a.execOwned(ctx, query, keyId, userId)
+}
+
+// GetActiveApiKeyByHash finds an unrevoked key; keys of suspended users are not found
+func (a *ApiKeyPsqlImpl) GetActiveApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	query := `
+		SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, k.last_used_at, k.revoked_at, k.created_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND u.suspended_at IS NULL
+	`
+	key, err := scanApiKey(a.db.QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("API key not found: %w", err)
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// TouchApiKey records usage, at most once a minute per key to keep writes cheap
+func (a *ApiKeyPsqlImpl) TouchApiKey(ctx context.Context, keyId uuid.UUID) error {
+	query := `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - interval '1 minute')
+	`
+	if _, err := a.db.ExecContext(ctx, query, keyId); err != nil {
+		return fmt.Errorf("failed to update API key usage: %w", err)
+	}
+	return nil
+}
+
+// execOwned runs an update scoped to one user's key and reports sql.ErrNoRows when nothing matched
+func (a *ApiKeyPsqlImpl) execOwned(ctx context.Context, query string, args ...interface{}) error {
+	result, err := a.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update API key: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApiKey(row rowScanner) (*models.ApiKey, error) {
+	var (
+		key    models.ApiKey
+		scopes string
+	)
+	err := row.Scan(
+		&key.Id,
+		&key.UserId,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	return &key, nil
+}
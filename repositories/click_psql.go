@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"U-235/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// clickColumns is the number of values inserted per url_clicks row
+const clickColumns = 10
+
+// ClickBreakdown names a url_clicks column that can be grouped for top-N lists
+type ClickBreakdown string
+
+const (
+	BreakdownReferrer  ClickBreakdown = "referrer"
+	BreakdownUserAgent ClickBreakdown = "user_agent"
+	BreakdownBrowser   ClickBreakdown = "browser"
+	BreakdownOs        ClickBreakdown = "os"
+	BreakdownVariant   ClickBreakdown = "variant"
+)
+
+type ClickRepo interface {
+	InsertClicks(ctx context.Context, clicks []models.ClickEvent) error
+	GetClickTotals(ctx context.Context, urlId uuid.UUID, from, to time.Time) (total int64, unique int64, err error)
+	GetClickTimeSeries(ctx context.Context, urlId uuid.UUID, from, to time.Time, bucket string) ([]models.StatsBucket, error)
+	GetTopClickValues(ctx context.Context, urlId uuid.UUID, from, to time.Time, column ClickBreakdown, limit int) ([]models.StatsCount, error)
+}
+
+type ClickPsqlImpl struct {
+	db *sql.DB
+}
+
+func NewClickPsql(db *sql.DB) ClickRepo {
+	return &ClickPsqlImpl{
+		db: db,
+	}
+}
+
+// insertClicksQuery inserts the click rows listed in its VALUES, leaving out clicks
+// on links that no longer exist so they cannot fail the rest of the batch
+const insertClicksQuery = `
+	INSERT INTO url_clicks (url_id, short_url, clicked_at, referrer, user_agent, ip_hash, accept_language, browser, os, variant)
+	SELECT v.*
+	FROM (VALUES %s) AS v(url_id, short_url, clicked_at, referrer, user_agent, ip_hash, accept_language, browser, os, variant)
+	WHERE v.url_id IS NULL OR EXISTS (SELECT 1 FROM shortened_urls WHERE id = v.url_id)
+`
+
+// InsertClicks writes a batch of click events with a single multi-row INSERT.
+// Clicks on deleted links are skipped. When the batch still fails, its clicks
+// are written one at a time so a single bad row only loses itself.
+func (c *ClickPsqlImpl) InsertClicks(ctx context.Context, clicks []models.ClickEvent) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	err := c.insertClicks(ctx, clicks)
+	if err == nil || len(clicks) == 1 || ctx.Err() != nil {
+		return err
+	}
+
+	failed := 0
+	for i := range clicks {
+		if rowErr := c.insertClicks(ctx, clicks[i:i+1]); rowErr != nil {
+			failed++
+			err = rowErr
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d click event(s) not written, last error: %w", failed, len(clicks), err)
+	}
+	return nil
+}
+
+func (c *ClickPsqlImpl) insertClicks(ctx context.Context, clicks []models.ClickEvent) error {
+	var values strings.Builder
+	args := make([]interface{}, 0, len(clicks)*clickColumns)
+	for i, click := range clicks {
+		if i > 0 {
+			values.WriteString(", ")
+		}
+		base := i * clickColumns
+		fmt.Fprintf(&values, "($%d::uuid, $%d::text, $%d::timestamptz, $%d::text, $%d::text, $%d::text, $%d::text, $%d::text, $%d::text, NULLIF($%d::text, ''))",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10)
+
+		// Links cached before ids were stored in Redis have no url_id
+		var urlId interface{}
+		if click.UrlId != uuid.Nil {
+			urlId = click.UrlId
+		}
+
+		args = append(args,
+			urlId,
+			click.ShortUrl,
+			click.ClickedAt,
+			click.Referrer,
+			click.UserAgent,
+			click.IpHash,
+			click.AcceptLanguage,
+			click.Browser,
+			click.Os,
+			click.Variant,
+		)
+	}
+
+	_, err := c.db.ExecContext(ctx, fmt.Sprintf(insertClicksQuery, values.String()), args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert click batch: %w", err)
+	}
+	return nil
+}
+
+func (c *ClickPsqlImpl) GetClickTotals(ctx context.Context, urlId uuid.UUID, from, to time.Time) (int64, int64, error) {
+	query := `
+		SELECT COUNT(*), COUNT(DISTINCT ip_hash)
+		FROM url_clicks
+		WHERE url_id = $1 AND clicked_at >= $2 AND clicked_at < $3
+	`
+
+	var total, unique int64
+	err := c.db.QueryRowContext(ctx, query, urlId, from, to).Scan(&total, &unique)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count clicks: %w", err)
+	}
+	return total, unique, nil
+}
+
+// GetClickTimeSeries returns one row per bucket in the range, including empty ones.
+// bucket must be one of the models.StatsBucket* values.
+func (c *ClickPsqlImpl) GetClickTimeSeries(ctx context.Context, urlId uuid.UUID, from, to time.Time, bucket string) ([]models.StatsBucket, error) {
+	query := `
+		SELECT b.start, COUNT(c.id), COUNT(DISTINCT c.ip_hash)
+		FROM generate_series(
+			date_trunc($4::text, $2::timestamptz),
+			$3::timestamptz - interval '1 microsecond',
+			('1 ' || $4::text)::interval
+		) AS b(start)
+		LEFT JOIN url_clicks c
+			ON c.url_id = $1
+			AND c.clicked_at >= GREATEST(b.start, $2::timestamptz)
+			AND c.clicked_at < LEAST(b.start + ('1 ' || $4::text)::interval, $3::timestamptz)
+		GROUP BY b.start
+		ORDER BY b.start
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, urlId, from, to, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch click time series: %w", err)
+	}
+	defer rows.Close()
+
+	series := make([]models.StatsBucket, 0)
+	for rows.Next() {
+		var point models.StatsBucket
+		if err := rows.Scan(&point.Start, &point.Clicks, &point.UniqueClicks); err != nil {
+			return nil, fmt.Errorf("failed to scan click time series: %w", err)
+		}
+		series = append(series, point)
+	}
+	return series, rows.Err()
+}
+
+func (c *ClickPsqlImpl) GetTopClickValues(ctx context.Context, urlId uuid.UUID, from, to time.Time, column ClickBreakdown, limit int) ([]models.StatsCount, error) {
+	switch column {
+	case BreakdownReferrer, BreakdownUserAgent, BreakdownBrowser, BreakdownOs, BreakdownVariant:
+	default:
+		return nil, fmt.Errorf("unsupported click breakdown %q", column)
+	}
+
+	// column is whitelisted above, so it is safe to splice into the query
+	query := fmt.Sprintf(`
+		SELECT COALESCE(NULLIF(%[1]s, ''), 'unknown') AS value, COUNT(*) AS clicks
+		FROM url_clicks
+		WHERE url_id = $1 AND clicked_at >= $2 AND clicked_at < $3
+		GROUP BY value
+		ORDER BY clicks DESC, value
+		LIMIT $4
+	`, column)
+
+	rows, err := c.db.QueryContext(ctx, query, urlId, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch top %s values: %w", column, err)
+	}
+	defer rows.Close()
+
+	counts := make([]models.StatsCount, 0)
+	for rows.Next() {
+		var count models.StatsCount
+		if err := rows.Scan(&count.Value, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan top %s values: %w", column, err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
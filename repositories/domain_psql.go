@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"U-235/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+)
+
+var (
+	// ErrDomainExists is returned by CreateDomain and MarkDomainVerified when the host
+	// name is already verified, by another user or, for CreateDomain, by the same one
+	ErrDomainExists = errors.New("domain already exists")
+	// ErrDomainInUse is returned by DeleteDomain while live links are still on the domain
+	ErrDomainInUse = errors.New("domain has links")
+)
+
+type DomainRepo interface {
+	CreateDomain(ctx context.Context, domain *models.Domain) error
+	ListDomains(ctx context.Context, userId uuid.UUID) ([]models.Domain, error)
+	GetDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) (*models.Domain, error)
+	MarkDomainVerified(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) (time.Time, error)
+	DeleteDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) error
+	GetVerifiedDomains(ctx context.Context) (map[string]uuid.UUID, error)
+}
+
+type DomainPsqlImpl struct {
+	db *sql.DB
+}
+
+func NewDomainPsql(db *sql.DB) DomainRepo {
+	return &DomainPsqlImpl{
+		db: db,
+	}
+}
+
+// CreateDomain adds an unverified domain. Unverified claims prove nothing, so any
+// number of users may claim the same host name, each with their own token; adding
+// a pending domain again gives it a new token. Only a verified host name returns
+// ErrDomainExists.
+func (d *DomainPsqlImpl) CreateDomain(ctx context.Context, domain *models.Domain) error {
+	query := `
+		INSERT INTO domains (user_id, hostname, token)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM domains WHERE hostname = $2 AND verified_at IS NOT NULL)
+		ON CONFLICT (user_id, hostname) DO UPDATE
+		SET token = EXCLUDED.token, created_at = NOW()
+		WHERE domains.verified_at IS NULL
+		RETURNING id, created_at
+	`
+	err := d.db.QueryRowContext(ctx, query, domain.UserId, domain.Hostname, domain.Token).
+		Scan(&domain.Id, &domain.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDomainExists
+		}
+		return fmt.Errorf("failed to create domain: %w", err)
+	}
+	return nil
+}
+
+func (d *DomainPsqlImpl) ListDomains(ctx context.Context, userId uuid.UUID) ([]models.Domain, error) {
+	query := `
+		SELECT id, user_id, hostname, token, verified_at, created_at
+		FROM domains
+		WHERE user_id = $1
+		ORDER BY hostname
+	`
+	rows, err := d.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := make([]models.Domain, 0)
+	for rows.Next() {
+		domain, err := scanDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, *domain)
+	}
+	return domains, rows.Err()
+}
+
+func (d *DomainPsqlImpl) GetDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) (*models.Domain, error) {
+	query := `
+		SELECT id, user_id, hostname, token, verified_at, created_at
+		FROM domains
+		WHERE id = $1 AND user_id = $2
+	`
+	return scanDomain(d.db.QueryRowContext(ctx, query, domainId, userId))
+}
+
+// MarkDomainVerified records the first successful verification and returns its time.
+// The pending claims other users made on the host name are dropped with it; if
+// another user verified it first, ErrDomainExists is returned.
+func (d *DomainPsqlImpl) MarkDomainVerified(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) (time.Time, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+		UPDATE domains SET verified_at = COALESCE(verified_at, NOW())
+		WHERE id = $1 AND user_id = $2
+		RETURNING hostname, verified_at
+	`
+	var (
+		hostname   string
+		verifiedAt time.Time
+	)
+	if err := tx.QueryRowContext(ctx, query, domainId, userId).Scan(&hostname, &verifiedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, err
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return time.Time{}, ErrDomainExists
+		}
+		return time.Time{}, fmt.Errorf("failed to verify domain: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM domains WHERE hostname = $1 AND id <> $2 AND verified_at IS NULL`, hostname, domainId)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to drop pending domain claims: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return verifiedAt, nil
+}
+
+// DeleteDomain removes a domain of the user. ErrDomainInUse is returned while links
+// that are live or scheduled remain on a verified domain; deleted and expired
+// links keep their domain name but do not hold the domain.
+func (d *DomainPsqlImpl) DeleteDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) error {
+	query := `
+		DELETE FROM domains
+		WHERE id = $1 AND user_id = $2 AND (verified_at IS NULL OR NOT EXISTS (
+			SELECT 1 FROM shortened_urls
+			WHERE shortened_urls.domain = domains.hostname AND is_active = true AND expires_at > NOW()
+		))
+	`
+	result, err := d.db.ExecContext(ctx, query, domainId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	// Nothing was deleted: either the domain is not the user's or it is in use
+	if _, err := d.GetDomain(ctx, userId, domainId); err != nil {
+		return err
+	}
+	return ErrDomainInUse
+}
+
+// GetVerifiedDomains returns the owner of every verified domain, by host name
+func (d *DomainPsqlImpl) GetVerifiedDomains(ctx context.Context) (map[string]uuid.UUID, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT hostname, user_id FROM domains WHERE verified_at IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list verified domains: %w", err)
+	}
+	defer rows.Close()
+
+	owners := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var (
+			hostname string
+			userId   uuid.UUID
+		)
+		if err := rows.Scan(&hostname, &userId); err != nil {
+			return nil, fmt.Errorf("failed to scan verified domain: %w", err)
+		}
+		owners[hostname] = userId
+	}
+	return owners, rows.Err()
+}
+
+func scanDomain(row rowScanner) (*models.Domain, error) {
+	var domain models.Domain
+	err := row.Scan(
+		&domain.Id,
+		&domain.UserId,
+		&domain.Hostname,
+		&domain.Token,
+		&domain.VerifiedAt,
+		&domain.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan domain: %w", err)
+	}
+	return &domain, nil
+}
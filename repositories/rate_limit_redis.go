@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"U-235/models"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// rateLimitPrefix namespaces limiter keys away from short URL keys
+const rateLimitPrefix = "ratelimit:"
+
+// slidingWindowScript keeps one sorted-set entry per counted request, scored by
+// Redis server time so every app instance shares the same clock.
+// Returns {allowed, remaining, reset_ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = window - (now - tonumber(oldest[2]))
+end
+
+return {allowed, limit - count, reset}
+`)
+
+type RateLimitRedis struct {
+	RedisClient *redis.Client
+}
+
+func NewRateLimitRedis(client *redis.Client) *RateLimitRedis {
+	return &RateLimitRedis{
+		RedisClient: client,
+	}
+}
+
+// Allow counts one request for key under policy. It satisfies middleware.RateLimiter.
+func (r *RateLimitRedis) Allow(ctx context.Context, key string, policy models.RateLimitPolicy) (models.RateLimitResult, error) {
+	redisKey := rateLimitPrefix + policy.Name + ":" + key
+
+	values, err := slidingWindowScript.Run(ctx, r.RedisClient, []string{redisKey},
+		policy.Window.Milliseconds(), policy.Limit, uuid.NewString()).Int64Slice()
+	if err != nil {
+		return models.RateLimitResult{}, fmt.Errorf("rate limit check failed: %w", err)
+	}
+
+	return models.RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  int(max(values[1], 0)),
+		ResetAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"U-235/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// ErrRefreshTokenReused is returned when a refresh token that was already rotated is presented again
+var ErrRefreshTokenReused = errors.New("refresh token already used")
+
+type RefreshTokenRepo interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldTokenId uuid.UUID, newToken *models.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyId uuid.UUID) error
+}
+
+type RefreshTokenPsqlImpl struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenPsql(db *sql.DB) RefreshTokenRepo {
+	return &RefreshTokenPsqlImpl{
+		db: db,
+	}
+}
+
+func (r *RefreshTokenPsqlImpl) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return r.insertRefreshToken(ctx, r.db, token)
+}
+
+func (r *RefreshTokenPsqlImpl) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token models.RefreshToken
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.Id,
+		&token.UserId,
+		&token.FamilyId,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.ReplacedBy,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("refresh token not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to fetch refresh token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// RotateRefreshToken retires oldTokenId and stores its replacement in one transaction.
+// If the old token was retired concurrently, ErrRefreshTokenReused is returned.
+func (r *RefreshTokenPsqlImpl) RotateRefreshToken(ctx context.Context, oldTokenId uuid.UUID, newToken *models.RefreshToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if newToken.Id == uuid.Nil {
+		newToken.Id = uuid.New()
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW(), replaced_by = $1
+		WHERE id = $2 AND revoked_at IS NULL
+	`, newToken.Id, oldTokenId)
+	if err != nil {
+		return fmt.Errorf("failed to retire refresh token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	if err := r.insertRefreshToken(ctx, tx, newToken); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *RefreshTokenPsqlImpl) RevokeRefreshTokenFamily(ctx context.Context, familyId uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, familyId); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// revokeUserRefreshTokens revokes every refresh token of a user through db, which
+// may be the transaction of a larger change such as a suspension
+func revokeUserRefreshTokens(ctx context.Context, db execQuerier, userId uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := db.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// execQuerier is satisfied by both *sql.DB and *sql.Tx
+type execQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (r *RefreshTokenPsqlImpl) insertRefreshToken(ctx context.Context, db execQuerier, token *models.RefreshToken) error {
+	if token.Id == uuid.Nil {
+		token.Id = uuid.New()
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+	err := db.QueryRowContext(ctx, query, token.Id, token.UserId, token.FamilyId, token.TokenHash, token.ExpiresAt).
+		Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const (
+	// revokedTokenPrefix namespaces revoked access token ids away from short URL keys
+	revokedTokenPrefix = "revoked:jti:"
+	// revokedUserPrefix marks users all of whose access tokens are revoked
+	revokedUserPrefix = "revoked:user:"
+)
+
+type TokenRevocationRepo interface {
+	Revoke(ctx context.Context, jti string, ttl time.Duration) error
+	RevokeUser(ctx context.Context, userId uuid.UUID, ttl time.Duration) error
+	IsRevoked(ctx context.Context, jti string, userId uuid.UUID) (bool, error)
+}
+
+type TokenRevocationRedis struct {
+	RedisClient *redis.Client
+}
+
+func NewTokenRevocationRedis(client *redis.Client) TokenRevocationRepo {
+	return &TokenRevocationRedis{
+		RedisClient: client,
+	}
+}
+
+// Revoke blocks an access token until it would have expired anyway
+func (t *TokenRevocationRedis) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := t.RedisClient.Set(ctx, revokedTokenPrefix+jti, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser blocks every access token of a user issued so far. ttl is the access
+// token lifetime, after which none of them is valid anyway.
+func (t *TokenRevocationRedis) RevokeUser(ctx context.Context, userId uuid.UUID, ttl time.Duration) error {
+	if err := t.RedisClient.Set(ctx, revokedUserPrefix+userId.String(), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the access token jti of userId is revoked, by itself
+// or along with every other token of the user
+func (t *TokenRevocationRedis) IsRevoked(ctx context.Context, jti string, userId uuid.UUID) (bool, error) {
+	n, err := t.RedisClient.Exists(ctx, revokedTokenPrefix+jti, revokedUserPrefix+userId.String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return n > 0, nil
+}
@@ -4,26 +4,63 @@ import (
 	"U-235/models"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 	"log"
+	"strings"
+	"time"
 )
 
+// ErrShortUrlExists is returned by SaveUrl when the short URL is already taken on its domain.
+var ErrShortUrlExists = errors.New("short URL already exists")
+
+// urlInsertColumns is the number of values inserted per shortened_urls row by SaveUrls
+const urlInsertColumns = 17
+
+// urlInfoColumns is the select list read by scanUrlInfo
+const urlInfoColumns = `id, user_id, original_url, short_url, expires_at, is_active, redirect_type, created_at,
+	COALESCE(password_hash, ''), COALESCE(max_clicks, 0), activates_at, routing_rules, variants, sticky_variants,
+	query_params, forward_query, preview, COALESCE(domain, ''), workspace_id, disabled_at, clicks_used`
+
+// sameDomain matches links on the domain in the given parameter, where an empty
+// string stands for the default domains (a NULL domain)
+const sameDomain = `domain IS NOT DISTINCT FROM NULLIF(%s, '')`
+
 type UrlsPsql interface {
 	SaveUrl(ctx context.Context, UrlInfo *models.ShortenedUrlInfoReq) (*models.ShortenedUrlInfoRes, *models.PsqlRollback, error)
 	GetUrlInfoByUserIdAndShortUrl(ctx context.Context, userId uuid.UUID, shortUrl string) (*models.ShortenedUrlInfoRes, error)
-	GetUrlInfoByUserIdAndUrlRecordId(ctx context.Context, userId uuid.UUID, urlRecordId uuid.UUID) (*models.ShortenedUrlInfoRes, error)
+	GetUrlInfoByRecordId(ctx context.Context, urlRecordId uuid.UUID) (*models.ShortenedUrlInfoRes, error)
 	DeleteUrlRecord(ctx context.Context, UserId uuid.UUID, UrlRecordId uuid.UUID) error
-	SoftDeleteUrl(ctx context.Context, userId uuid.UUID, urlId uuid.UUID) error
-	GetUserUrls(ctx context.Context, userID uuid.UUID, offset, limit int, isActive *bool) ([]models.ShortenedUrlInfoRes, error)
-	CountUserUrls(ctx context.Context, userID uuid.UUID, isActive *bool) (int64, error)
-	SetUrlState(ctx context.Context, userId uuid.UUID, urlId uuid.UUID, isActive bool) error
-	UrlRecordExists(ctx context.Context, urlID uuid.UUID) (bool, error)
-	ExtendExpiry(ctx context.Context, userId uuid.UUID, urlId uuid.UUID, hours int) error
-	MarkUrlAsExpired(ctx context.Context, shortUrl string) error
+	SoftDeleteUrl(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID) error
+	GetWorkspaceUrls(ctx context.Context, workspaceId uuid.UUID, offset, limit int, status string) ([]models.ShortenedUrlInfoRes, error)
+	CountWorkspaceUrls(ctx context.Context, workspaceId uuid.UUID, status string) (int64, error)
+	SetUrlState(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID, isActive bool) error
+	ExtendExpiry(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID, hours int) error
+	MarkUrlAsExpired(ctx context.Context, domain string, shortUrl string) error
+	AddMaxClicks(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID, clicks int) error
+	GetClicksUsed(ctx context.Context, urlId uuid.UUID) (int64, error)
+	SetClicksUsed(ctx context.Context, urlId uuid.UUID, used int64) error
+	GetUrlsActivatedBetween(ctx context.Context, from, to time.Time) ([]models.ShortenedUrlInfoRes, error)
+	GetActiveUrlByShortUrl(ctx context.Context, domain string, shortUrl string) (*models.ShortenedUrlInfoRes, error)
+	GetUrlOwnerInfo(ctx context.Context, domain string, shortUrl string) (createdAt time.Time, ownerName string, err error)
+	NextShortIDSequence(ctx context.Context) (int64, error)
+	UpdateUrl(ctx context.Context, urlId uuid.UUID, urlInfo *models.ShortenedUrlInfoReq) (*models.ShortenedUrlInfoRes, error)
+	SaveUrls(ctx context.Context, urls []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error)
+	BeginUrlBatch(ctx context.Context) (UrlBatch, error)
+	DeleteUrlRecords(ctx context.Context, userId uuid.UUID, urlRecordIds []uuid.UUID) error
+	EachWorkspaceUrl(ctx context.Context, workspaceId uuid.UUID, fn func(*models.ShortenedUrlInfoRes) error) error
+}
+
+// UrlBatch saves links in one transaction: none of them exist until Commit, and
+// Rollback after a failed SaveUrls leaves no trace of the others
+type UrlBatch interface {
+	SaveUrls(ctx context.Context, urls []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error)
+	Commit() error
+	Rollback() error
 }
 
 type UrlsPsqlImpl struct {
@@ -45,13 +82,32 @@ func (u *UrlsPsqlImpl) DeleteUrlRecord(ctx context.Context, UserId uuid.UUID, Ur
 	return err
 }
 
-func (u *UrlsPsqlImpl) SoftDeleteUrl(ctx context.Context, userId uuid.UUID, urlId uuid.UUID) error {
+// DeleteUrlRecords - Batch form of DeleteUrlRecord, used to roll back bulk creation.
+func (u *UrlsPsqlImpl) DeleteUrlRecords(ctx context.Context, userId uuid.UUID, urlRecordIds []uuid.UUID) error {
+	if len(urlRecordIds) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(urlRecordIds))
+	for i, id := range urlRecordIds {
+		ids[i] = id.String()
+	}
+
+	query := `DELETE FROM shortened_urls WHERE user_id = $1 AND id = ANY($2::uuid[])`
+	_, err := u.db.ExecContext(ctx, query, userId, ids)
+	if err != nil {
+		return fmt.Errorf("failed to delete url records: %w", err)
+	}
+	return nil
+}
+
+func (u *UrlsPsqlImpl) SoftDeleteUrl(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID) error {
 	query := `
        UPDATE shortened_urls
        SET is_active = false, expires_at = NOW()
-       WHERE user_id = $1 AND id = $2
+       WHERE workspace_id = $1 AND id = $2
     `
-	result, err := u.db.ExecContext(ctx, query, userId, urlId)
+	result, err := u.db.ExecContext(ctx, query, workspaceId, urlId)
 	if err != nil {
 		return fmt.Errorf("failed to soft delete URL: %w", err)
 	}
@@ -85,15 +141,29 @@ func (u *UrlsPsqlImpl) SaveUrl(ctx context.Context, urlInfo *models.ShortenedUrl
 
 	query := `
         INSERT INTO shortened_urls (
-            user_id, original_url, short_url, expires_at, is_active
+            user_id, original_url, short_url, expires_at, is_active, redirect_type, password_hash, max_clicks, activates_at,
+            routing_rules, variants, sticky_variants, query_params, forward_query, preview, domain, workspace_id
         ) VALUES (
-            $1, $2, $3, $4, $5
-        ) RETURNING id, user_id, original_url, short_url, expires_at, is_active, created_at;
+            $1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, 0), $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''), $17
+        ) RETURNING ` + urlInfoColumns + `;
     `
 
+	routingRules, err := jsonListParam(urlInfo.RoutingRules)
+	if err != nil {
+		return nil, nil, err
+	}
+	variants, err := jsonListParam(urlInfo.Variants)
+	if err != nil {
+		return nil, nil, err
+	}
+	queryParams, err := jsonListParam(urlInfo.QueryParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var response models.ShortenedUrlInfoRes
 
-	err = tx.QueryRowContext(
+	err = scanUrlInfo(tx.QueryRowContext(
 		ctx,
 		query,
 		urlInfo.UserId,
@@ -101,20 +171,24 @@ func (u *UrlsPsqlImpl) SaveUrl(ctx context.Context, urlInfo *models.ShortenedUrl
 		urlInfo.ShortUrl,
 		urlInfo.ExpiresAt,
 		urlInfo.IsActive,
-	).Scan(
-		&response.Id,
-		&response.UserId,
-		&response.OriginalUrl,
-		&response.ShortUrl,
-		&response.ExpiresAt,
-		&response.IsActive,
-		&response.CreatedAt,
-	)
+		urlInfo.RedirectType,
+		urlInfo.PasswordHash,
+		urlInfo.MaxClicks,
+		urlInfo.ActivatesAt,
+		routingRules,
+		variants,
+		urlInfo.StickyVariants,
+		queryParams,
+		urlInfo.ForwardQuery,
+		urlInfo.Preview,
+		urlInfo.Domain,
+		urlInfo.WorkspaceId,
+	), &response)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			return nil, nil, fmt.Errorf("short URL already exists: %w", err)
+			return nil, nil, fmt.Errorf("%w: %v", ErrShortUrlExists, err)
 		}
 		return nil, nil, fmt.Errorf("failed to insert shortened url: %w", err)
 	}
@@ -131,24 +205,109 @@ func (u *UrlsPsqlImpl) SaveUrl(ctx context.Context, urlInfo *models.ShortenedUrl
 	return &response, rollback, nil
 }
 
+// SaveUrls inserts a batch of links with a single multi-row INSERT. Rows whose short
+// URL is already taken are skipped, so only the links that were created are returned.
+func (u *UrlsPsqlImpl) SaveUrls(ctx context.Context, urls []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error) {
+	return saveUrls(ctx, u.db, urls)
+}
+
+// BeginUrlBatch starts a transaction for SaveUrls calls that must succeed together
+func (u *UrlsPsqlImpl) BeginUrlBatch(ctx context.Context) (UrlBatch, error) {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &urlBatch{Tx: tx}, nil
+}
+
+type urlBatch struct {
+	*sql.Tx
+}
+
+func (b *urlBatch) SaveUrls(ctx context.Context, urls []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error) {
+	return saveUrls(ctx, b.Tx, urls)
+}
+
+func saveUrls(ctx context.Context, db execQuerier, urls []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO shortened_urls (user_id, original_url, short_url, expires_at, is_active, redirect_type, password_hash, max_clicks, activates_at, routing_rules, variants, sticky_variants, query_params, forward_query, preview, domain, workspace_id) VALUES `)
+
+	args := make([]interface{}, 0, len(urls)*urlInsertColumns)
+	for i, urlInfo := range urls {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		base := i * urlInsertColumns
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, 0), $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10, base+11, base+12, base+13, base+14, base+15, base+16, base+17)
+
+		routingRules, err := jsonListParam(urlInfo.RoutingRules)
+		if err != nil {
+			return nil, err
+		}
+		variants, err := jsonListParam(urlInfo.Variants)
+		if err != nil {
+			return nil, err
+		}
+		queryParams, err := jsonListParam(urlInfo.QueryParams)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args,
+			urlInfo.UserId,
+			urlInfo.OriginalUrl,
+			urlInfo.ShortUrl,
+			urlInfo.ExpiresAt,
+			urlInfo.IsActive,
+			urlInfo.RedirectType,
+			urlInfo.PasswordHash,
+			urlInfo.MaxClicks,
+			urlInfo.ActivatesAt,
+			routingRules,
+			variants,
+			urlInfo.StickyVariants,
+			queryParams,
+			urlInfo.ForwardQuery,
+			urlInfo.Preview,
+			urlInfo.Domain,
+			urlInfo.WorkspaceId,
+		)
+	}
+	query.WriteString(` ON CONFLICT DO NOTHING
+		RETURNING ` + urlInfoColumns)
+
+	rows, err := db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert shortened url batch: %w", err)
+	}
+	defer rows.Close()
+
+	saved := make([]models.ShortenedUrlInfoRes, 0, len(urls))
+	for rows.Next() {
+		var response models.ShortenedUrlInfoRes
+		if err := scanUrlInfo(rows, &response); err != nil {
+			return nil, fmt.Errorf("failed to scan inserted url: %w", err)
+		}
+		saved = append(saved, response)
+	}
+	return saved, rows.Err()
+}
+
 func (u *UrlsPsqlImpl) GetUrlInfoByUserIdAndShortUrl(ctx context.Context, userId uuid.UUID, shortUrl string) (*models.ShortenedUrlInfoRes, error) {
 	query := `
-		SELECT id, user_id, original_url, short_url, expires_at, is_active, created_at
+		SELECT ` + urlInfoColumns + `
 		FROM shortened_urls
 		WHERE user_id = $1 AND short_url = $2;
 	`
 
 	var urlInfo models.ShortenedUrlInfoRes
 
-	err := u.db.QueryRowContext(ctx, query, userId, shortUrl).Scan(
-		&urlInfo.Id,
-		&urlInfo.UserId,
-		&urlInfo.OriginalUrl,
-		&urlInfo.ShortUrl,
-		&urlInfo.ExpiresAt,
-		&urlInfo.IsActive,
-		&urlInfo.CreatedAt,
-	)
+	err := scanUrlInfo(u.db.QueryRowContext(ctx, query, userId, shortUrl), &urlInfo)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// Not found — return a typed error for clarity
@@ -161,17 +320,15 @@ func (u *UrlsPsqlImpl) GetUrlInfoByUserIdAndShortUrl(ctx context.Context, userId
 	return &urlInfo, nil
 }
 
-func (u *UrlsPsqlImpl) GetUserUrls(ctx context.Context, userID uuid.UUID, offset, limit int, isActive *bool) ([]models.ShortenedUrlInfoRes, error) {
+func (u *UrlsPsqlImpl) GetWorkspaceUrls(ctx context.Context, workspaceId uuid.UUID, offset, limit int, status string) ([]models.ShortenedUrlInfoRes, error) {
 	var urls []models.ShortenedUrlInfoRes
 
 	query := u.gormDB.WithContext(ctx).
 		Table("shortened_urls").
-		Where("user_id = ?", userID)
+		Where("workspace_id = ?", workspaceId)
 
-	// Apply active status filter if provided
-	if isActive != nil {
-		query = query.Where("is_active = ?", *isActive)
-	}
+	// Apply status filter if provided
+	query = filterUrlStatus(query, status)
 
 	// Apply pagination and order by creation date (newest first)
 	err := query.
@@ -184,20 +341,65 @@ func (u *UrlsPsqlImpl) GetUserUrls(ctx context.Context, userID uuid.UUID, offset
 		return nil, err
 	}
 
+	now := time.Now()
+	for i := range urls {
+		urls[i].IsProtected = urls[i].PasswordHash != ""
+		urls[i].Status = urls[i].CurrentStatus(now)
+	}
+
 	return urls, nil
 }
 
-func (u *UrlsPsqlImpl) CountUserUrls(ctx context.Context, userID uuid.UUID, isActive *bool) (int64, error) {
+// filterUrlStatus narrows a shortened_urls query to one list API state
+func filterUrlStatus(query *gorm.DB, status string) *gorm.DB {
+	switch status {
+	case models.UrlStatusActive:
+		return query.Where("is_active = true AND (activates_at IS NULL OR activates_at <= NOW())")
+	case models.UrlStatusInactive:
+		return query.Where("is_active = false")
+	case models.UrlStatusScheduled:
+		return query.Where("is_active = true AND activates_at > NOW()")
+	}
+	return query
+}
+
+// EachWorkspaceUrl streams every link of a workspace, oldest first, without loading
+// them all into memory. An error returned by fn stops the iteration and is passed back.
+func (u *UrlsPsqlImpl) EachWorkspaceUrl(ctx context.Context, workspaceId uuid.UUID, fn func(*models.ShortenedUrlInfoRes) error) error {
+	query := `
+		SELECT ` + urlInfoColumns + `
+		FROM shortened_urls
+		WHERE workspace_id = $1
+		ORDER BY created_at, id;
+	`
+
+	rows, err := u.db.QueryContext(ctx, query, workspaceId)
+	if err != nil {
+		return fmt.Errorf("failed to query workspace urls: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var urlInfo models.ShortenedUrlInfoRes
+		if err := scanUrlInfo(rows, &urlInfo); err != nil {
+			return fmt.Errorf("failed to scan workspace url: %w", err)
+		}
+		if err := fn(&urlInfo); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (u *UrlsPsqlImpl) CountWorkspaceUrls(ctx context.Context, workspaceId uuid.UUID, status string) (int64, error) {
 	var count int64
 
 	query := u.gormDB.WithContext(ctx).
 		Table("shortened_urls").
-		Where("user_id = ?", userID)
+		Where("workspace_id = ?", workspaceId)
 
-	// Apply active status filter if provided
-	if isActive != nil {
-		query = query.Where("is_active = ?", *isActive)
-	}
+	// Apply status filter if provided
+	query = filterUrlStatus(query, status)
 
 	err := query.Count(&count).Error
 	if err != nil {
@@ -207,28 +409,22 @@ func (u *UrlsPsqlImpl) CountUserUrls(ctx context.Context, userID uuid.UUID, isAc
 	return count, nil
 }
 
-func (u *UrlsPsqlImpl) GetUrlInfoByUserIdAndUrlRecordId(ctx context.Context, userId uuid.UUID, urlRecordId uuid.UUID) (*models.ShortenedUrlInfoRes, error) {
+// GetUrlInfoByRecordId loads a link by id whatever its workspace; callers check
+// the requesting user's role in urlInfo.WorkspaceId
+func (u *UrlsPsqlImpl) GetUrlInfoByRecordId(ctx context.Context, urlRecordId uuid.UUID) (*models.ShortenedUrlInfoRes, error) {
 	query := `
-		SELECT id, user_id, original_url, short_url, expires_at, is_active, created_at
+		SELECT ` + urlInfoColumns + `
 		FROM shortened_urls
-		WHERE user_id = $1 AND id = $2;
+		WHERE id = $1;
 	`
 
 	var urlInfo models.ShortenedUrlInfoRes
 
-	err := u.db.QueryRowContext(ctx, query, userId, urlRecordId).Scan(
-		&urlInfo.Id,
-		&urlInfo.UserId,
-		&urlInfo.OriginalUrl,
-		&urlInfo.ShortUrl,
-		&urlInfo.ExpiresAt,
-		&urlInfo.IsActive,
-		&urlInfo.CreatedAt,
-	)
+	err := scanUrlInfo(u.db.QueryRowContext(ctx, query, urlRecordId), &urlInfo)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// Not found — return a typed error for clarity
-			return nil, fmt.Errorf("no URL found for user ID %s and short URL %s: %w", userId.String(), urlRecordId, err)
+			return nil, fmt.Errorf("no URL found for ID %s: %w", urlRecordId, err)
 		}
 		// Log or wrap unexpected DB error
 		return nil, fmt.Errorf("failed to fetch URL info from DB: %w", err)
@@ -237,32 +433,20 @@ func (u *UrlsPsqlImpl) GetUrlInfoByUserIdAndUrlRecordId(ctx context.Context, use
 	return &urlInfo, nil
 }
 
-func (u *UrlsPsqlImpl) SetUrlState(ctx context.Context, userId uuid.UUID, urlId uuid.UUID, isActive bool) error {
+func (u *UrlsPsqlImpl) SetUrlState(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID, isActive bool) error {
 	query := `
 		UPDATE shortened_urls
 		SET is_active = $1
-		WHERE user_id = $2 AND id = $3
+		WHERE workspace_id = $2 AND id = $3
 	`
-	_, err := u.db.ExecContext(ctx, query, isActive, userId, urlId)
+	_, err := u.db.ExecContext(ctx, query, isActive, workspaceId, urlId)
 	if err != nil {
 		return fmt.Errorf("failed to update URL state: %w", err)
 	}
 	return nil
 }
 
-func (u *UrlsPsqlImpl) UrlRecordExists(ctx context.Context, urlID uuid.UUID) (bool, error) {
-	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM shortened_urls WHERE id = $1)`
-
-	err := u.db.QueryRowContext(ctx, query, urlID).Scan(&exists)
-	if err != nil {
-		return false, err
-	}
-
-	return exists, nil
-}
-
-func (u *UrlsPsqlImpl) ExtendExpiry(ctx context.Context, userId uuid.UUID, urlId uuid.UUID, hours int) error {
+func (u *UrlsPsqlImpl) ExtendExpiry(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID, hours int) error {
 	tx, err := u.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -273,9 +457,9 @@ func (u *UrlsPsqlImpl) ExtendExpiry(ctx context.Context, userId uuid.UUID, urlId
 		}
 	}()
 
-	query := `UPDATE shortened_urls SET expires_at = expires_at + make_interval(hours => $1) WHERE id = $2 AND user_id = $3`
+	query := `UPDATE shortened_urls SET expires_at = expires_at + make_interval(hours => $1) WHERE id = $2 AND workspace_id = $3`
 
-	result, err := tx.ExecContext(ctx, query, hours, urlId, userId)
+	result, err := tx.ExecContext(ctx, query, hours, urlId, workspaceId)
 	if err != nil {
 		return err
 	}
@@ -292,15 +476,15 @@ func (u *UrlsPsqlImpl) ExtendExpiry(ctx context.Context, userId uuid.UUID, urlId
 	return tx.Commit()
 }
 
-func (u *UrlsPsqlImpl) MarkUrlAsExpired(ctx context.Context, shortUrl string) error {
+func (u *UrlsPsqlImpl) MarkUrlAsExpired(ctx context.Context, domain string, shortUrl string) error {
 	query := `
         UPDATE shortened_urls 
         SET is_active = false, 
             expires_at = NOW() 
-        WHERE short_url = $1 AND is_active = true
+        WHERE short_url = $1 AND ` + fmt.Sprintf(sameDomain, "$2") + ` AND is_active = true
     `
 
-	result, err := u.db.ExecContext(ctx, query, shortUrl)
+	result, err := u.db.ExecContext(ctx, query, shortUrl, domain)
 	if err != nil {
 		return fmt.Errorf("failed to mark URL as expired: %w", err)
 	}
@@ -311,8 +495,249 @@ func (u *UrlsPsqlImpl) MarkUrlAsExpired(ctx context.Context, shortUrl string) er
 	}
 
 	if rowsAffected == 0 {
-		log.Printf("No active URL found for shortUrl: %s", shortUrl)
+		log.Printf("No active URL found for shortUrl: %s", models.LinkKey(domain, shortUrl))
 	}
 
 	return nil
 }
+
+// AddMaxClicks raises the click limit of a click-limited link by clicks.
+// Links without a limit are left unlimited.
+func (u *UrlsPsqlImpl) AddMaxClicks(ctx context.Context, workspaceId uuid.UUID, urlId uuid.UUID, clicks int) error {
+	query := `UPDATE shortened_urls SET max_clicks = max_clicks + $1 WHERE id = $2 AND workspace_id = $3 AND max_clicks IS NOT NULL`
+
+	result, err := u.db.ExecContext(ctx, query, clicks, urlId, workspaceId)
+	if err != nil {
+		return fmt.Errorf("failed to add clicks: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetClicksUsed - Used to seed the Redis click counter of a click-limited link.
+func (u *UrlsPsqlImpl) GetClicksUsed(ctx context.Context, urlId uuid.UUID) (int64, error) {
+	var used int64
+	err := u.db.QueryRowContext(ctx, `SELECT clicks_used FROM shortened_urls WHERE id = $1`, urlId).Scan(&used)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read clicks used: %w", err)
+	}
+	return used, nil
+}
+
+// SetClicksUsed stores the Redis click counter of a link after a click. The count
+// never goes down, so clicks stored out of order cannot give clicks back.
+func (u *UrlsPsqlImpl) SetClicksUsed(ctx context.Context, urlId uuid.UUID, used int64) error {
+	query := `UPDATE shortened_urls SET clicks_used = GREATEST(clicks_used, $1) WHERE id = $2`
+	if _, err := u.db.ExecContext(ctx, query, used, urlId); err != nil {
+		return fmt.Errorf("failed to store clicks used: %w", err)
+	}
+	return nil
+}
+
+// GetActiveUrlByShortUrl - Used to re-warm Redis when a redirect misses the cache.
+// Only rows that are active and not yet expired are returned.
+func (u *UrlsPsqlImpl) GetActiveUrlByShortUrl(ctx context.Context, domain string, shortUrl string) (*models.ShortenedUrlInfoRes, error) {
+	query := `
+		SELECT ` + urlInfoColumns + `
+		FROM shortened_urls
+		WHERE short_url = $1 AND ` + fmt.Sprintf(sameDomain, "$2") + ` AND is_active = true AND expires_at > NOW();
+	`
+
+	var urlInfo models.ShortenedUrlInfoRes
+
+	err := scanUrlInfo(u.db.QueryRowContext(ctx, query, shortUrl, domain), &urlInfo)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no active URL found for short URL %s: %w", models.LinkKey(domain, shortUrl), err)
+		}
+		return nil, fmt.Errorf("failed to fetch URL info from DB: %w", err)
+	}
+
+	return &urlInfo, nil
+}
+
+// GetUrlsActivatedBetween - Used by the activation scheduler to cache scheduled links
+// whose activation time fell in (from, to] and that are still live at to.
+func (u *UrlsPsqlImpl) GetUrlsActivatedBetween(ctx context.Context, from, to time.Time) ([]models.ShortenedUrlInfoRes, error) {
+	query := `
+		SELECT ` + urlInfoColumns + `
+		FROM shortened_urls
+		WHERE activates_at > $1 AND activates_at <= $2 AND is_active = true AND expires_at > $2
+		ORDER BY activates_at;
+	`
+
+	rows, err := u.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activated urls: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.ShortenedUrlInfoRes
+	for rows.Next() {
+		var urlInfo models.ShortenedUrlInfoRes
+		if err := scanUrlInfo(rows, &urlInfo); err != nil {
+			return nil, fmt.Errorf("failed to scan activated url: %w", err)
+		}
+		urls = append(urls, urlInfo)
+	}
+	return urls, rows.Err()
+}
+
+// GetUrlOwnerInfo - Used by the preview page to show when and by whom a link was created.
+func (u *UrlsPsqlImpl) GetUrlOwnerInfo(ctx context.Context, domain string, shortUrl string) (time.Time, string, error) {
+	query := `
+		SELECT s.created_at, COALESCE(u.name, '')
+		FROM shortened_urls s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.short_url = $1 AND s.` + fmt.Sprintf(sameDomain, "$2") + `;
+	`
+
+	var (
+		createdAt time.Time
+		ownerName string
+	)
+	if err := u.db.QueryRowContext(ctx, query, shortUrl, domain).Scan(&createdAt, &ownerName); err != nil {
+		return time.Time{}, "", err
+	}
+	return createdAt, ownerName, nil
+}
+
+// NextShortIDSequence - Feeds the sequence based short ID generators.
+func (u *UrlsPsqlImpl) NextShortIDSequence(ctx context.Context) (int64, error) {
+	var next int64
+	err := u.db.QueryRowContext(ctx, `SELECT nextval('short_url_seq')`).Scan(&next)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next short URL sequence value: %w", err)
+	}
+	return next, nil
+}
+
+// UpdateUrl overwrites the editable fields of a link in urlInfo.WorkspaceId.
+// A short URL that is already in use returns ErrShortUrlExists. Reactivating an
+// inactive link starts its clicks used over at zero.
+func (u *UrlsPsqlImpl) UpdateUrl(ctx context.Context, urlId uuid.UUID, urlInfo *models.ShortenedUrlInfoReq) (*models.ShortenedUrlInfoRes, error) {
+	query := `
+		UPDATE shortened_urls
+		SET original_url = $1, short_url = $2, expires_at = $3, is_active = $4, redirect_type = $5,
+			password_hash = NULLIF($6, ''), activates_at = $7, routing_rules = $8, variants = $9,
+			sticky_variants = $10, query_params = $11, forward_query = $12, preview = $13, updated_at = NOW(),
+			clicks_used = CASE WHEN NOT is_active AND $4 THEN 0 ELSE clicks_used END
+		WHERE id = $14 AND workspace_id = $15
+		RETURNING ` + urlInfoColumns + `;
+	`
+
+	routingRules, err := jsonListParam(urlInfo.RoutingRules)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := jsonListParam(urlInfo.Variants)
+	if err != nil {
+		return nil, err
+	}
+	queryParams, err := jsonListParam(urlInfo.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var response models.ShortenedUrlInfoRes
+
+	err = scanUrlInfo(u.db.QueryRowContext(
+		ctx,
+		query,
+		urlInfo.OriginalUrl,
+		urlInfo.ShortUrl,
+		urlInfo.ExpiresAt,
+		urlInfo.IsActive,
+		urlInfo.RedirectType,
+		urlInfo.PasswordHash,
+		urlInfo.ActivatesAt,
+		routingRules,
+		variants,
+		urlInfo.StickyVariants,
+		queryParams,
+		urlInfo.ForwardQuery,
+		urlInfo.Preview,
+		urlId,
+		urlInfo.WorkspaceId,
+	), &response)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("%w: %v", ErrShortUrlExists, err)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update shortened url: %w", err)
+	}
+
+	return &response, nil
+}
+
+// scanUrlInfo reads a row selected with urlInfoColumns
+func scanUrlInfo(row rowScanner, urlInfo *models.ShortenedUrlInfoRes) error {
+	var routingRules, variants, queryParams []byte
+	err := row.Scan(
+		&urlInfo.Id,
+		&urlInfo.UserId,
+		&urlInfo.OriginalUrl,
+		&urlInfo.ShortUrl,
+		&urlInfo.ExpiresAt,
+		&urlInfo.IsActive,
+		&urlInfo.RedirectType,
+		&urlInfo.CreatedAt,
+		&urlInfo.PasswordHash,
+		&urlInfo.MaxClicks,
+		&urlInfo.ActivatesAt,
+		&routingRules,
+		&variants,
+		&urlInfo.StickyVariants,
+		&queryParams,
+		&urlInfo.ForwardQuery,
+		&urlInfo.Preview,
+		&urlInfo.Domain,
+		&urlInfo.WorkspaceId,
+		&urlInfo.DisabledAt,
+		&urlInfo.ClicksUsed,
+	)
+	if err != nil {
+		return err
+	}
+	urlInfo.IsProtected = urlInfo.PasswordHash != ""
+	urlInfo.Status = urlInfo.CurrentStatus(time.Now())
+	if len(routingRules) > 0 {
+		if err := json.Unmarshal(routingRules, &urlInfo.RoutingRules); err != nil {
+			return fmt.Errorf("failed to decode routing rules: %w", err)
+		}
+	}
+	if len(variants) > 0 {
+		if err := json.Unmarshal(variants, &urlInfo.Variants); err != nil {
+			return fmt.Errorf("failed to decode variants: %w", err)
+		}
+	}
+	if len(queryParams) > 0 {
+		if err := json.Unmarshal(queryParams, &urlInfo.QueryParams); err != nil {
+			return fmt.Errorf("failed to decode query params: %w", err)
+		}
+	}
+	return nil
+}
+
+// jsonListParam encodes a list for a JSONB column such as routing_rules; an empty list is NULL
+func jsonListParam[T any](items []T) (interface{}, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %T: %w", items, err)
+	}
+	return string(encoded), nil
+}
@@ -1,20 +1,79 @@
 package repositories
 
 import (
+	"U-235/models"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// clickCounterPrefix namespaces the per-link click counters of click-limited links
+const clickCounterPrefix = "clicks:"
+
+var (
+	// ErrClickCounterMissing is returned by ConsumeClick when the counter has to be
+	// seeded from Postgres first, e.g. on the first click or after a cache flush.
+	ErrClickCounterMissing = errors.New("click counter missing")
+	// ErrClickLimitReached is returned by ConsumeClick once every click is used up
+	ErrClickLimitReached = errors.New("click limit reached")
+)
+
+// consumeClickScript counts one click unless the limit is reached.
+// Returns the clicks used including this one, 0 at the limit, -1 without a counter.
+var consumeClickScript = redis.NewScript(`
+local used = redis.call('GET', KEYS[1])
+if not used then
+	return -1
+end
+if tonumber(used) >= tonumber(ARGV[1]) then
+	return 0
+end
+return redis.call('INCR', KEYS[1])
+`)
+
+// seedClickCounterScript creates a click counter that lives as long as its link
+// key. An existing counter is left alone since it is always more recent.
+var seedClickCounterScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl == -2 then
+	return 0
+end
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl, 'NX')
+else
+	redis.call('SET', KEYS[1], ARGV[1], 'NX')
+end
+return 1
+`)
+
+// RedisRepo caches links by key. Despite the parameter names, keys are link keys
+// (see models.LinkKey): the bare slug on the default domains, "<host>/<slug>" on a
+// custom domain.
 type RedisRepo interface {
 	GetOriginalUrl(ctx context.Context, shortUrl string) (string, bool)
+	GetUrl(ctx context.Context, shortUrl string) (*models.CachedUrl, bool)
 	GetShortUrl(ctx context.Context, originalUrl string) (string, bool)
-	SaveUrl(ctx context.Context, originalUrl string, shortUrl string, time time.Duration) error
+	SaveUrl(ctx context.Context, shortUrl string, entry *models.CachedUrl, time time.Duration) error
 	ExistsInRedis(ctx context.Context, shortUrl string) (bool, error)
+	SaveUrls(ctx context.Context, writes []CachedUrlWrite) error
 	DeleteKeys(ctx context.Context, shortUrl string) error
 	ExtendExpiry(ctx context.Context, originalUrl string, shortUrl string, duration time.Duration) error
+	ConsumeClick(ctx context.Context, shortUrl string, maxClicks int) (int64, error)
+	SeedClickCounter(ctx context.Context, shortUrl string, used int64) error
+	ResetClickCounter(ctx context.Context, shortUrl string) error
+	GetClickCounts(ctx context.Context, shortUrls []string) (map[string]int64, error)
+}
+
+// CachedUrlWrite is one entry of a pipelined SaveUrls call
+type CachedUrlWrite struct {
+	ShortUrl string
+	Entry    *models.CachedUrl
+	TTL      time.Duration
 }
 
 type UrlRedis struct {
@@ -34,15 +93,32 @@ func NewUrlRedis(client *redis.Client) (RedisRepo, error) {
 }
 
 func (u *UrlRedis) GetOriginalUrl(ctx context.Context, shortUrl string) (string, bool) {
-	OriginalUrl, err := u.RedisClient.Get(ctx, shortUrl).Result()
-	if err != nil {
+	entry, ok := u.GetUrl(ctx, shortUrl)
+	if !ok {
 		return "", false
 	}
-	if OriginalUrl == "" {
-		return "", false
+	return entry.OriginalUrl, true
+}
+
+func (u *UrlRedis) GetUrl(ctx context.Context, shortUrl string) (*models.CachedUrl, bool) {
+	value, err := u.RedisClient.Get(ctx, shortUrl).Result()
+	if err != nil {
+		return nil, false
+	}
+	if value == "" {
+		return nil, false
+	}
+
+	// Keys written before links carried settings hold the bare original URL
+	if !strings.HasPrefix(value, "{") {
+		return &models.CachedUrl{OriginalUrl: value}, true
 	}
-	return OriginalUrl, true
 
+	var entry models.CachedUrl
+	if err := json.Unmarshal([]byte(value), &entry); err != nil || entry.OriginalUrl == "" {
+		return nil, false
+	}
+	return &entry, true
 }
 
 func (u *UrlRedis) GetShortUrl(ctx context.Context, originalUrl string) (string, bool) {
@@ -59,12 +135,36 @@ func (u *UrlRedis) GetShortUrl(ctx context.Context, originalUrl string) (string,
 	return shortUrl, true
 }
 
-func (u *UrlRedis) SaveUrl(ctx context.Context, originalUrl string, shortUrl string, ExpiryTime time.Duration) error {
+func (u *UrlRedis) SaveUrl(ctx context.Context, shortUrl string, entry *models.CachedUrl, ExpiryTime time.Duration) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached url: %w", err)
+	}
+
 	// Use a pipeline for atomic operations
 	pipe := u.RedisClient.Pipeline()
 
-	// Store shortID -> originalURL mapping (for redirects)
-	pipe.Set(ctx, shortUrl, originalUrl, ExpiryTime)
+	// Store shortID -> link settings mapping (for redirects)
+	pipe.Set(ctx, shortUrl, value, ExpiryTime)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// SaveUrls stores many links in one round trip
+func (u *UrlRedis) SaveUrls(ctx context.Context, writes []CachedUrlWrite) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	pipe := u.RedisClient.Pipeline()
+	for _, w := range writes {
+		value, err := json.Marshal(w.Entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode cached url: %w", err)
+		}
+		pipe.Set(ctx, w.ShortUrl, value, w.TTL)
+	}
 
 	_, err := pipe.Exec(ctx)
 	return err
@@ -76,12 +176,7 @@ func (u *UrlRedis) ExistsInRedis(ctx context.Context, shortUrl string) (bool, er
 	if err != nil {
 		return false, fmt.Errorf("error checking key: %v", err)
 	}
-	if ShortExists == 1 {
-		return true, nil
-	} else {
-		return false, fmt.Errorf("key not exists")
-	}
-
+	return ShortExists == 1, nil
 }
 
 func (u *UrlRedis) DeleteKeys(ctx context.Context, shortUrl string) error {
@@ -116,6 +211,17 @@ func (u *UrlRedis) ExtendExpiry(ctx context.Context, originalUrl string, shortUr
 		return err
 	}
 
+	// Keep the click counter of a click-limited link alive as long as the link
+	counterTTL, err := u.RedisClient.TTL(ctx, clickCounterPrefix+shortUrl).Result()
+	if err != nil {
+		return err
+	}
+	if counterTTL > 0 {
+		if err := u.RedisClient.Expire(ctx, clickCounterPrefix+shortUrl, counterTTL+duration).Err(); err != nil {
+			return err
+		}
+	}
+
 	// Handle originalUrl expiry
 	ttl2, err := u.RedisClient.TTL(ctx, originalUrl).Result()
 	if err != nil {
@@ -129,3 +235,64 @@ func (u *UrlRedis) ExtendExpiry(ctx context.Context, originalUrl string, shortUr
 		return u.RedisClient.Expire(ctx, originalUrl, newExpiry2).Err()
 	}
 }
+
+// ConsumeClick atomically counts one click of a link limited to maxClicks and
+// returns how many clicks are used including this one.
+func (u *UrlRedis) ConsumeClick(ctx context.Context, shortUrl string, maxClicks int) (int64, error) {
+	used, err := consumeClickScript.Run(ctx, u.RedisClient, []string{clickCounterPrefix + shortUrl}, maxClicks).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count click: %w", err)
+	}
+	switch used {
+	case -1:
+		return 0, ErrClickCounterMissing
+	case 0:
+		return 0, ErrClickLimitReached
+	}
+	return used, nil
+}
+
+// SeedClickCounter creates the click counter of a link with the clicks already used
+func (u *UrlRedis) SeedClickCounter(ctx context.Context, shortUrl string, used int64) error {
+	keys := []string{clickCounterPrefix + shortUrl, shortUrl}
+	if err := seedClickCounterScript.Run(ctx, u.RedisClient, keys, used).Err(); err != nil {
+		return fmt.Errorf("failed to seed click counter: %w", err)
+	}
+	return nil
+}
+
+// ResetClickCounter starts the click counter of a cached link over at zero
+func (u *UrlRedis) ResetClickCounter(ctx context.Context, shortUrl string) error {
+	if err := u.RedisClient.Del(ctx, clickCounterPrefix+shortUrl).Err(); err != nil {
+		return fmt.Errorf("failed to reset click counter: %w", err)
+	}
+	return u.SeedClickCounter(ctx, shortUrl, 0)
+}
+
+// GetClickCounts returns the clicks used per short URL; links without a counter are left out
+func (u *UrlRedis) GetClickCounts(ctx context.Context, shortUrls []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(shortUrls))
+	if len(shortUrls) == 0 {
+		return counts, nil
+	}
+
+	keys := make([]string, len(shortUrls))
+	for i, shortUrl := range shortUrls {
+		keys[i] = clickCounterPrefix + shortUrl
+	}
+
+	values, err := u.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read click counters: %w", err)
+	}
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if used, err := strconv.ParseInt(str, 10, 64); err == nil {
+			counts[shortUrls[i]] = used
+		}
+	}
+	return counts, nil
+}
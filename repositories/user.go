@@ -35,7 +35,7 @@ func (u *userRepo) UserRegistration(name, email, passwordHashed string, ctx cont
 
 	// If no error, user exists
 	if err == nil {
-		return nil, errors.New("user with this email already exists")
+		return nil, models.ErrUserExists
 	}
 
 	// If error is not "no rows", return the error
@@ -48,7 +48,7 @@ func (u *userRepo) UserRegistration(name, email, passwordHashed string, ctx cont
 	var user models.User
 	insertQuery := `INSERT INTO users (name, email, password) 
                     VALUES ($1, $2, $3) 
-                    RETURNING id, name, email, created_at`
+                    RETURNING id, name, email, role, created_at`
 
 	err = u.db.QueryRowContext(
 		ctx,
@@ -56,7 +56,7 @@ func (u *userRepo) UserRegistration(name, email, passwordHashed string, ctx cont
 		name,
 		email,
 		passwordHashed,
-	).Scan(&user.Id, &user.Name, &user.Email, &user.CreatedAt)
+	).Scan(&user.Id, &user.Name, &user.Email, &user.Role, &user.CreatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
@@ -65,37 +65,46 @@ func (u *userRepo) UserRegistration(name, email, passwordHashed string, ctx cont
 	return &user, nil
 }
 
+// UserLogin checks the credentials; suspended users get models.ErrAccountSuspended,
+// but only once the password matched so that suspension does not reveal accounts
 func (u *userRepo) UserLogin(email, password string, ctx context.Context) (uuid.UUID, error) {
-	query := `SELECT id, password FROM users WHERE email = $1`
+	query := `SELECT id, password, suspended_at FROM users WHERE email = $1`
 	var (
 		userID       uuid.UUID
 		hashedPasswd string
+		suspendedAt  sql.NullTime
 	)
 
-	err := u.db.QueryRowContext(ctx, query, email).Scan(&userID, &hashedPasswd)
+	err := u.db.QueryRowContext(ctx, query, email).Scan(&userID, &hashedPasswd, &suspendedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return uuid.Nil, errors.New("invalid email or password")
+			return uuid.Nil, models.ErrInvalidCredentials
 		}
 		return uuid.Nil, fmt.Errorf("database error: %w", err)
 	}
 
 	err = utils.VerifyPassword(hashedPasswd, password)
 	if err != nil {
-		return uuid.Nil, errors.New("invalid email or password")
+		return uuid.Nil, models.ErrInvalidCredentials
+	}
+
+	if suspendedAt.Valid {
+		return uuid.Nil, models.ErrAccountSuspended
 	}
 
 	return userID, nil
 }
 
 func (u *userRepo) GetUserByID(userID uuid.UUID, ctx context.Context) (*models.User, error) {
-	query := `SELECT id, name, email, created_at, updated_at FROM users WHERE id = $1`
+	query := `SELECT id, name, email, role, suspended_at, created_at, updated_at FROM users WHERE id = $1`
 	var user models.User
 
 	err := u.db.QueryRowContext(ctx, query, userID).Scan(
 		&user.Id,
 		&user.Name,
 		&user.Email,
+		&user.Role,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -117,7 +126,7 @@ func (u *userRepo) UserProfileService(userId uuid.UUID, ctx context.Context) (*m
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("user not found")
+			return nil, models.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to fetch user profile: %w", err)
 	}
@@ -0,0 +1,365 @@
+package repositories
+
+import (
+	"U-235/core/workspaces"
+	"U-235/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+)
+
+// personalWorkspaceName is the name every personal workspace is created with
+const personalWorkspaceName = "Personal"
+
+var (
+	// ErrLastOwner is returned by RemoveMember for the only owner of a workspace
+	ErrLastOwner = errors.New("workspace has no other owner")
+	// ErrAlreadyMember is returned by AcceptInvitation when the user is already a member
+	ErrAlreadyMember = errors.New("user is already a workspace member")
+	// ErrInvitationEmail is returned by AcceptInvitation when the invitation was sent to another email
+	ErrInvitationEmail = errors.New("invitation was sent to another email")
+)
+
+type WorkspaceRepo interface {
+	CreateWorkspace(ctx context.Context, userId uuid.UUID, name string) (*models.Workspace, error)
+	PersonalWorkspace(ctx context.Context, userId uuid.UUID) (uuid.UUID, error)
+	ListWorkspaces(ctx context.Context, userId uuid.UUID) ([]models.Workspace, error)
+	GetMemberRole(ctx context.Context, workspaceId uuid.UUID, userId uuid.UUID) (string, error)
+	ListMembers(ctx context.Context, workspaceId uuid.UUID) ([]models.WorkspaceMember, error)
+	RemoveMember(ctx context.Context, workspaceId uuid.UUID, userId uuid.UUID) error
+	CreateInvitation(ctx context.Context, invitation *models.WorkspaceInvitation, tokenHash string) error
+	ListInvitations(ctx context.Context, workspaceId uuid.UUID) ([]models.WorkspaceInvitation, error)
+	DeleteInvitation(ctx context.Context, workspaceId uuid.UUID, invitationId uuid.UUID) error
+	AcceptInvitation(ctx context.Context, tokenHash string, userId uuid.UUID, email string) (*models.Workspace, error)
+}
+
+type WorkspacePsqlImpl struct {
+	db *sql.DB
+}
+
+func NewWorkspacePsql(db *sql.DB) WorkspaceRepo {
+	return &WorkspacePsqlImpl{
+		db: db,
+	}
+}
+
+// CreateWorkspace creates a shared workspace with userId as its owner
+func (w *WorkspacePsqlImpl) CreateWorkspace(ctx context.Context, userId uuid.UUID, name string) (*models.Workspace, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	workspace := models.Workspace{Name: name, Role: workspaces.RoleOwner}
+	err = tx.QueryRowContext(ctx, `INSERT INTO workspaces (name) VALUES ($1) RETURNING id, created_at`, name).
+		Scan(&workspace.Id, &workspace.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	if err := addMember(ctx, tx, workspace.Id, userId, workspaces.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &workspace, nil
+}
+
+// PersonalWorkspace returns the id of the user's personal workspace, creating it
+// with the user as owner on first use
+func (w *WorkspacePsqlImpl) PersonalWorkspace(ctx context.Context, userId uuid.UUID) (uuid.UUID, error) {
+	query := `SELECT id FROM workspaces WHERE personal_user_id = $1`
+
+	var workspaceId uuid.UUID
+	err := w.db.QueryRowContext(ctx, query, userId).Scan(&workspaceId)
+	if err == nil {
+		return workspaceId, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, fmt.Errorf("failed to fetch personal workspace: %w", err)
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO workspaces (name, personal_user_id) VALUES ($1, $2)
+		ON CONFLICT (personal_user_id) DO NOTHING
+		RETURNING id
+	`, personalWorkspaceName, userId).Scan(&workspaceId)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A concurrent request created it first
+		if err := w.db.QueryRowContext(ctx, query, userId).Scan(&workspaceId); err != nil {
+			return uuid.Nil, fmt.Errorf("failed to fetch personal workspace: %w", err)
+		}
+		return workspaceId, nil
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create personal workspace: %w", err)
+	}
+	if err := addMember(ctx, tx, workspaceId, userId, workspaces.RoleOwner); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return workspaceId, nil
+}
+
+// ListWorkspaces returns the workspaces the user is a member of with the user's
+// role, personal workspace first
+func (w *WorkspacePsqlImpl) ListWorkspaces(ctx context.Context, userId uuid.UUID) ([]models.Workspace, error) {
+	query := `
+		SELECT w.id, w.name, w.personal_user_id IS NOT NULL, m.role, w.created_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.personal_user_id IS NULL, w.name, w.created_at
+	`
+	rows, err := w.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.Workspace, 0)
+	for rows.Next() {
+		var workspace models.Workspace
+		if err := rows.Scan(&workspace.Id, &workspace.Name, &workspace.Personal, &workspace.Role, &workspace.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace: %w", err)
+		}
+		list = append(list, workspace)
+	}
+	return list, rows.Err()
+}
+
+// GetMemberRole returns the user's role in the workspace, or sql.ErrNoRows for non-members
+func (w *WorkspacePsqlImpl) GetMemberRole(ctx context.Context, workspaceId uuid.UUID, userId uuid.UUID) (string, error) {
+	query := `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+
+	var role string
+	if err := w.db.QueryRowContext(ctx, query, workspaceId, userId).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to fetch workspace role: %w", err)
+	}
+	return role, nil
+}
+
+func (w *WorkspacePsqlImpl) ListMembers(ctx context.Context, workspaceId uuid.UUID) ([]models.WorkspaceMember, error) {
+	query := `
+		SELECT m.user_id, COALESCE(u.name, ''), u.email, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at
+	`
+	rows, err := w.db.QueryContext(ctx, query, workspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]models.WorkspaceMember, 0)
+	for rows.Next() {
+		var member models.WorkspaceMember
+		if err := rows.Scan(&member.UserId, &member.Name, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace member: %w", err)
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// RemoveMember takes the user out of the workspace; the links they created stay.
+// The last owner cannot be removed (ErrLastOwner), and non-members are sql.ErrNoRows.
+func (w *WorkspacePsqlImpl) RemoveMember(ctx context.Context, workspaceId uuid.UUID, userId uuid.UUID) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// Lock the owners so two owners cannot remove each other at the same time
+	var owners []uuid.UUID
+	rows, err := tx.QueryContext(ctx, `
+		SELECT user_id FROM workspace_members
+		WHERE workspace_id = $1 AND role = $2
+		FOR UPDATE
+	`, workspaceId, workspaces.RoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to lock workspace owners: %w", err)
+	}
+	for rows.Next() {
+		var owner uuid.UUID
+		if err := rows.Scan(&owner); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan workspace owner: %w", err)
+		}
+		owners = append(owners, owner)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to lock workspace owners: %w", err)
+	}
+	if len(owners) == 1 && owners[0] == userId {
+		return ErrLastOwner
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`, workspaceId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace member: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// CreateInvitation stores a pending invitation; only the hash of its token is kept
+func (w *WorkspacePsqlImpl) CreateInvitation(ctx context.Context, invitation *models.WorkspaceInvitation, tokenHash string) error {
+	query := `
+		INSERT INTO workspace_invitations (workspace_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := w.db.QueryRowContext(ctx, query, invitation.WorkspaceId, invitation.Email, invitation.Role, tokenHash,
+		invitation.InvitedBy, invitation.ExpiresAt).Scan(&invitation.Id, &invitation.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return nil
+}
+
+// ListInvitations returns the pending invitations of a workspace, newest first
+func (w *WorkspacePsqlImpl) ListInvitations(ctx context.Context, workspaceId uuid.UUID) ([]models.WorkspaceInvitation, error) {
+	query := `
+		SELECT id, workspace_id, email, role, invited_by, expires_at, created_at
+		FROM workspace_invitations
+		WHERE workspace_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+	rows, err := w.db.QueryContext(ctx, query, workspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := make([]models.WorkspaceInvitation, 0)
+	for rows.Next() {
+		var invitation models.WorkspaceInvitation
+		err := rows.Scan(&invitation.Id, &invitation.WorkspaceId, &invitation.Email, &invitation.Role,
+			&invitation.InvitedBy, &invitation.ExpiresAt, &invitation.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// DeleteInvitation revokes a pending invitation
+func (w *WorkspacePsqlImpl) DeleteInvitation(ctx context.Context, workspaceId uuid.UUID, invitationId uuid.UUID) error {
+	query := `DELETE FROM workspace_invitations WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL`
+	result, err := w.db.ExecContext(ctx, query, invitationId, workspaceId)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AcceptInvitation adds the user to the workspace of a pending invitation with the
+// invited role and uses the invitation up. Unknown, used and expired invitations
+// are sql.ErrNoRows.
+func (w *WorkspacePsqlImpl) AcceptInvitation(ctx context.Context, tokenHash string, userId uuid.UUID, email string) (*models.Workspace, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var invitation models.WorkspaceInvitation
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, workspace_id, email, role
+		FROM workspace_invitations
+		WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		FOR UPDATE
+	`, tokenHash).Scan(&invitation.Id, &invitation.WorkspaceId, &invitation.Email, &invitation.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to fetch invitation: %w", err)
+	}
+	if !strings.EqualFold(invitation.Email, email) {
+		return nil, ErrInvitationEmail
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO NOTHING
+	`, invitation.WorkspaceId, userId, invitation.Role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add workspace member: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	} else if rowsAffected == 0 {
+		return nil, ErrAlreadyMember
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE workspace_invitations SET accepted_at = NOW() WHERE id = $1`, invitation.Id); err != nil {
+		return nil, fmt.Errorf("failed to use up invitation: %w", err)
+	}
+
+	workspace := models.Workspace{Id: invitation.WorkspaceId, Role: invitation.Role}
+	err = tx.QueryRowContext(ctx, `SELECT name, personal_user_id IS NOT NULL, created_at FROM workspaces WHERE id = $1`, invitation.WorkspaceId).
+		Scan(&workspace.Name, &workspace.Personal, &workspace.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch workspace: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &workspace, nil
+}
+
+func addMember(ctx context.Context, tx *sql.Tx, workspaceId uuid.UUID, userId uuid.UUID, role string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`,
+		workspaceId, userId, role)
+	if err != nil {
+		return fmt.Errorf("failed to add workspace member: %w", err)
+	}
+	return nil
+}
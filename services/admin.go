@@ -0,0 +1,242 @@
+package services
+
+import (
+	"U-235/core/links"
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"time"
+)
+
+var ErrCannotSuspendAdmin = models.NewAppError("CANNOT_SUSPEND_ADMIN", "Admins cannot be suspended", http.StatusConflict)
+
+// AdminServices back the platform admin API. Every call takes the acting admin,
+// and every action except reading the audit log itself is recorded in it.
+type AdminServices interface {
+	ListUsers(ctx context.Context, adminId uuid.UUID, search string, page, limit int) (*models.PaginatedAdminUsersResponse, error)
+	ListUrls(ctx context.Context, adminId uuid.UUID, filter *models.AdminUrlFilter, page, limit int) (*models.PaginatedUrlsResponse, error)
+	DisableUrl(ctx context.Context, adminId uuid.UUID, urlId uuid.UUID, reason string) (*models.ShortenedUrlInfoRes, error)
+	SuspendUser(ctx context.Context, adminId uuid.UUID, userId uuid.UUID, reason string) (int, error)
+	GetStats(ctx context.Context, adminId uuid.UUID) (*models.AdminStats, error)
+	ListAudit(ctx context.Context, page, limit int) (*models.PaginatedAuditResponse, error)
+}
+
+type AdminService struct {
+	AdminRepo   repositories.AdminRepo
+	UserRepo    repositories.UserRepository
+	RedisRepo   repositories.RedisRepo
+	Revocations repositories.TokenRevocationRepo
+	// AccessTokenTTL is how long the access tokens of a suspended user stay revoked
+	AccessTokenTTL time.Duration
+	// Links builds the short link of listed links
+	Links *links.Builder
+}
+
+func NewAdminService(repo repositories.AdminRepo, userRepo repositories.UserRepository, redisRepo repositories.RedisRepo, revocations repositories.TokenRevocationRepo, accessTokenTTL time.Duration, links *links.Builder) AdminServices {
+	return &AdminService{
+		AdminRepo:      repo,
+		UserRepo:       userRepo,
+		RedisRepo:      redisRepo,
+		Revocations:    revocations,
+		AccessTokenTTL: accessTokenTTL,
+		Links:          links,
+	}
+}
+
+func (a *AdminService) ListUsers(ctx context.Context, adminId uuid.UUID, search string, page, limit int) (*models.PaginatedAdminUsersResponse, error) {
+	page, limit, offset := pageBounds(page, limit)
+
+	err := a.AdminRepo.RecordAudit(ctx, &models.AdminAuditEntry{
+		AdminId: adminId,
+		Action:  models.AdminActionListUsers,
+		Details: map[string]interface{}{"search": search, "page": page},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := a.AdminRepo.ListUsers(ctx, search, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []models.AdminUser{}
+	}
+
+	return &models.PaginatedAdminUsersResponse{
+		Users: users,
+		Meta:  paginationMeta(page, limit, total),
+	}, nil
+}
+
+func (a *AdminService) ListUrls(ctx context.Context, adminId uuid.UUID, filter *models.AdminUrlFilter, page, limit int) (*models.PaginatedUrlsResponse, error) {
+	page, limit, offset := pageBounds(page, limit)
+
+	details := map[string]interface{}{"search": filter.Search, "page": page}
+	if filter.UserId != uuid.Nil {
+		details["user_id"] = filter.UserId
+	}
+	if filter.Status != "" {
+		details["status"] = filter.Status
+	}
+	if filter.Disabled {
+		details["disabled"] = true
+	}
+	err := a.AdminRepo.RecordAudit(ctx, &models.AdminAuditEntry{
+		AdminId: adminId,
+		Action:  models.AdminActionListUrls,
+		Details: details,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	urls, total, err := a.AdminRepo.ListUrls(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if urls == nil {
+		urls = []models.ShortenedUrlInfoRes{}
+	}
+	for i := range urls {
+		urls[i].Slug = urls[i].ShortUrl
+		urls[i].ShortLink = a.Links.Link(urls[i].Domain, urls[i].ShortUrl)
+	}
+
+	return &models.PaginatedUrlsResponse{
+		Urls: urls,
+		Meta: paginationMeta(page, limit, total),
+	}, nil
+}
+
+// DisableUrl takes a link down for good: it stops redirecting at once, and its
+// owners can no longer edit or reactivate it
+func (a *AdminService) DisableUrl(ctx context.Context, adminId uuid.UUID, urlId uuid.UUID, reason string) (*models.ShortenedUrlInfoRes, error) {
+	urlInfo, err := a.AdminRepo.DisableUrl(ctx, urlId, &models.AdminAuditEntry{
+		AdminId:    adminId,
+		Action:     models.AdminActionDisableUrl,
+		TargetType: "url",
+		TargetId:   &urlId,
+		Details:    map[string]interface{}{"reason": reason},
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrUrlNotFound
+		}
+		return nil, err
+	}
+
+	// The link is already disabled in Postgres, so a cache miss cannot bring it back;
+	// a failure here leaves it redirecting from Redis, and disabling again retries
+	if err := a.RedisRepo.DeleteKeys(ctx, urlInfo.LinkKey()); err != nil {
+		return nil, models.NewAppError("CACHE_DELETE_FAILED", "URL disabled but could not be removed from cache, please retry", http.StatusInternalServerError).Wrap(err)
+	}
+
+	urlInfo.Slug = urlInfo.ShortUrl
+	urlInfo.ShortLink = a.Links.Link(urlInfo.Domain, urlInfo.ShortUrl)
+	return urlInfo, nil
+}
+
+// SuspendUser blocks a user from logging in, refreshing sessions or using API keys,
+// revokes the access tokens already issued and disables every link they created.
+// It returns how many links are disabled.
+func (a *AdminService) SuspendUser(ctx context.Context, adminId uuid.UUID, userId uuid.UUID, reason string) (int, error) {
+	user, err := a.UserRepo.GetUserByID(userId, ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrUserNotFound
+		}
+		return 0, err
+	}
+	if user.Role == models.RoleAdmin {
+		return 0, ErrCannotSuspendAdmin
+	}
+
+	keys, err := a.AdminRepo.SuspendUser(ctx, userId, &models.AdminAuditEntry{
+		AdminId:    adminId,
+		Action:     models.AdminActionSuspendUser,
+		TargetType: "user",
+		TargetId:   &userId,
+		Details:    map[string]interface{}{"reason": reason},
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrUserNotFound
+		}
+		return 0, err
+	}
+
+	// Suspending again retries this, like the cache cleanup below
+	if err := a.Revocations.RevokeUser(ctx, userId, a.AccessTokenTTL); err != nil {
+		return 0, models.NewAppError("TOKEN_REVOCATION_FAILED", "User suspended but their sessions could not be ended, please retry", http.StatusInternalServerError).Wrap(err)
+	}
+
+	failed := 0
+	for _, key := range keys {
+		if err := a.RedisRepo.DeleteKeys(ctx, key); err != nil {
+			log.Printf("Failed to remove suspended user's link %s from cache: %v", key, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return 0, models.NewAppError("CACHE_DELETE_FAILED", "User suspended but some links could not be removed from cache, please retry", http.StatusInternalServerError).
+			WithDetails(fmt.Sprintf("%d of %d links are still cached", failed, len(keys)))
+	}
+	return len(keys), nil
+}
+
+func (a *AdminService) GetStats(ctx context.Context, adminId uuid.UUID) (*models.AdminStats, error) {
+	err := a.AdminRepo.RecordAudit(ctx, &models.AdminAuditEntry{
+		AdminId: adminId,
+		Action:  models.AdminActionViewStats,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.AdminRepo.GetStats(ctx)
+}
+
+func (a *AdminService) ListAudit(ctx context.Context, page, limit int) (*models.PaginatedAuditResponse, error) {
+	page, limit, offset := pageBounds(page, limit)
+
+	entries, total, err := a.AdminRepo.ListAudit(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PaginatedAuditResponse{
+		Entries: entries,
+		Meta:    paginationMeta(page, limit, total),
+	}, nil
+}
+
+// pageBounds applies the list API defaults: page 1, 10 items, at most 100
+func pageBounds(page, limit int) (int, int, int) {
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	} else if limit > 100 {
+		limit = 100
+	}
+	return page, limit, (page - 1) * limit
+}
+
+func paginationMeta(page, limit int, total int64) models.PaginationMeta {
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	return models.PaginationMeta{
+		CurrentPage: page,
+		TotalPages:  totalPages,
+		PageSize:    limit,
+		TotalCount:  total,
+		HasNext:     page < totalPages,
+		HasPrevious: page > 1,
+	}
+}
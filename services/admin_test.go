@@ -0,0 +1,118 @@
+package services
+
+import (
+	"U-235/core/links"
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// fakeAdminRepo suspends users in memory and returns the link keys they own
+type fakeAdminRepo struct {
+	repositories.AdminRepo
+
+	keys      map[uuid.UUID][]string
+	suspended map[uuid.UUID]bool
+	audit     []models.AdminAuditEntry
+}
+
+func (f *fakeAdminRepo) SuspendUser(ctx context.Context, userId uuid.UUID, entry *models.AdminAuditEntry) ([]string, error) {
+	keys, ok := f.keys[userId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	f.suspended[userId] = true
+	f.audit = append(f.audit, *entry)
+	return keys, nil
+}
+
+type fakeUserRepo struct {
+	repositories.UserRepository
+
+	users map[uuid.UUID]*models.User
+}
+
+func (f *fakeUserRepo) GetUserByID(userID uuid.UUID, ctx context.Context) (*models.User, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+type fakeRevocations struct {
+	repositories.TokenRevocationRepo
+
+	users map[uuid.UUID]time.Duration
+}
+
+func (f *fakeRevocations) RevokeUser(ctx context.Context, userId uuid.UUID, ttl time.Duration) error {
+	f.users[userId] = ttl
+	return nil
+}
+
+func TestSuspendUser(t *testing.T) {
+	adminId, userId, otherAdminId := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name        string
+		target      uuid.UUID
+		failDelete  bool
+		wantErr     error
+		wantLinks   int
+		wantRevoked bool
+	}{
+		{name: "user", target: userId, wantLinks: 2, wantRevoked: true},
+		{name: "unknown user", target: uuid.New(), wantErr: models.ErrUserNotFound},
+		{name: "admin", target: otherAdminId, wantErr: ErrCannotSuspendAdmin},
+		{name: "cache unavailable", target: userId, failDelete: true, wantErr: models.NewAppError("CACHE_DELETE_FAILED", "", 0), wantRevoked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adminRepo := &fakeAdminRepo{
+				keys:      map[uuid.UUID][]string{userId: {"abc1234", "go.brand.com/sale"}, otherAdminId: nil},
+				suspended: map[uuid.UUID]bool{},
+			}
+			userRepo := &fakeUserRepo{users: map[uuid.UUID]*models.User{
+				userId:       {Id: userId, Role: models.RoleUser},
+				otherAdminId: {Id: otherAdminId, Role: models.RoleAdmin},
+			}}
+			redis := newFakeRedisRepo()
+			redis.urls["abc1234"] = &models.CachedUrl{}
+			redis.urls["go.brand.com/sale"] = &models.CachedUrl{}
+			redis.failDelete = tt.failDelete
+			revocations := &fakeRevocations{users: map[uuid.UUID]time.Duration{}}
+			builder, _ := links.NewBuilder("u235.link")
+			service := NewAdminService(adminRepo, userRepo, redis, revocations, 15*time.Minute, builder)
+
+			disabled, err := service.SuspendUser(context.Background(), adminId, tt.target, "spam")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SuspendUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if disabled != tt.wantLinks {
+				t.Errorf("SuspendUser() = %d links, want %d", disabled, tt.wantLinks)
+			}
+			if ttl, revoked := revocations.users[tt.target]; revoked != tt.wantRevoked || (revoked && ttl != 15*time.Minute) {
+				t.Errorf("tokens revoked = %t for %s, want %t for the access token lifetime", revoked, ttl, tt.wantRevoked)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !adminRepo.suspended[tt.target] {
+				t.Error("user was not suspended")
+			}
+			if len(redis.urls) != 0 {
+				t.Errorf("links still cached: %v", redis.urls)
+			}
+			if len(adminRepo.audit) != 1 || adminRepo.audit[0].AdminId != adminId || adminRepo.audit[0].Details["reason"] != "spam" {
+				t.Errorf("audit entries = %+v, want one suspension by the admin with its reason", adminRepo.audit)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"U-235/core/workspaces"
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	defaultStatsRange = 7 * 24 * time.Hour
+	defaultStatsTop   = 10
+	maxStatsTop       = 50
+)
+
+// maxStatsRange bounds each bucket size so a single call returns at most a few hundred points
+var maxStatsRange = map[string]time.Duration{
+	models.StatsBucketHour: 31 * 24 * time.Hour,
+	models.StatsBucketDay:  366 * 24 * time.Hour,
+	models.StatsBucketWeek: 5 * 366 * 24 * time.Hour,
+}
+
+type AnalyticsServices interface {
+	GetUrlStats(ctx context.Context, userId uuid.UUID, urlId uuid.UUID, req *models.UrlStatsReq) (*models.UrlStatsRes, error)
+}
+
+type AnalyticsService struct {
+	PsqlRepo   repositories.UrlsPsql
+	ClickRepo  repositories.ClickRepo
+	Workspaces WorkspaceServices
+}
+
+func NewAnalyticsService(psql repositories.UrlsPsql, clickRepo repositories.ClickRepo, workspaceService WorkspaceServices) AnalyticsServices {
+	return &AnalyticsService{
+		PsqlRepo:   psql,
+		ClickRepo:  clickRepo,
+		Workspaces: workspaceService,
+	}
+}
+
+func (a *AnalyticsService) GetUrlStats(ctx context.Context, userId uuid.UUID, urlId uuid.UUID, req *models.UrlStatsReq) (*models.UrlStatsRes, error) {
+	// Every member of the link's workspace can see its stats
+	urlInfo, err := authorizeUrl(ctx, a.PsqlRepo, a.Workspaces, userId, urlId, workspaces.RoleViewer, "view")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := normalizeStatsReq(req); err != nil {
+		return nil, err
+	}
+
+	res := &models.UrlStatsRes{
+		UrlId:    urlInfo.Id,
+		ShortUrl: urlInfo.ShortUrl,
+		From:     req.From,
+		To:       req.To,
+		Bucket:   req.Bucket,
+	}
+
+	res.TotalClicks, res.UniqueClicks, err = a.ClickRepo.GetClickTotals(ctx, urlId, req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+
+	res.TimeSeries, err = a.ClickRepo.GetClickTimeSeries(ctx, urlId, req.From, req.To, req.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdowns := []struct {
+		column repositories.ClickBreakdown
+		target *[]models.StatsCount
+	}{
+		{repositories.BreakdownReferrer, &res.TopReferrers},
+		{repositories.BreakdownUserAgent, &res.TopUserAgents},
+		{repositories.BreakdownBrowser, &res.TopBrowsers},
+		{repositories.BreakdownOs, &res.TopOs},
+	}
+	for _, b := range breakdowns {
+		*b.target, err = a.ClickRepo.GetTopClickValues(ctx, urlId, req.From, req.To, b.column, req.Top)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Every variant is listed, so the split can be compared; clicks that were
+	// not split (routed by a rule, or before the split began) count as "unknown"
+	res.Variants, err = a.ClickRepo.GetTopClickValues(ctx, urlId, req.From, req.To, repositories.BreakdownVariant, maxStatsTop)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(res.Variants, func(count models.StatsCount) bool { return count.Value != "unknown" }) {
+		res.Variants = nil
+	}
+
+	return res, nil
+}
+
+// normalizeStatsReq fills in defaults and rejects ranges that are inverted or
+// too long for the chosen bucket size.
+func normalizeStatsReq(req *models.UrlStatsReq) error {
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+	if req.From.IsZero() {
+		req.From = req.To.Add(-defaultStatsRange)
+	}
+	if !req.From.Before(req.To) {
+		return models.NewAppError("INVALID_RANGE", "'from' must be before 'to'", http.StatusBadRequest)
+	}
+
+	if req.Bucket == "" {
+		req.Bucket = models.StatsBucketDay
+		if req.To.Sub(req.From) <= 48*time.Hour {
+			req.Bucket = models.StatsBucketHour
+		}
+	}
+	limit, ok := maxStatsRange[req.Bucket]
+	if !ok {
+		return models.NewAppError("INVALID_BUCKET", "bucket must be one of hour, day or week", http.StatusBadRequest)
+	}
+	if req.To.Sub(req.From) > limit {
+		return models.NewAppError("RANGE_TOO_LARGE", fmt.Sprintf("range is too large for %s buckets", req.Bucket), http.StatusBadRequest)
+	}
+
+	if req.Top <= 0 {
+		req.Top = defaultStatsTop
+	} else if req.Top > maxStatsTop {
+		req.Top = maxStatsTop
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"U-235/core/workspaces"
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeStatsRepo answers every stats query with no clicks and keeps the
+// arguments of the last ones
+type fakeStatsRepo struct {
+	repositories.ClickRepo
+
+	bucket string
+	limits map[repositories.ClickBreakdown]int
+}
+
+func (f *fakeStatsRepo) GetClickTotals(ctx context.Context, urlId uuid.UUID, from, to time.Time) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (f *fakeStatsRepo) GetClickTimeSeries(ctx context.Context, urlId uuid.UUID, from, to time.Time, bucket string) ([]models.StatsBucket, error) {
+	f.bucket = bucket
+	return nil, nil
+}
+
+func (f *fakeStatsRepo) GetTopClickValues(ctx context.Context, urlId uuid.UUID, from, to time.Time, column repositories.ClickBreakdown, limit int) ([]models.StatsCount, error) {
+	f.limits[column] = limit
+	return nil, nil
+}
+
+func TestNormalizeStatsReq(t *testing.T) {
+	to := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	before := func(d time.Duration) time.Time { return to.Add(-d) }
+	day := 24 * time.Hour
+
+	type normalizeTest struct {
+		name       string
+		req        models.UrlStatsReq
+		wantFrom   time.Time
+		wantBucket string
+		wantTop    int
+		wantCode   string
+	}
+	tests := []normalizeTest{
+		{name: "default range", req: models.UrlStatsReq{To: to}, wantFrom: before(defaultStatsRange), wantBucket: models.StatsBucketDay, wantTop: defaultStatsTop},
+		{name: "48h picks hours", req: models.UrlStatsReq{From: before(48 * time.Hour), To: to}, wantFrom: before(48 * time.Hour), wantBucket: models.StatsBucketHour, wantTop: defaultStatsTop},
+		{name: "over 48h picks days", req: models.UrlStatsReq{From: before(48*time.Hour + time.Second), To: to}, wantFrom: before(48*time.Hour + time.Second), wantBucket: models.StatsBucketDay, wantTop: defaultStatsTop},
+		{name: "explicit bucket", req: models.UrlStatsReq{From: before(day), To: to, Bucket: models.StatsBucketWeek}, wantFrom: before(day), wantBucket: models.StatsBucketWeek, wantTop: defaultStatsTop},
+		{name: "top within limit", req: models.UrlStatsReq{From: before(day), To: to, Top: 3}, wantFrom: before(day), wantBucket: models.StatsBucketHour, wantTop: 3},
+		{name: "top clamped", req: models.UrlStatsReq{From: before(day), To: to, Top: maxStatsTop + 1}, wantFrom: before(day), wantBucket: models.StatsBucketHour, wantTop: maxStatsTop},
+		{name: "from equals to", req: models.UrlStatsReq{From: to, To: to}, wantCode: "INVALID_RANGE"},
+		{name: "from after to", req: models.UrlStatsReq{From: to.Add(time.Hour), To: to}, wantCode: "INVALID_RANGE"},
+		{name: "unknown bucket", req: models.UrlStatsReq{From: before(day), To: to, Bucket: "minute"}, wantCode: "INVALID_BUCKET"},
+	}
+	for bucket, limit := range maxStatsRange {
+		tests = append(tests,
+			normalizeTest{name: "longest " + bucket + " range", req: models.UrlStatsReq{From: before(limit), To: to, Bucket: bucket}, wantFrom: before(limit), wantBucket: bucket, wantTop: defaultStatsTop},
+			normalizeTest{name: "too long " + bucket + " range", req: models.UrlStatsReq{From: before(limit + time.Second), To: to, Bucket: bucket}, wantCode: "RANGE_TOO_LARGE"},
+		)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := normalizeStatsReq(&req)
+			if tt.wantCode != "" {
+				var appErr *models.AppError
+				if !errors.As(err, &appErr) || appErr.Code != tt.wantCode || appErr.StatusCode != http.StatusBadRequest {
+					t.Fatalf("normalizeStatsReq() error = %v, want a 400 %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeStatsReq() error = %v", err)
+			}
+			if !req.From.Equal(tt.wantFrom) || !req.To.Equal(to) || req.Bucket != tt.wantBucket || req.Top != tt.wantTop {
+				t.Errorf("normalizeStatsReq() = %s..%s %s top %d, want %s..%s %s top %d",
+					req.From, req.To, req.Bucket, req.Top, tt.wantFrom, to, tt.wantBucket, tt.wantTop)
+			}
+		})
+	}
+}
+
+func TestGetUrlStats(t *testing.T) {
+	team, member, outsider := uuid.New(), uuid.New(), uuid.New()
+	access := NewWorkspaceService(&fakeWorkspaceRepo{
+		roles:    map[membership]string{{team, member}: workspaces.RoleViewer},
+		personal: map[uuid.UUID]uuid.UUID{outsider: uuid.New()},
+	}, nil, 0)
+	psql := newFakeUrlsPsql()
+	link := models.ShortenedUrlInfoRes{Id: uuid.New(), WorkspaceId: team, ShortUrl: "stats12", IsActive: true}
+	psql.urls[link.ShortUrl] = link
+
+	tests := []struct {
+		name    string
+		userId  uuid.UUID
+		urlId   uuid.UUID
+		wantErr error
+	}{
+		{name: "workspace member", userId: member, urlId: link.Id},
+		{name: "another user's link", userId: outsider, urlId: link.Id, wantErr: models.ErrUrlAccessDenied},
+		{name: "unknown link", userId: member, urlId: uuid.New(), wantErr: models.ErrUrlNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clicks := &fakeStatsRepo{limits: make(map[repositories.ClickBreakdown]int)}
+			service := NewAnalyticsService(psql, clicks, access)
+
+			res, err := service.GetUrlStats(context.Background(), tt.userId, tt.urlId, &models.UrlStatsReq{Top: maxStatsTop * 2})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetUrlStats() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(clicks.limits) != 0 {
+					t.Errorf("clicks queried for a denied request: %v", clicks.limits)
+				}
+				return
+			}
+
+			if res.UrlId != link.Id || res.ShortUrl != link.ShortUrl || res.Bucket != models.StatsBucketDay || clicks.bucket != models.StatsBucketDay {
+				t.Errorf("GetUrlStats() = %+v, queried %q buckets; want the link's stats by day", res, clicks.bucket)
+			}
+			for _, column := range []repositories.ClickBreakdown{repositories.BreakdownReferrer, repositories.BreakdownUserAgent, repositories.BreakdownBrowser, repositories.BreakdownOs} {
+				if clicks.limits[column] != maxStatsTop {
+					t.Errorf("top %s queried with limit %d, want %d", column, clicks.limits[column], maxStatsTop)
+				}
+			}
+		})
+	}
+}
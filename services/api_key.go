@@ -0,0 +1,116 @@
+package services
+
+import (
+	"U-235/models"
+	"U-235/repositories"
+	"U-235/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	// apiKeySecretBytes is the entropy of the random part of a key
+	apiKeySecretBytes = 30
+	// apiKeyDisplayLength is how much of the key (including ApiKeyPrefix) is kept for display
+	apiKeyDisplayLength = len(models.ApiKeyPrefix) + 8
+)
+
+var (
+	ErrApiKeyNotFound = models.NewAppError("API_KEY_NOT_FOUND", "API key not found", http.StatusNotFound)
+	ErrInvalidApiKey  = errors.New("invalid or revoked API key")
+)
+
+type ApiKeyServices interface {
+	CreateApiKey(ctx context.Context, userId uuid.UUID, req *models.CreateApiKeyReq) (*models.CreateApiKeyRes, error)
+	ListApiKeys(ctx context.Context, userId uuid.UUID) ([]models.ApiKey, error)
+	RenameApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID, name string) error
+	RevokeApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID) error
+	AuthenticateApiKey(ctx context.Context, key string) (uuid.UUID, []string, error)
+}
+
+type ApiKeyService struct {
+	ApiKeyRepo repositories.ApiKeyRepo
+}
+
+func NewApiKeyService(repo repositories.ApiKeyRepo) ApiKeyServices {
+	return &ApiKeyService{
+		ApiKeyRepo: repo,
+	}
+}
+
+func (a *ApiKeyService) CreateApiKey(ctx context.Context, userId uuid.UUID, req *models.CreateApiKeyReq) (*models.CreateApiKeyRes, error) {
+	secret, err := utils.GenerateOpaqueToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, err
+	}
+	rawKey := models.ApiKeyPrefix + secret
+
+	// Deduplicate and order scopes so listings are stable
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	key := models.ApiKey{
+		UserId: userId,
+		Name:   strings.TrimSpace(req.Name),
+		Prefix: rawKey[:apiKeyDisplayLength],
+		Scopes: scopes,
+	}
+	if err := a.ApiKeyRepo.CreateApiKey(ctx, &key, utils.HashToken(rawKey)); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateApiKeyRes{
+		ApiKey: key,
+		Key:    rawKey,
+	}, nil
+}
+
+func (a *ApiKeyService) ListApiKeys(ctx context.Context, userId uuid.UUID) ([]models.ApiKey, error) {
+	return a.ApiKeyRepo.ListApiKeys(ctx, userId)
+}
+
+func (a *ApiKeyService) RenameApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID, name string) error {
+	err := a.ApiKeyRepo.RenameApiKey(ctx, userId, keyId, strings.TrimSpace(name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrApiKeyNotFound
+	}
+	return err
+}
+
+func (a *ApiKeyService) RevokeApiKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID) error {
+	err := a.ApiKeyRepo.RevokeApiKey(ctx, userId, keyId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrApiKeyNotFound
+	}
+	return err
+}
+
+// AuthenticateApiKey resolves a raw bearer key to its owner and scopes.
+// It satisfies middleware.ApiKeyAuthenticator.
+func (a *ApiKeyService) AuthenticateApiKey(ctx context.Context, key string) (uuid.UUID, []string, error) {
+	if !strings.HasPrefix(key, models.ApiKeyPrefix) {
+		return uuid.Nil, nil, ErrInvalidApiKey
+	}
+
+	stored, err := a.ApiKeyRepo.GetActiveApiKeyByHash(ctx, utils.HashToken(key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, nil, ErrInvalidApiKey
+		}
+		return uuid.Nil, nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	if err := a.ApiKeyRepo.TouchApiKey(ctx, stored.Id); err != nil {
+		log.Printf("Failed to record API key usage: %v", err)
+	}
+
+	return stored.UserId, stored.Scopes, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"U-235/models"
+	"U-235/repositories"
+	"U-235/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits applied to free-form request headers before they are stored
+const maxClickHeaderLength = 1024
+
+type ClickRecorder interface {
+	// Record queues a click without blocking; it never waits on the database.
+	Record(event models.ClickEvent)
+	// Close flushes queued clicks and stops the background writer, waiting for
+	// the final write. Clicks recorded afterwards are dropped.
+	Close()
+}
+
+type BatchClickOptions struct {
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// BatchClickRecorder buffers click events in memory and writes them to
+// Postgres in batches from a single background goroutine. When the buffer is
+// full, new clicks are dropped rather than slowing down redirects.
+type BatchClickRecorder struct {
+	clickRepo repositories.ClickRepo
+	ipSalt    []byte
+	opts      BatchClickOptions
+
+	events    chan models.ClickEvent
+	dropped   atomic.Int64
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// mu guards closed: Record holds it for reading while sending, so Close
+	// never closes events under a sender
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewBatchClickRecorder(clickRepo repositories.ClickRepo, ipSalt string, opts BatchClickOptions) ClickRecorder {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 2 * time.Second
+	}
+
+	salt := []byte(ipSalt)
+	if len(salt) == 0 {
+		// Unique visitor counts will not line up across restarts without a fixed salt
+		log.Printf("Warning: CLICK_IP_SALT not set, using a random per-process salt for IP hashes")
+		salt = make([]byte, 32)
+		_, _ = rand.Read(salt)
+	}
+
+	recorder := &BatchClickRecorder{
+		clickRepo: clickRepo,
+		ipSalt:    salt,
+		opts:      opts,
+		events:    make(chan models.ClickEvent, opts.BufferSize),
+		done:      make(chan struct{}),
+	}
+
+	go recorder.run()
+	return recorder
+}
+
+func (r *BatchClickRecorder) Record(event models.ClickEvent) {
+	if event.ClientIP != "" {
+		event.IpHash = r.hashIP(event.ClientIP)
+		event.ClientIP = ""
+	}
+	event.Referrer = sanitizeHeader(event.Referrer)
+	event.UserAgent = sanitizeHeader(event.UserAgent)
+	event.AcceptLanguage = sanitizeHeader(event.AcceptLanguage)
+	event.Browser, event.Os = utils.ParseUserAgent(event.UserAgent)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+
+	select {
+	case r.events <- event:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+func (r *BatchClickRecorder) Close() {
+	r.closeOnce.Do(func() {
+		r.mu.Lock()
+		r.closed = true
+		close(r.events)
+		r.mu.Unlock()
+		<-r.done
+	})
+}
+
+func (r *BatchClickRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.ClickEvent, 0, r.opts.BatchSize)
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= r.opts.BatchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (r *BatchClickRecorder) flush(batch []models.ClickEvent) {
+	if dropped := r.dropped.Swap(0); dropped > 0 {
+		log.Printf("Click buffer full, dropped %d click event(s)", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.clickRepo.InsertClicks(ctx, batch); err != nil {
+		log.Printf("Failed to write click events: %v", err)
+	}
+}
+
+// hashIP keeps raw client addresses out of the database while still allowing
+// unique visitor counts.
+func (r *BatchClickRecorder) hashIP(ip string) string {
+	mac := hmac.New(sha256.New, r.ipSalt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sanitizeHeader trims a header value to something Postgres TEXT accepts; one
+// malformed value must not fail the whole batch.
+func sanitizeHeader(s string) string {
+	if len(s) > maxClickHeaderLength {
+		s = s[:maxClickHeaderLength]
+	}
+	s = strings.ReplaceAll(s, "\x00", "")
+	return strings.ToValidUTF8(s, "")
+}
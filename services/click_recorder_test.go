@@ -0,0 +1,70 @@
+package services
+
+import (
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClickRepo keeps inserted clicks in memory
+type fakeClickRepo struct {
+	repositories.ClickRepo
+
+	mu     sync.Mutex
+	clicks []models.ClickEvent
+}
+
+func (f *fakeClickRepo) InsertClicks(ctx context.Context, clicks []models.ClickEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clicks = append(f.clicks, clicks...)
+	return nil
+}
+
+func (f *fakeClickRepo) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.clicks)
+}
+
+func TestBatchClickRecorderCloseFlushes(t *testing.T) {
+	repo := &fakeClickRepo{}
+	recorder := NewBatchClickRecorder(repo, "salt", BatchClickOptions{BatchSize: 100, FlushInterval: time.Hour})
+
+	for i := 0; i < 10; i++ {
+		recorder.Record(models.ClickEvent{ShortUrl: "abc1234", ClientIP: "192.0.2.1"})
+	}
+	recorder.Close()
+
+	if got := repo.count(); got != 10 {
+		t.Fatalf("clicks written after Close = %d, want 10", got)
+	}
+	if repo.clicks[0].ClientIP != "" || repo.clicks[0].IpHash == "" {
+		t.Errorf("client IP was not replaced by its hash: %+v", repo.clicks[0])
+	}
+}
+
+func TestBatchClickRecorderRecordAfterClose(t *testing.T) {
+	repo := &fakeClickRepo{}
+	recorder := NewBatchClickRecorder(repo, "salt", BatchClickOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				recorder.Record(models.ClickEvent{ShortUrl: "abc1234"})
+			}
+		}()
+	}
+	recorder.Close()
+	wg.Wait()
+
+	// Clicks racing with Close are dropped rather than panicking on a closed channel
+	recorder.Record(models.ClickEvent{ShortUrl: "abc1234"})
+	recorder.Close()
+}
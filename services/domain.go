@@ -0,0 +1,232 @@
+package services
+
+import (
+	"U-235/core/domains"
+	"U-235/core/links"
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// verifiedDomainsMissRefresh is how often a host name missing from VerifiedDomains
+// may reload it, so domains verified on another instance show up quickly
+const verifiedDomainsMissRefresh = 5 * time.Second
+
+var (
+	ErrDomainNotFound           = models.NewAppError("DOMAIN_NOT_FOUND", "Domain not found", http.StatusNotFound)
+	ErrDomainExists             = models.NewAppError("DOMAIN_EXISTS", "Domain is already in use", http.StatusConflict)
+	ErrDomainInUse              = models.NewAppError("DOMAIN_IN_USE", "Domain still has links", http.StatusConflict)
+	ErrInvalidDomain            = models.NewAppError("INVALID_DOMAIN", "Invalid domain name", http.StatusBadRequest)
+	ErrDomainNotVerified        = models.NewAppError("DOMAIN_NOT_VERIFIED", "Domain is not a verified domain of yours", http.StatusUnprocessableEntity)
+	ErrDomainVerificationFailed = models.NewAppError("DOMAIN_VERIFICATION_FAILED", "Domain could not be verified", http.StatusUnprocessableEntity)
+)
+
+type DomainServices interface {
+	AddDomain(ctx context.Context, userId uuid.UUID, req *models.CreateDomainReq) (*models.DomainRes, error)
+	ListDomains(ctx context.Context, userId uuid.UUID) ([]models.DomainRes, error)
+	VerifyDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) (*models.DomainRes, error)
+	DeleteDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) error
+}
+
+type DomainService struct {
+	DomainRepo repositories.DomainRepo
+	Verifier   *domains.Verifier
+	Verified   *VerifiedDomains
+	Links      *links.Builder
+}
+
+func NewDomainService(repo repositories.DomainRepo, verifier *domains.Verifier, verified *VerifiedDomains, links *links.Builder) DomainServices {
+	return &DomainService{
+		DomainRepo: repo,
+		Verifier:   verifier,
+		Verified:   verified,
+		Links:      links,
+	}
+}
+
+func (d *DomainService) AddDomain(ctx context.Context, userId uuid.UUID, req *models.CreateDomainReq) (*models.DomainRes, error) {
+	hostname, err := domains.NormalizeHostname(req.Hostname)
+	if err != nil {
+		return nil, ErrInvalidDomain.WithDetails(err.Error())
+	}
+	// Links on the service's own domains are created without a domain
+	if d.Links.IsDefaultHost(hostname) {
+		return nil, ErrDomainExists
+	}
+
+	token, err := domains.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	domain := models.Domain{UserId: userId, Hostname: hostname, Token: token}
+	if err := d.DomainRepo.CreateDomain(ctx, &domain); err != nil {
+		if errors.Is(err, repositories.ErrDomainExists) {
+			return nil, ErrDomainExists
+		}
+		return nil, err
+	}
+	return newDomainRes(domain), nil
+}
+
+func (d *DomainService) ListDomains(ctx context.Context, userId uuid.UUID) ([]models.DomainRes, error) {
+	list, err := d.DomainRepo.ListDomains(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.DomainRes, len(list))
+	for i, domain := range list {
+		res[i] = *newDomainRes(domain)
+	}
+	return res, nil
+}
+
+// VerifyDomain looks the verification TXT record of a domain up. Verifying an
+// already verified domain succeeds without a lookup.
+func (d *DomainService) VerifyDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) (*models.DomainRes, error) {
+	domain, err := d.DomainRepo.GetDomain(ctx, userId, domainId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDomainNotFound
+		}
+		return nil, err
+	}
+	if domain.VerifiedAt != nil {
+		return newDomainRes(*domain), nil
+	}
+
+	if err := d.Verifier.Verify(ctx, domain.Hostname, domain.Token); err != nil {
+		if errors.Is(err, domains.ErrLookupFailed) {
+			log.Printf("Failed to verify domain %s: %v", domain.Hostname, err)
+		}
+		return nil, ErrDomainVerificationFailed.WithDetails(err.Error())
+	}
+
+	verifiedAt, err := d.DomainRepo.MarkDomainVerified(ctx, userId, domainId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDomainNotFound
+		}
+		if errors.Is(err, repositories.ErrDomainExists) {
+			return nil, ErrDomainExists
+		}
+		return nil, err
+	}
+	domain.VerifiedAt = &verifiedAt
+	d.Verified.Invalidate()
+	return newDomainRes(*domain), nil
+}
+
+func (d *DomainService) DeleteDomain(ctx context.Context, userId uuid.UUID, domainId uuid.UUID) error {
+	err := d.DomainRepo.DeleteDomain(ctx, userId, domainId)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrDomainNotFound
+	case errors.Is(err, repositories.ErrDomainInUse):
+		return ErrDomainInUse
+	case err != nil:
+		return err
+	}
+	d.Verified.Invalidate()
+	return nil
+}
+
+// newDomainRes adds the record to publish to a domain that is not verified yet
+func newDomainRes(domain models.Domain) *models.DomainRes {
+	res := &models.DomainRes{Domain: domain, Verified: domain.VerifiedAt != nil}
+	if !res.Verified {
+		res.Verification = &models.DomainVerification{
+			Type:  "TXT",
+			Name:  domains.RecordName(domain.Hostname),
+			Value: domains.RecordValue(domain.Token),
+		}
+	}
+	return res
+}
+
+// VerifiedDomains keeps the verified custom domains and their owners in memory,
+// since the redirect path looks the request's host up on every visit. The set
+// is reloaded from Postgres once ttl has passed, and a host name missing from
+// it reloads it at most every verifiedDomainsMissRefresh.
+// It satisfies policy.HostSet.
+type VerifiedDomains struct {
+	repo  repositories.DomainRepo
+	links *links.Builder
+	ttl   time.Duration
+
+	mu       sync.RWMutex
+	owners   map[string]uuid.UUID
+	loadedAt time.Time
+	loads    singleflight.Group
+}
+
+// NewVerifiedDomains returns an empty set that loads on first use. links tells
+// the service's own domains apart, which are never looked up.
+func NewVerifiedDomains(repo repositories.DomainRepo, links *links.Builder, ttl time.Duration) *VerifiedDomains {
+	return &VerifiedDomains{
+		repo:  repo,
+		links: links,
+		ttl:   ttl,
+	}
+}
+
+// Owner returns the user who verified host, if anyone has
+func (v *VerifiedDomains) Owner(ctx context.Context, host string) (uuid.UUID, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" || v.links.IsDefaultHost(host) {
+		return uuid.Nil, false
+	}
+
+	v.mu.RLock()
+	owner, ok := v.owners[host]
+	age := time.Since(v.loadedAt)
+	v.mu.RUnlock()
+
+	if age < v.ttl && (ok || age < verifiedDomainsMissRefresh) {
+		return owner, ok
+	}
+
+	v.reload(ctx)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	owner, ok = v.owners[host]
+	return owner, ok
+}
+
+// Contains reports whether host is a verified custom domain
+func (v *VerifiedDomains) Contains(ctx context.Context, host string) bool {
+	_, ok := v.Owner(ctx, host)
+	return ok
+}
+
+// Invalidate makes the next lookup reload the set
+func (v *VerifiedDomains) Invalidate() {
+	v.mu.Lock()
+	v.loadedAt = time.Time{}
+	v.mu.Unlock()
+}
+
+// reload replaces the set from Postgres; on failure the previous set is kept
+// and the next reload waits as if it had succeeded.
+func (v *VerifiedDomains) reload(ctx context.Context) {
+	_, _, _ = v.loads.Do("", func() (interface{}, error) {
+		owners, err := v.repo.GetVerifiedDomains(context.WithoutCancel(ctx))
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if err != nil {
+			log.Printf("Failed to load verified domains: %v", err)
+		} else {
+			v.owners = owners
+		}
+		v.loadedAt = time.Now()
+		return nil, nil
+	})
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"U-235/core/links"
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// fakeDomainRepo serves a fixed set of verified domains and counts the loads
+type fakeDomainRepo struct {
+	repositories.DomainRepo
+
+	owners map[string]uuid.UUID
+	loads  int
+}
+
+func (f *fakeDomainRepo) GetVerifiedDomains(ctx context.Context) (map[string]uuid.UUID, error) {
+	f.loads++
+	owners := make(map[string]uuid.UUID, len(f.owners))
+	for host, owner := range f.owners {
+		owners[host] = owner
+	}
+	return owners, nil
+}
+
+func newTestVerifiedDomains(owners map[string]uuid.UUID) (*VerifiedDomains, *fakeDomainRepo) {
+	builder, err := links.NewBuilder("u235.link")
+	if err != nil {
+		panic(err)
+	}
+	repo := &fakeDomainRepo{owners: owners}
+	return NewVerifiedDomains(repo, builder, time.Hour), repo
+}
+
+func TestVerifiedDomainsOwner(t *testing.T) {
+	owner := uuid.New()
+	verified, repo := newTestVerifiedDomains(map[string]uuid.UUID{"go.example.com": owner})
+
+	tests := []struct {
+		host      string
+		wantOwner uuid.UUID
+		wantOk    bool
+	}{
+		{host: "go.example.com", wantOwner: owner, wantOk: true},
+		{host: "GO.Example.com.", wantOwner: owner, wantOk: true},
+		{host: "other.example.com"},
+		{host: "u235.link"},
+		{host: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, ok := verified.Owner(context.Background(), tt.host)
+			if got != tt.wantOwner || ok != tt.wantOk {
+				t.Errorf("Owner(%q) = %s, %t, want %s, %t", tt.host, got, ok, tt.wantOwner, tt.wantOk)
+			}
+		})
+	}
+	if repo.loads != 1 {
+		t.Errorf("loaded %d times, want misses within %s to reuse the first load", repo.loads, verifiedDomainsMissRefresh)
+	}
+
+	// A domain verified since the last load shows up once the set is invalidated
+	repo.owners["new.example.com"] = owner
+	if verified.Contains(context.Background(), "new.example.com") {
+		t.Fatal("Contains() found a domain before the reload")
+	}
+	verified.Invalidate()
+	if !verified.Contains(context.Background(), "new.example.com") {
+		t.Error("Contains() missed a domain after Invalidate()")
+	}
+}
+
+func TestCustomDomainLinks(t *testing.T) {
+	owner := uuid.New()
+	psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+	service := newTestUrlService(psql, redis, &sequentialIds{})
+	service.Domains, _ = newTestVerifiedDomains(map[string]uuid.UUID{"go.example.com": owner})
+	ctx := context.Background()
+
+	create := func(userId uuid.UUID, domain string) (*models.ShortenedUrlInfoRes, error) {
+		return service.CreateUrlService(userId, uuid.Nil, &models.CreateShortUrlReq{
+			OriginalUrl:    "https://example.com/" + domain,
+			ExpireTime:     24,
+			CustomShortUrl: "launch",
+			Domain:         domain,
+		}, ctx)
+	}
+
+	// The same slug is free on every domain
+	if _, err := create(owner, ""); err != nil {
+		t.Fatalf("CreateUrlService() on the default domain error = %v", err)
+	}
+	res, err := create(owner, "Go.Example.com")
+	if err != nil {
+		t.Fatalf("CreateUrlService() on a custom domain error = %v", err)
+	}
+	if res.Domain != "go.example.com" || res.ShortLink != "https://go.example.com/launch" {
+		t.Errorf("created on %q as %q, want go.example.com and https://go.example.com/launch", res.Domain, res.ShortLink)
+	}
+	if _, err := create(uuid.New(), "go.example.com"); !errors.Is(err, ErrDomainNotVerified) {
+		t.Errorf("CreateUrlService() on another user's domain error = %v, want %v", err, ErrDomainNotVerified)
+	}
+
+	tests := []struct {
+		host            string
+		wantKey         string
+		wantDestination string
+	}{
+		{host: "u235.link", wantKey: "launch", wantDestination: "https://example.com/"},
+		{host: "go.example.com", wantKey: "go.example.com/launch", wantDestination: "https://example.com/Go.Example.com"},
+		{host: "GO.example.com.", wantKey: "go.example.com/launch", wantDestination: "https://example.com/Go.Example.com"},
+	}
+	for _, tt := range tests {
+		key := service.LinkKey(ctx, tt.host, "launch")
+		if key != tt.wantKey {
+			t.Errorf("LinkKey(%q) = %q, want %q", tt.host, key, tt.wantKey)
+			continue
+		}
+		if entry, ok := redis.urls[key]; !ok || entry.OriginalUrl != tt.wantDestination {
+			t.Errorf("%q cached as %+v, want %s", key, entry, tt.wantDestination)
+		}
+	}
+}
@@ -2,80 +2,299 @@ package services
 
 import (
 	"U-235/core"
+	"U-235/core/domains"
+	"U-235/core/links"
+	"U-235/core/policy"
+	"U-235/core/workspaces"
 	"U-235/models"
 	"U-235/repositories"
+	"U-235/utils"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/singleflight"
 	"log"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// maxIdAttemptsPerLength is how many unique violations are tolerated at one
+// generated ID length before the keyspace is considered crowded.
+const maxIdAttemptsPerLength = 3
+
 type UrlServices interface {
-	CreateUrlService(userID uuid.UUID, req *models.CreateShortUrlReq, ctx context.Context) (*models.ShortenedUrlInfoRes, error)
-	GetUserUrls(ctx context.Context, userID uuid.UUID, page, limit int, isActive *bool) (*models.PaginatedUrlsResponse, error)
+	CreateUrlService(userID uuid.UUID, workspaceID uuid.UUID, req *models.CreateShortUrlReq, ctx context.Context) (*models.ShortenedUrlInfoRes, error)
+	GetUserUrls(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, page, limit int, status string) (*models.PaginatedUrlsResponse, error)
 	SoftDeleteUrlService(DelReq *models.DeleteShortUrlReq, ctx context.Context) error
 	ExtendExpiryService(userId uuid.UUID, Req *models.ExtendExpiry, ctx context.Context) error
-	GetOriginalUrl(ctx context.Context, shortUrl string) (string, error)
+	LinkKey(ctx context.Context, host string, slug string) string
+	GetOriginalUrl(ctx context.Context, key string) (string, error)
+	ResolveShortUrl(ctx context.Context, key string) (*models.CachedUrl, error)
+	PreviewShortUrl(ctx context.Context, key string) (*models.UrlPreview, error)
+	UrlQrCodeService(userId uuid.UUID, urlId uuid.UUID, req *models.QrCodeReq, ctx context.Context) (*models.QrCodeRes, error)
+	UpdateUrlService(userId uuid.UUID, urlId uuid.UUID, req *models.UpdateUrlReq, ctx context.Context) (*models.ShortenedUrlInfoRes, error)
+	BulkCreateUrlService(userID uuid.UUID, workspaceID uuid.UUID, reqs []models.CreateShortUrlReq, atomic bool, ctx context.Context) ([]models.BulkCreateItemRes, error)
+	ExportUrlsService(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, fn func(*models.UrlExportRow) error) error
+	ImportUrlsService(userId uuid.UUID, workspaceId uuid.UUID, rows []models.UrlExportRow, onConflict string, ctx context.Context) ([]models.ImportRowRes, error)
+	UnlockShortUrl(ctx context.Context, key string, password string) (*models.CachedUrl, error)
+	ConsumeClick(ctx context.Context, key string, entry *models.CachedUrl) error
 }
 
 type ShortUrlService struct {
-	RedisRepo repositories.RedisRepo
-	PsqlRepo  repositories.UrlsPsql
+	RedisRepo   repositories.RedisRepo
+	PsqlRepo    repositories.UrlsPsql
+	IdGenerator core.ShortIDGenerator
+	// Destinations vets every URL a link can redirect to on create, edit and import
+	Destinations policy.DestinationPolicy
+	// Links builds the short link every response, export and QR code uses
+	Links *links.Builder
+	// Domains are the verified custom domains links are created and served on
+	Domains *VerifiedDomains
+	// Workspaces own the links; members act on them according to their role
+	Workspaces WorkspaceServices
+
+	// idLength is the length generated IDs start at; it grows as collisions pile up
+	idLength atomic.Int32
+
+	// cacheMisses collapses concurrent Postgres lookups for the same link key
+	cacheMisses singleflight.Group
+}
+
+func NewShortUrlService(repo repositories.RedisRepo, psql repositories.UrlsPsql, idGenerator core.ShortIDGenerator, idLength int, destinations policy.DestinationPolicy, links *links.Builder, verified *VerifiedDomains, workspaceService WorkspaceServices) *ShortUrlService {
+	service := &ShortUrlService{
+		RedisRepo:    repo,
+		PsqlRepo:     psql,
+		IdGenerator:  idGenerator,
+		Destinations: destinations,
+		Links:        links,
+		Domains:      verified,
+		Workspaces:   workspaceService,
+	}
+
+	idLength = max(core.MinShortIDLength, min(idLength, core.MaxShortIDLength))
+	service.idLength.Store(int32(idLength))
+	return service
 }
 
-func NewShortUrlService(repo repositories.RedisRepo, psql repositories.UrlsPsql) *ShortUrlService {
-	return &ShortUrlService{
-		RedisRepo: repo,
-		PsqlRepo:  psql,
+// withLink fills in the slug and short link of urlInfo for API responses
+func (r *ShortUrlService) withLink(urlInfo *models.ShortenedUrlInfoRes) *models.ShortenedUrlInfoRes {
+	if urlInfo != nil {
+		urlInfo.Slug = urlInfo.ShortUrl
+		urlInfo.ShortLink = r.Links.Link(urlInfo.Domain, urlInfo.ShortUrl)
 	}
+	return urlInfo
 }
 
-func (r *ShortUrlService) CreateUrlService(userID uuid.UUID, req *models.CreateShortUrlReq, ctx context.Context) (*models.ShortenedUrlInfoRes, error) {
-	//var Domain = os.Getenv("DOMAIN")
+// LinkKey returns the key of slug as requested on host. A verified custom domain
+// serves its own links; every other host serves the links of the default domains.
+func (r *ShortUrlService) LinkKey(ctx context.Context, host string, slug string) string {
+	if r.Domains != nil && r.Domains.Contains(ctx, host) {
+		return models.LinkKey(strings.ToLower(strings.TrimSuffix(host, ".")), slug)
+	}
+	return slug
+}
+
+// CreateUrlService creates a link in workspaceID, or in the user's personal
+// workspace for uuid.Nil; creating links takes the editor role.
+func (r *ShortUrlService) CreateUrlService(userID uuid.UUID, workspaceID uuid.UUID, req *models.CreateShortUrlReq, ctx context.Context) (*models.ShortenedUrlInfoRes, error) {
+	workspaceID, _, err := r.Workspaces.Authorize(ctx, userID, workspaceID, workspaces.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
 	CustomUrlTag := req.CustomShortUrl
 	urlInfo := models.ShortenedUrlInfoReq{
-		UserId:      userID,
-		OriginalUrl: req.OriginalUrl,
+		UserId:         userID,
+		WorkspaceId:    workspaceID,
+		OriginalUrl:    req.OriginalUrl,
+		RedirectType:   req.RedirectType,
+		MaxClicks:      req.MaxClicks,
+		RoutingRules:   req.RoutingRules,
+		Variants:       req.Variants,
+		StickyVariants: req.StickyVariants,
+		QueryParams:    req.QueryParams,
+		ForwardQuery:   req.ForwardQuery,
+		Preview:        req.Preview,
 	}
 
-	if CustomUrlTag != "" && len(CustomUrlTag) < 5 {
-		return nil, errors.New("invalid length of the custom url suffix")
-	} else if CustomUrlTag == "" {
-		shortID := core.GenerateShortID(req.OriginalUrl)
-		urlInfo.ShortUrl = shortID
-	} else {
-		_, exists := r.RedisRepo.GetOriginalUrl(ctx, CustomUrlTag)
-		if exists {
-			return nil, errors.New("custom short url already exists")
+	if urlInfo.RedirectType == 0 {
+		urlInfo.RedirectType = models.DefaultRedirectType
+	}
+
+	domain, appErr := r.checkDomain(ctx, userID, req.Domain)
+	if appErr != nil {
+		return nil, appErr
+	}
+	urlInfo.Domain = domain
+
+	if err := r.checkDestinations(ctx, linkDestinations(req.OriginalUrl, req.RoutingRules, req.Variants)); err != nil {
+		return nil, err
+	}
+
+	if req.Password != "" {
+		hash, err := utils.HashPassword(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash link password: %w", err)
+		}
+		urlInfo.PasswordHash = hash
+	}
+
+	if CustomUrlTag != "" {
+		if err := r.checkCustomShortUrl(ctx, urlInfo.Domain, CustomUrlTag); err != nil {
+			return nil, err
 		}
 		urlInfo.ShortUrl = CustomUrlTag
 	}
 
-	urlInfo.ExpiresAt = time.Now().Add(time.Duration(req.ExpireTime) * time.Hour)
+	urlInfo.ActivatesAt, urlInfo.ExpiresAt = activationWindow(time.Now(), req.ActivatesAt, req.ExpireTime)
 	urlInfo.IsActive = true
 
 	// 1. Save to PostgreSQL first
-	finalUrlRes, rollback, err := r.PsqlRepo.SaveUrl(ctx, &urlInfo)
+	var (
+		finalUrlRes *models.ShortenedUrlInfoRes
+		rollback    *models.PsqlRollback
+	)
+	if CustomUrlTag != "" {
+		finalUrlRes, rollback, err = r.PsqlRepo.SaveUrl(ctx, &urlInfo)
+		if errors.Is(err, repositories.ErrShortUrlExists) {
+			return nil, models.ErrShortUrlTaken
+		}
+	} else {
+		finalUrlRes, rollback, err = r.saveWithGeneratedId(ctx, &urlInfo)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to save url to DB: %w", err)
 	}
 
-	// 2. Save to Redis
-	redisErr := r.RedisRepo.SaveUrl(ctx, req.OriginalUrl, urlInfo.ShortUrl, time.Duration(req.ExpireTime)*time.Hour)
+	// 2. Save to Redis; scheduled links are cached by the activation scheduler
+	if finalUrlRes.IsScheduled(time.Now()) {
+		return r.withLink(finalUrlRes), nil
+	}
+	cached := newCachedUrl(finalUrlRes)
+	redisErr := r.RedisRepo.SaveUrl(ctx, finalUrlRes.LinkKey(), cached, time.Duration(req.ExpireTime)*time.Hour)
 	if redisErr != nil {
 		// Rollback: delete from PostgreSQL
 		_ = r.PsqlRepo.DeleteUrlRecord(ctx, rollback.UserId, rollback.UrlRecordId)
 		return nil, fmt.Errorf("failed to save url to Redis, rolled back DB: %w", redisErr)
 	}
-	return finalUrlRes, nil
+	return r.withLink(finalUrlRes), nil
+}
+
+// checkCustomShortUrl validates a user chosen short URL and rejects it if it is
+// live in Redis on domain; Postgres' unique constraint catches the remaining conflicts.
+func (r *ShortUrlService) checkCustomShortUrl(ctx context.Context, domain string, shortUrl string) error {
+	if err := validateCustomShortUrl(shortUrl); err != nil {
+		return err
+	}
+	if _, exists := r.RedisRepo.GetOriginalUrl(ctx, models.LinkKey(domain, shortUrl)); exists {
+		return models.ErrShortUrlTaken
+	}
+	return nil
+}
+
+// checkDomain returns the normalized domain a user asked to create a link on,
+// which must be one of the user's verified domains; empty means the default domains.
+func (r *ShortUrlService) checkDomain(ctx context.Context, userId uuid.UUID, domain string) (string, *models.AppError) {
+	if domain == "" {
+		return "", nil
+	}
+	hostname, err := domains.NormalizeHostname(domain)
+	if err != nil {
+		return "", ErrInvalidDomain.WithDetails(err.Error())
+	}
+	if r.Domains == nil {
+		return "", ErrDomainNotVerified
+	}
+	if owner, ok := r.Domains.Owner(ctx, hostname); !ok || owner != userId {
+		return "", ErrDomainNotVerified.WithDetails(fmt.Sprintf("%s is not verified", hostname))
+	}
+	return hostname, nil
+}
+
+// activationWindow returns when a new link goes live and when it expires. A link
+// scheduled for the future lives for expireHours from its activation time.
+func activationWindow(now time.Time, activatesAt *time.Time, expireHours int64) (*time.Time, time.Time) {
+	lifetime := time.Duration(expireHours) * time.Hour
+	if activatesAt == nil || !activatesAt.After(now) {
+		return nil, now.Add(lifetime)
+	}
+	return activatesAt, activatesAt.Add(lifetime)
+}
+
+// newCachedUrl builds the Redis value of a link from its database row
+func newCachedUrl(urlInfo *models.ShortenedUrlInfoRes) *models.CachedUrl {
+	return &models.CachedUrl{
+		Id:           urlInfo.Id,
+		OriginalUrl:  urlInfo.OriginalUrl,
+		RedirectType: urlInfo.RedirectType,
+		Protected:    urlInfo.IsProtected,
+		PasswordVer:  utils.PasswordVersion(urlInfo.PasswordHash),
+		MaxClicks:    urlInfo.MaxClicks,
+		Rules:        models.CompileRoutingRules(urlInfo.RoutingRules),
+		Variants:     models.CompileVariants(urlInfo.Variants),
+		Sticky:       urlInfo.StickyVariants,
+		Query:        models.CompileQueryParams(urlInfo.QueryParams),
+		ForwardQuery: urlInfo.ForwardQuery,
+		Preview:      urlInfo.Preview,
+	}
+}
+
+// validateCustomShortUrl checks the format of a user chosen short URL
+func validateCustomShortUrl(shortUrl string) *models.AppError {
+	if len(shortUrl) < 5 {
+		return models.ErrInvalidShortUrl.WithMessage("Custom short url must be at least 5 characters long")
+	}
+	if !core.IsValidShortIdFormat(shortUrl) {
+		return models.ErrInvalidShortUrl.WithMessage("Custom short url must be at most 12 letters, digits or inner hyphens")
+	}
+	if core.IsReservedPath(shortUrl) {
+		return models.ErrInvalidShortUrl.WithMessage("Custom short url is reserved")
+	}
+	return nil
+}
+
+// saveWithGeneratedId inserts urlInfo under a freshly generated short ID, retrying on
+// unique violations. Once maxIdAttemptsPerLength attempts at one length have collided
+// the length grows by one, both for this link and for every link created after it.
+func (r *ShortUrlService) saveWithGeneratedId(ctx context.Context, urlInfo *models.ShortenedUrlInfoReq) (*models.ShortenedUrlInfoRes, *models.PsqlRollback, error) {
+	for length := int(r.idLength.Load()); length <= core.MaxShortIDLength; length++ {
+		for attempt := 0; attempt < maxIdAttemptsPerLength; attempt++ {
+			shortID, err := r.IdGenerator.Generate(ctx, length)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to generate short ID: %w", err)
+			}
+			if core.IsReservedPath(shortID) || !core.IsValidShortIdFormat(shortID) {
+				continue
+			}
+
+			urlInfo.ShortUrl = shortID
+			res, rollback, err := r.PsqlRepo.SaveUrl(ctx, urlInfo)
+			if errors.Is(err, repositories.ErrShortUrlExists) {
+				continue
+			}
+			return res, rollback, err
+		}
+
+		// Only the first request to hit a crowded length moves the shared length on
+		if length < core.MaxShortIDLength && r.idLength.CompareAndSwap(int32(length), int32(length+1)) {
+			log.Printf("Short ID keyspace crowded at length %d, growing to %d", length, length+1)
+		}
+	}
+	return nil, nil, errors.New("no free short ID available")
 }
 
-func (r *ShortUrlService) GetUserUrls(ctx context.Context, userID uuid.UUID, page, limit int, isActive *bool) (*models.PaginatedUrlsResponse, error) {
+// GetUserUrls lists the links of workspaceID, or of the user's personal workspace
+// for uuid.Nil; every member role can list them.
+func (r *ShortUrlService) GetUserUrls(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, page, limit int, status string) (*models.PaginatedUrlsResponse, error) {
+	workspaceID, _, err := r.Workspaces.Authorize(ctx, userID, workspaceID, workspaces.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
 	// Set default pagination values if not provided
 	if page <= 0 {
 		page = 1
@@ -91,18 +310,23 @@ func (r *ShortUrlService) GetUserUrls(ctx context.Context, userID uuid.UUID, pag
 	// Calculate offset for pagination
 	offset := (page - 1) * limit
 
-	// Get total count of URLs for this user with the applied filter
-	totalCount, err := r.PsqlRepo.CountUserUrls(ctx, userID, isActive)
+	// Get total count of URLs in the workspace with the applied filter
+	totalCount, err := r.PsqlRepo.CountWorkspaceUrls(ctx, workspaceID, status)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count user URLs: %w", err)
 	}
 
-	// Get paginated URLs for this user
-	urls, err := r.PsqlRepo.GetUserUrls(ctx, userID, offset, limit, isActive)
+	// Get paginated URLs of the workspace
+	urls, err := r.PsqlRepo.GetWorkspaceUrls(ctx, workspaceID, offset, limit, status)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user URLs: %w", err)
 	}
 
+	r.fillRemainingClicks(ctx, urls)
+	for i := range urls {
+		r.withLink(&urls[i])
+	}
+
 	// Calculate pagination metadata
 	totalPages := int((totalCount + int64(limit) - 1) / int64(limit))
 	hasNext := page < totalPages
@@ -124,45 +348,65 @@ func (r *ShortUrlService) GetUserUrls(ctx context.Context, userID uuid.UUID, pag
 	return response, nil
 }
 
-func (r *ShortUrlService) SoftDeleteUrlService(DelReq *models.DeleteShortUrlReq, ctx context.Context) error {
-	// First, check if the URL record exists at all (regardless of owner)
-	exists, err := r.PsqlRepo.UrlRecordExists(ctx, DelReq.UrlRecordId)
+// fillRemainingClicks sets RemainingClicks on the click-limited links of a page.
+// Clicks are read from the Redis counters, falling back to the clicks used stored
+// on the link.
+func (r *ShortUrlService) fillRemainingClicks(ctx context.Context, urls []models.ShortenedUrlInfoRes) {
+	var limited []string
+	for _, urlInfo := range urls {
+		if urlInfo.MaxClicks > 0 {
+			limited = append(limited, urlInfo.LinkKey())
+		}
+	}
+	if len(limited) == 0 {
+		return
+	}
+
+	counts, err := r.RedisRepo.GetClickCounts(ctx, limited)
 	if err != nil {
-		return fmt.Errorf("database error: %w", err)
+		log.Printf("Failed to read click counters: %v", err)
+		counts = map[string]int64{}
 	}
 
-	if !exists {
-		return models.NewAppError("URL_NOT_FOUND", "The requested URL does not exist", http.StatusNotFound)
+	for i := range urls {
+		urlInfo := &urls[i]
+		if urlInfo.MaxClicks == 0 {
+			continue
+		}
+		used, ok := counts[urlInfo.LinkKey()]
+		if !ok {
+			used = urlInfo.ClicksUsed
+		}
+		remaining := max(int64(urlInfo.MaxClicks)-used, 0)
+		urlInfo.RemainingClicks = &remaining
 	}
+}
 
-	// Now check if this URL belongs to the requesting user
-	urlInfo, err := r.PsqlRepo.GetUrlInfoByUserIdAndUrlRecordId(ctx, DelReq.UserId, DelReq.UrlRecordId)
+// SoftDeleteUrlService deactivates a link; it takes the editor role in the link's workspace
+func (r *ShortUrlService) SoftDeleteUrlService(DelReq *models.DeleteShortUrlReq, ctx context.Context) error {
+	urlInfo, err := authorizeUrl(ctx, r.PsqlRepo, r.Workspaces, DelReq.UserId, DelReq.UrlRecordId, workspaces.RoleEditor, "delete")
 	if err != nil {
-		// If no rows found, it means URL exists but belongs to another user
-		if errors.Is(err, sql.ErrNoRows) {
-			return models.NewAppError("ACCESS_DENIED", "You do not have permission to delete this URL", http.StatusForbidden)
-		}
-		return fmt.Errorf("database error: %w", err)
+		return err
 	}
 
-	ok, err := r.RedisRepo.ExistsInRedis(ctx, urlInfo.ShortUrl)
+	ok, err := r.RedisRepo.ExistsInRedis(ctx, urlInfo.LinkKey())
 	if err != nil {
-		return models.NewAppError("ACTIVE_URL_NOT_FOUND", "The requested URL does not exist", http.StatusNotFound)
+		return models.ErrInternal.Wrap(err)
 	}
 
 	// if isActive, then it must be inside redis.
 	if urlInfo.IsActive && ok {
 		// Soft delete: deactivate URL and set expiration to current time
-		err = r.PsqlRepo.SoftDeleteUrl(ctx, urlInfo.UserId, urlInfo.Id)
+		err = r.PsqlRepo.SoftDeleteUrl(ctx, urlInfo.WorkspaceId, urlInfo.Id)
 		if err != nil {
 			return models.NewAppError("DEACTIVATION_FAILED", "Failed to deactivate the URL", http.StatusInternalServerError)
 		}
 
 		// delete url from redis
-		err = r.RedisRepo.DeleteKeys(ctx, urlInfo.ShortUrl)
+		err = r.RedisRepo.DeleteKeys(ctx, urlInfo.LinkKey())
 		if err != nil {
 			// Attempt rollback - reactivate the URL
-			rollbackErr := r.PsqlRepo.SetUrlState(ctx, urlInfo.UserId, urlInfo.Id, true)
+			rollbackErr := r.PsqlRepo.SetUrlState(ctx, urlInfo.WorkspaceId, urlInfo.Id, true)
 			if rollbackErr != nil {
 				log.Printf("Failed to rollback URL state: %v", rollbackErr)
 			}
@@ -170,7 +414,7 @@ func (r *ShortUrlService) SoftDeleteUrlService(DelReq *models.DeleteShortUrlReq,
 		}
 	} else if urlInfo.IsActive {
 		// URL is active but not in Redis, just soft delete in database
-		err = r.PsqlRepo.SoftDeleteUrl(ctx, urlInfo.UserId, urlInfo.Id)
+		err = r.PsqlRepo.SoftDeleteUrl(ctx, urlInfo.WorkspaceId, urlInfo.Id)
 		if err != nil {
 			return models.NewAppError("SOFT_DELETE_FAILED", "Failed to soft delete URL", http.StatusInternalServerError)
 		}
@@ -180,40 +424,424 @@ func (r *ShortUrlService) SoftDeleteUrlService(DelReq *models.DeleteShortUrlReq,
 	return nil
 }
 
+// ExtendExpiryService extends a link's lifetime and/or click limit; it takes the
+// editor role in the link's workspace
 func (r *ShortUrlService) ExtendExpiryService(userId uuid.UUID, Req *models.ExtendExpiry, ctx context.Context) error {
-	// First update the PostgreSQL database (this will also validate ownership)
-	err := r.PsqlRepo.ExtendExpiry(ctx, userId, Req.UrlId, Req.Hours)
+	current, err := authorizeUrl(ctx, r.PsqlRepo, r.Workspaces, userId, Req.UrlId, workspaces.RoleEditor, "extend")
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, "URL not found or you don't have permission")
-		}
 		return err
 	}
+	if current.DisabledAt != nil {
+		return models.ErrUrlDisabled
+	}
+	// Deleted or used up links are brought back with a PATCH, not extended
+	if !current.IsActive {
+		return models.ErrUrlInactive
+	}
+
+	// Raising the click limit only makes sense for click-limited links, so check
+	// that before changing anything
+	if Req.Clicks > 0 && current.MaxClicks == 0 {
+		return models.ErrBadRequest.WithMessage("URL has no click limit")
+	}
+
+	// First update the PostgreSQL database
+	if Req.Hours > 0 {
+		err := r.PsqlRepo.ExtendExpiry(ctx, current.WorkspaceId, Req.UrlId, Req.Hours)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.ErrUrlNotFound
+			}
+			return err
+		}
+	}
+	if Req.Clicks > 0 {
+		if err := r.PsqlRepo.AddMaxClicks(ctx, current.WorkspaceId, Req.UrlId, Req.Clicks); err != nil {
+			return err
+		}
+	}
 
 	// Now get the URL info to update Redis
-	urlInfo, err := r.PsqlRepo.GetUrlInfoByUserIdAndUrlRecordId(ctx, userId, Req.UrlId)
+	urlInfo, err := r.PsqlRepo.GetUrlInfoByRecordId(ctx, Req.UrlId)
 	if err != nil {
 		return err
 	}
 
-	if !urlInfo.IsActive {
-		return errors.New("URL is Inactive or Deleted")
+	// Update Redis expiry
+	if Req.Hours > 0 {
+		duration := time.Duration(Req.Hours) * time.Hour
+		err = r.RedisRepo.ExtendExpiry(ctx, urlInfo.OriginalUrl, urlInfo.LinkKey(), duration)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Update Redis expiry
-	duration := time.Duration(Req.Hours) * time.Hour
-	err = r.RedisRepo.ExtendExpiry(ctx, urlInfo.OriginalUrl, urlInfo.ShortUrl, duration)
+	// The cached entry carries the click limit enforced on redirect
+	if Req.Clicks > 0 && urlInfo.IsLive(time.Now()) {
+		if remaining := time.Until(urlInfo.ExpiresAt); remaining > 0 {
+			return r.RedisRepo.SaveUrl(ctx, urlInfo.LinkKey(), newCachedUrl(urlInfo), remaining)
+		}
+	}
+
+	return nil
+}
+
+// UpdateUrlService edits a link in place: destination, slug, absolute expiry,
+// redirect type, or reactivation. Postgres is updated first; Redis then gets the
+// new key with the remaining lifetime before the old key is removed, and Postgres
+// is restored if Redis cannot be updated. Editing takes the editor role in the
+// link's workspace.
+func (r *ShortUrlService) UpdateUrlService(userId uuid.UUID, urlId uuid.UUID, req *models.UpdateUrlReq, ctx context.Context) (*models.ShortenedUrlInfoRes, error) {
+	current, err := authorizeUrl(ctx, r.PsqlRepo, r.Workspaces, userId, urlId, workspaces.RoleEditor, "edit")
+	if err != nil {
+		return nil, err
+	}
+	// Links disabled by an admin stay down; editing could reactivate them
+	if current.DisabledAt != nil {
+		return nil, models.ErrUrlDisabled
+	}
+
+	previous := models.ShortenedUrlInfoReq{
+		UserId:         current.UserId,
+		WorkspaceId:    current.WorkspaceId,
+		Domain:         current.Domain,
+		OriginalUrl:    current.OriginalUrl,
+		ShortUrl:       current.ShortUrl,
+		ExpiresAt:      current.ExpiresAt,
+		IsActive:       current.IsActive,
+		RedirectType:   current.RedirectType,
+		PasswordHash:   current.PasswordHash,
+		MaxClicks:      current.MaxClicks,
+		ActivatesAt:    current.ActivatesAt,
+		RoutingRules:   current.RoutingRules,
+		Variants:       current.Variants,
+		StickyVariants: current.StickyVariants,
+		QueryParams:    current.QueryParams,
+		ForwardQuery:   current.ForwardQuery,
+		Preview:        current.Preview,
+	}
+	updated := previous
+
+	// Only destinations that change are vetted, so links created under an older
+	// policy can still be edited
+	var destinations []string
+	if req.OriginalUrl != nil {
+		destinations = append(destinations, *req.OriginalUrl)
+	}
+	if req.RoutingRules != nil {
+		destinations = append(destinations, linkDestinations("", *req.RoutingRules, nil)...)
+	}
+	if req.Variants != nil {
+		destinations = append(destinations, linkDestinations("", nil, *req.Variants)...)
+	}
+	if err := r.checkDestinations(ctx, destinations); err != nil {
+		return nil, err
+	}
+
+	if req.OriginalUrl != nil {
+		updated.OriginalUrl = *req.OriginalUrl
+	}
+	if req.RedirectType != nil {
+		updated.RedirectType = *req.RedirectType
+	}
+	if req.ExpiresAt != nil {
+		updated.ExpiresAt = *req.ExpiresAt
+	}
+	if req.ActivatesAt != nil {
+		updated.ActivatesAt = req.ActivatesAt
+		if !req.ActivatesAt.After(time.Now()) {
+			updated.ActivatesAt = nil
+		}
+	}
+	if updated.ActivatesAt != nil && !updated.ExpiresAt.After(*updated.ActivatesAt) {
+		return nil, models.ErrBadRequest.WithMessage("expires_at must be after activates_at")
+	}
+	if req.Reactivate {
+		// Deleted links keep their domain, which may since have been removed
+		if _, err := r.checkDomain(ctx, current.UserId, current.Domain); err != nil {
+			return nil, err
+		}
+		updated.IsActive = true
+	}
+	if req.RoutingRules != nil {
+		updated.RoutingRules = *req.RoutingRules
+	}
+	if req.Variants != nil {
+		updated.Variants = *req.Variants
+	}
+	if req.StickyVariants != nil {
+		updated.StickyVariants = *req.StickyVariants
+	}
+	if req.QueryParams != nil {
+		updated.QueryParams = *req.QueryParams
+	}
+	if req.ForwardQuery != nil {
+		updated.ForwardQuery = *req.ForwardQuery
+	}
+	if req.Preview != nil {
+		updated.Preview = *req.Preview
+	}
+	if req.Password != nil {
+		updated.PasswordHash = ""
+		if *req.Password != "" {
+			if updated.PasswordHash, err = utils.HashPassword(*req.Password); err != nil {
+				return nil, fmt.Errorf("failed to hash link password: %w", err)
+			}
+		}
+	}
+	if req.ShortUrl != nil && *req.ShortUrl != current.ShortUrl {
+		if err := r.checkCustomShortUrl(ctx, current.Domain, *req.ShortUrl); err != nil {
+			return nil, err
+		}
+		updated.ShortUrl = *req.ShortUrl
+	}
+
+	if updated.IsActive && !updated.ExpiresAt.After(time.Now()) {
+		if req.Reactivate {
+			return nil, models.ErrBadRequest.WithMessage("expires_at must be in the future to reactivate this URL")
+		}
+		if req.ExpiresAt != nil {
+			return nil, models.ErrBadRequest.WithMessage("expires_at must be in the future")
+		}
+	}
+
+	res, err := r.PsqlRepo.UpdateUrl(ctx, urlId, &updated)
+	if err != nil {
+		if errors.Is(err, repositories.ErrShortUrlExists) {
+			return nil, models.ErrShortUrlTaken
+		}
+		return nil, fmt.Errorf("failed to update url in DB: %w", err)
+	}
+
+	if err := r.syncCachedUrl(ctx, current, res); err != nil {
+		if _, rollbackErr := r.PsqlRepo.UpdateUrl(ctx, urlId, &previous); rollbackErr != nil {
+			log.Printf("Failed to roll back URL %s: %v", urlId, rollbackErr)
+		}
+		return nil, fmt.Errorf("failed to update url in Redis, rolled back DB: %w", err)
+	}
+
+	return r.withLink(res), nil
+}
+
+// checkDestinations runs the destination policy on each URL and reports the first one rejected
+func (r *ShortUrlService) checkDestinations(ctx context.Context, destinations []string) *models.AppError {
+	if r.Destinations == nil {
+		return nil
+	}
+	for _, destination := range destinations {
+		if err := r.Destinations.Check(ctx, destination); err != nil {
+			return models.ErrUnsafeDestination.WithMessage(err.Error()).WithDetails(map[string]string{"url": destination})
+		}
+	}
+	return nil
+}
+
+// linkDestinations lists every URL a link can redirect to; an empty original is left out
+func linkDestinations(original string, rules []models.RoutingRule, variants []models.Variant) []string {
+	destinations := make([]string, 0, 1+len(rules)+len(variants))
+	if original != "" {
+		destinations = append(destinations, original)
+	}
+	for _, rule := range rules {
+		destinations = append(destinations, rule.Destination)
+	}
+	for _, variant := range variants {
+		destinations = append(destinations, variant.Destination)
+	}
+	return destinations
+}
+
+// syncCachedUrl makes Redis reflect an edited link. A link that is inactive,
+// scheduled or already past its expiry has no key; a renamed link loses its old key.
+// A reactivated click-limited link gets all of its clicks back.
+func (r *ShortUrlService) syncCachedUrl(ctx context.Context, before, after *models.ShortenedUrlInfoRes) error {
+	remaining := time.Until(after.ExpiresAt)
+	if after.IsLive(time.Now()) {
+		entry := newCachedUrl(after)
+		if err := r.RedisRepo.SaveUrl(ctx, after.LinkKey(), entry, remaining); err != nil {
+			return err
+		}
+		if !before.IsActive && after.MaxClicks > 0 {
+			// The counter of a link that used up its clicks is still at the limit
+			if err := r.RedisRepo.ResetClickCounter(ctx, after.LinkKey()); err != nil {
+				_ = r.RedisRepo.DeleteKeys(ctx, after.LinkKey())
+				return err
+			}
+		}
+		if before.LinkKey() == after.LinkKey() {
+			return nil
+		}
+	}
+
+	if err := r.RedisRepo.DeleteKeys(ctx, before.LinkKey()); err != nil {
+		if after.LinkKey() != before.LinkKey() {
+			_ = r.RedisRepo.DeleteKeys(ctx, after.LinkKey())
+		}
+		return err
+	}
+	return nil
+}
+
+// UnlockShortUrl checks the password of a protected link and returns the link
+// so the caller can issue an unlock token for it. Links without a password
+// cannot be unlocked.
+func (r *ShortUrlService) UnlockShortUrl(ctx context.Context, key string, password string) (*models.CachedUrl, error) {
+	entry, err := r.ResolveShortUrl(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.Protected {
+		return nil, models.ErrBadRequest.WithMessage("This link is not password protected")
+	}
+
+	// The hash is only kept in Postgres; this path is rare and rate limited
+	domain, slug := models.SplitLinkKey(key)
+	urlInfo, err := r.PsqlRepo.GetActiveUrlByShortUrl(ctx, domain, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrUrlNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	// A stale cache entry can still be marked protected after an edit removed the password
+	if urlInfo.PasswordHash == "" {
+		return nil, models.ErrBadRequest.WithMessage("This link is not password protected")
+	}
+	if utils.VerifyPassword(urlInfo.PasswordHash, password) != nil {
+		return nil, models.ErrInvalidPassword
+	}
+	return entry, nil
+}
+
+// ConsumeClick counts one visit of a click-limited link. The Redis counter enforces
+// the limit and is copied to the link's row after every click, so a counter lost
+// to eviction or a flush is seeded again from Postgres. The visit that uses the
+// last click is still served, after which the link is deactivated the same way
+// an expired link is; later visits get ErrUrlNotFound.
+func (r *ShortUrlService) ConsumeClick(ctx context.Context, key string, entry *models.CachedUrl) error {
+	if entry.MaxClicks == 0 {
+		return nil
+	}
+
+	used, err := r.RedisRepo.ConsumeClick(ctx, key, entry.MaxClicks)
+	if errors.Is(err, repositories.ErrClickCounterMissing) {
+		// First click since the link was cached; clicks used so far still count
+		stored, storedErr := r.PsqlRepo.GetClicksUsed(ctx, entry.Id)
+		if storedErr != nil {
+			return storedErr
+		}
+		if err := r.RedisRepo.SeedClickCounter(ctx, key, stored); err != nil {
+			return err
+		}
+		used, err = r.RedisRepo.ConsumeClick(ctx, key, entry.MaxClicks)
+	}
+	if errors.Is(err, repositories.ErrClickLimitReached) || errors.Is(err, repositories.ErrClickCounterMissing) {
+		return models.ErrUrlNotFound
+	}
 	if err != nil {
 		return err
 	}
 
+	if err := r.PsqlRepo.SetClicksUsed(ctx, entry.Id, used); err != nil {
+		log.Printf("Failed to store clicks used of %s: %v", key, err)
+	}
+	if used >= int64(entry.MaxClicks) {
+		domain, slug := models.SplitLinkKey(key)
+		if err := r.PsqlRepo.MarkUrlAsExpired(ctx, domain, slug); err != nil {
+			log.Printf("Failed to deactivate %s after its last click: %v", key, err)
+		}
+		// The counter is kept until it expires so in-flight visits still see the limit
+		if err := r.RedisRepo.DeleteKeys(ctx, key); err != nil {
+			log.Printf("Failed to remove %s from cache after its last click: %v", key, err)
+		}
+	}
 	return nil
 }
 
-func (r *ShortUrlService) GetOriginalUrl(ctx context.Context, shortUrl string) (string, error) {
-	original, exists := r.RedisRepo.GetOriginalUrl(ctx, shortUrl)
+// GetOriginalUrl returns the default destination of a link key with the link's
+// query parameters merged in; visitor-specific routing is left to the caller.
+func (r *ShortUrlService) GetOriginalUrl(ctx context.Context, key string) (string, error) {
+	entry, err := r.ResolveShortUrl(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	_, slug := models.SplitLinkKey(key)
+	return entry.WithQuery(entry.OriginalUrl, "", map[string]string{"short_id": slug}), nil
+}
+
+// PreviewShortUrl looks a short URL up like a redirect does, so the preview page
+// shows exactly the links that would redirect, and adds its creation date and owner.
+func (r *ShortUrlService) PreviewShortUrl(ctx context.Context, key string) (*models.UrlPreview, error) {
+	entry, err := r.ResolveShortUrl(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	domain, slug := models.SplitLinkKey(key)
+	createdAt, ownerName, err := r.PsqlRepo.GetUrlOwnerInfo(ctx, domain, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrUrlNotFound
+		}
+		return nil, fmt.Errorf("failed to load link owner: %w", err)
+	}
+	return &models.UrlPreview{Entry: entry, CreatedAt: createdAt, OwnerName: ownerName}, nil
+}
+
+// ResolveShortUrl returns the destination and redirect settings of the active link
+// with the given key, see models.LinkKey.
+func (r *ShortUrlService) ResolveShortUrl(ctx context.Context, key string) (*models.CachedUrl, error) {
+	entry, exists := r.RedisRepo.GetUrl(ctx, key)
 	if !exists {
-		return "", errors.New("URL not found in cache")
+		// Cache miss (flush, restart or eviction) - fall back to Postgres.
+		// The lookup is detached from the caller's cancellation since other
+		// requests for the same key may be waiting on its result.
+		result, err, _ := r.cacheMisses.Do(key, func() (interface{}, error) {
+			return r.rewarmFromDatabase(context.WithoutCancel(ctx), key)
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Copy so callers sharing the flight cannot affect each other
+		shared := *result.(*models.CachedUrl)
+		entry = &shared
+	}
+	if entry.RedirectType == 0 {
+		entry.RedirectType = models.DefaultRedirectType
 	}
-	return original, nil
+	return entry, nil
+}
+
+// rewarmFromDatabase loads an active link from Postgres and puts it back
+// into Redis with whatever lifetime it has left.
+func (r *ShortUrlService) rewarmFromDatabase(ctx context.Context, key string) (*models.CachedUrl, error) {
+	domain, slug := models.SplitLinkKey(key)
+	urlInfo, err := r.PsqlRepo.GetActiveUrlByShortUrl(ctx, domain, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrUrlNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if urlInfo.IsScheduled(time.Now()) {
+		return nil, models.ErrUrlNotYetActive.WithDetails(map[string]time.Time{
+			"activates_at": *urlInfo.ActivatesAt,
+		})
+	}
+
+	remaining := time.Until(urlInfo.ExpiresAt)
+	if remaining <= 0 {
+		return nil, models.ErrUrlNotFound
+	}
+
+	entry := newCachedUrl(urlInfo)
+
+	// A failed re-warm still serves this request from the database row
+	if err := r.RedisRepo.SaveUrl(ctx, key, entry, remaining); err != nil {
+		log.Printf("Failed to re-warm Redis for %s: %v", key, err)
+	}
+
+	return entry, nil
 }
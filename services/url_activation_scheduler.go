@@ -0,0 +1,96 @@
+package services
+
+import (
+	"U-235/repositories"
+	"context"
+	"log"
+	"time"
+)
+
+// activationCatchUp is how far back the scheduler looks on startup, so links that
+// went live while no instance was running are cached without waiting for a visit.
+const activationCatchUp = 24 * time.Hour
+
+type ActivationScheduler interface {
+	Start(ctx context.Context)
+	Stop()
+}
+
+// UrlActivationScheduler pushes scheduled links into Redis once their activation
+// time has passed. Redirects do not depend on it: a visit after the activation
+// time re-warms the link from Postgres, so the interval only bounds how long a
+// freshly activated link is served from the database instead of the cache.
+type UrlActivationScheduler struct {
+	redisRepo repositories.RedisRepo
+	psqlRepo  repositories.UrlsPsql
+	interval  time.Duration
+	stopChan  chan struct{}
+}
+
+func NewUrlActivationScheduler(redisRepo repositories.RedisRepo, psqlRepo repositories.UrlsPsql, interval time.Duration) ActivationScheduler {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &UrlActivationScheduler{
+		redisRepo: redisRepo,
+		psqlRepo:  psqlRepo,
+		interval:  interval,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+func (s *UrlActivationScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		log.Println("Link activation scheduler started")
+
+		since := time.Now().Add(-activationCatchUp)
+		for {
+			since = s.activate(ctx, since)
+
+			select {
+			case <-ticker.C:
+			case <-s.stopChan:
+				log.Println("Link activation scheduler stopped")
+				return
+			case <-ctx.Done():
+				log.Println("Link activation scheduler stopped due to context cancellation")
+				return
+			}
+		}
+	}()
+}
+
+func (s *UrlActivationScheduler) Stop() {
+	close(s.stopChan)
+}
+
+// activate caches the links that went live after since and returns the time the
+// next run should start from. On failure the same window is retried next time.
+func (s *UrlActivationScheduler) activate(ctx context.Context, since time.Time) time.Time {
+	now := time.Now()
+	urls, err := s.psqlRepo.GetUrlsActivatedBetween(ctx, since, now)
+	if err != nil {
+		log.Printf("Failed to load activated links: %v", err)
+		return since
+	}
+
+	writes := make([]repositories.CachedUrlWrite, 0, len(urls))
+	for i := range urls {
+		writes = append(writes, repositories.CachedUrlWrite{
+			ShortUrl: urls[i].LinkKey(),
+			Entry:    newCachedUrl(&urls[i]),
+			TTL:      urls[i].ExpiresAt.Sub(now),
+		})
+	}
+	if err := s.redisRepo.SaveUrls(ctx, writes); err != nil {
+		log.Printf("Failed to cache %d activated link(s): %v", len(writes), err)
+		return since
+	}
+
+	if len(writes) > 0 {
+		log.Printf("Activated %d scheduled link(s)", len(writes))
+	}
+	return now
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"U-235/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// activatedUrls answers GetUrlsActivatedBetween from the fake repository's links
+type activatedUrls struct {
+	*fakeUrlsPsql
+
+	err error
+}
+
+func (a *activatedUrls) GetUrlsActivatedBetween(ctx context.Context, from, to time.Time) ([]models.ShortenedUrlInfoRes, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	var urls []models.ShortenedUrlInfoRes
+	for _, url := range a.urls {
+		if url.ActivatesAt != nil && url.ActivatesAt.After(from) && !url.ActivatesAt.After(to) && url.IsActive && url.ExpiresAt.After(to) {
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+func TestActivationSchedulerActivate(t *testing.T) {
+	now := time.Now()
+	since := now.Add(-time.Minute)
+	scheduled := func(shortUrl string, activatesAt time.Time) models.ShortenedUrlInfoRes {
+		return models.ShortenedUrlInfoRes{
+			Id:          uuid.New(),
+			OriginalUrl: "https://example.com/" + shortUrl,
+			ShortUrl:    shortUrl,
+			ActivatesAt: &activatesAt,
+			ExpiresAt:   activatesAt.Add(time.Hour),
+			IsActive:    true,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		loadErr    error
+		failCache  bool
+		wantCached []string
+		wantSince  bool // the next run starts from the same window
+	}{
+		{name: "caches links that went live", wantCached: []string{"went-live"}},
+		{name: "database error", loadErr: errors.New("connection refused"), wantSince: true},
+		{name: "cache error", failCache: true, wantSince: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+			psql.urls["too-early"] = scheduled("too-early", since.Add(-time.Second))
+			psql.urls["went-live"] = scheduled("went-live", now.Add(-30*time.Second))
+			psql.urls["still-waiting"] = scheduled("still-waiting", now.Add(time.Hour))
+			redis.failSave = tt.failCache
+			scheduler := NewUrlActivationScheduler(redis, &activatedUrls{fakeUrlsPsql: psql, err: tt.loadErr}, time.Minute).(*UrlActivationScheduler)
+
+			next := scheduler.activate(context.Background(), since)
+			if tt.wantSince != next.Equal(since) {
+				t.Errorf("activate() = %s, want since = %t", next, tt.wantSince)
+			}
+			if len(redis.urls) != len(tt.wantCached) {
+				t.Errorf("cached %d link(s), want %v", len(redis.urls), tt.wantCached)
+			}
+			for _, key := range tt.wantCached {
+				if entry, ok := redis.urls[key]; !ok || entry.OriginalUrl != "https://example.com/"+key {
+					t.Errorf("%s cached as %+v", key, entry)
+				}
+			}
+		})
+	}
+}
+
+func TestActivationWindow(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		activatesAt *time.Time
+		wantStart   *time.Time
+		wantExpiry  time.Time
+	}{
+		{name: "immediate", wantExpiry: now.Add(24 * time.Hour)},
+		{name: "start in the past", activatesAt: &past, wantExpiry: now.Add(24 * time.Hour)},
+		{name: "scheduled", activatesAt: &future, wantStart: &future, wantExpiry: future.Add(24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, expiry := activationWindow(now, tt.activatesAt, 24)
+			if (start == nil) != (tt.wantStart == nil) || (start != nil && !start.Equal(*tt.wantStart)) {
+				t.Errorf("activationWindow() start = %v, want %v", start, tt.wantStart)
+			}
+			if !expiry.Equal(tt.wantExpiry) {
+				t.Errorf("activationWindow() expiry = %s, want %s", expiry, tt.wantExpiry)
+			}
+		})
+	}
+}
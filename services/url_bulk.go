@@ -0,0 +1,358 @@
+package services
+
+import (
+	"U-235/core"
+	"U-235/core/workspaces"
+	"U-235/models"
+	"U-235/repositories"
+	"U-235/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"time"
+)
+
+// bulkBatchSize bounds the rows per multi-row INSERT and the commands per Redis pipeline
+const bulkBatchSize = 500
+
+var (
+	ErrBulkAborted = models.NewAppError("BULK_ABORTED", "No links were created because at least one item failed", http.StatusUnprocessableEntity)
+	ErrNoFreeId    = models.NewAppError("NO_FREE_SHORT_ID", "No free short ID available", http.StatusServiceUnavailable)
+)
+
+// bulkItem tracks one link through a bulk insert
+type bulkItem struct {
+	info models.ShortenedUrlInfoReq
+	// custom is set while the short URL is the caller's choice rather than generated
+	custom bool
+	// renamed is set once a taken custom short URL was replaced by a generated one
+	renamed bool
+	// taken is set when a custom short URL was already in use and not renamed
+	taken bool
+	saved *models.ShortenedUrlInfoRes
+	err   *models.AppError
+}
+
+// BulkCreateUrlService creates many links for one user with batched inserts and a
+// pipelined cache write. It reuses the custom slug rules and ID generator of
+// CreateUrlService and returns one result per request item, in order. All links
+// go to workspaceID, like CreateUrlService.
+// In atomic mode any failure rolls back every created link and ErrBulkAborted
+// is returned along with the results.
+func (r *ShortUrlService) BulkCreateUrlService(userID uuid.UUID, workspaceID uuid.UUID, reqs []models.CreateShortUrlReq, atomic bool, ctx context.Context) ([]models.BulkCreateItemRes, error) {
+	workspaceID, _, err := r.Workspaces.Authorize(ctx, userID, workspaceID, workspaces.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*bulkItem, len(reqs))
+	customSlugs := make(map[string]bool)
+
+	now := time.Now()
+	for i, req := range reqs {
+		item := &bulkItem{
+			info: models.ShortenedUrlInfoReq{
+				UserId:         userID,
+				WorkspaceId:    workspaceID,
+				OriginalUrl:    req.OriginalUrl,
+				ShortUrl:       req.CustomShortUrl,
+				IsActive:       true,
+				RedirectType:   req.RedirectType,
+				MaxClicks:      req.MaxClicks,
+				RoutingRules:   req.RoutingRules,
+				Variants:       req.Variants,
+				StickyVariants: req.StickyVariants,
+				QueryParams:    req.QueryParams,
+				ForwardQuery:   req.ForwardQuery,
+				Preview:        req.Preview,
+			},
+			custom: req.CustomShortUrl != "",
+		}
+		item.info.ActivatesAt, item.info.ExpiresAt = activationWindow(now, req.ActivatesAt, req.ExpireTime)
+		if item.info.RedirectType == 0 {
+			item.info.RedirectType = models.DefaultRedirectType
+		}
+		items[i] = item
+
+		domain, err := r.checkDomain(ctx, userID, req.Domain)
+		if err != nil {
+			item.err = err
+			continue
+		}
+		item.info.Domain = domain
+
+		if err := r.checkDestinations(ctx, linkDestinations(req.OriginalUrl, req.RoutingRules, req.Variants)); err != nil {
+			item.err = err
+			continue
+		}
+
+		if req.Password != "" {
+			hash, err := utils.HashPassword(req.Password)
+			if err != nil {
+				item.err = models.ErrInternal.Wrap(err)
+				continue
+			}
+			item.info.PasswordHash = hash
+		}
+
+		if item.custom {
+			key := models.LinkKey(domain, req.CustomShortUrl)
+			if err := validateCustomShortUrl(req.CustomShortUrl); err != nil {
+				item.err = err
+			} else if customSlugs[key] {
+				item.err = models.ErrShortUrlTaken.WithMessage("Custom short url is used by another item")
+			}
+			customSlugs[key] = true
+		}
+	}
+
+	err = r.saveBulk(ctx, userID, items, false, atomic)
+
+	results := make([]models.BulkCreateItemRes, len(items))
+	for i, item := range items {
+		results[i] = models.BulkCreateItemRes{
+			Index:   i,
+			Success: item.saved != nil,
+			Url:     r.withLink(item.saved),
+			Error:   item.err,
+		}
+		if item.taken {
+			results[i].Error = models.ErrShortUrlTaken
+		}
+	}
+	return results, err
+}
+
+// saveBulk inserts and caches items, skipping those that already carry an error.
+// With renameOnConflict a taken custom short URL is swapped for a generated one.
+// In atomic mode nothing is saved if any item fails or is taken.
+func (r *ShortUrlService) saveBulk(ctx context.Context, userID uuid.UUID, items []*bulkItem, renameOnConflict bool, atomic bool) error {
+	if atomic {
+		if err := r.insertBulkAtomic(ctx, items, renameOnConflict); err != nil {
+			return err
+		}
+	} else {
+		r.insertBulk(ctx, items, renameOnConflict, r.PsqlRepo.SaveUrls)
+	}
+
+	// Cache the live links; a chunk that cannot be cached is removed again
+	cacheable := make([]*bulkItem, 0, len(items))
+	now := time.Now()
+	for _, item := range items {
+		if item.saved != nil && item.saved.IsLive(now) {
+			cacheable = append(cacheable, item)
+		}
+	}
+
+	var uncached []uuid.UUID
+	for start := 0; start < len(cacheable); start += bulkBatchSize {
+		chunk := cacheable[start:min(start+bulkBatchSize, len(cacheable))]
+
+		writes := make([]repositories.CachedUrlWrite, len(chunk))
+		for j, item := range chunk {
+			writes[j] = repositories.CachedUrlWrite{
+				ShortUrl: item.saved.LinkKey(),
+				Entry:    newCachedUrl(item.saved),
+				TTL:      time.Until(item.saved.ExpiresAt),
+			}
+		}
+
+		if err := r.RedisRepo.SaveUrls(ctx, writes); err != nil {
+			log.Printf("Failed to cache bulk created urls: %v", err)
+			if atomic {
+				r.rollbackBulk(ctx, userID, items)
+				for _, item := range chunk {
+					item.err = models.ErrInternal
+				}
+				return ErrBulkAborted
+			}
+			for _, item := range chunk {
+				uncached = append(uncached, item.saved.Id)
+				item.saved = nil
+				item.err = models.ErrInternal
+			}
+		}
+	}
+
+	if err := r.PsqlRepo.DeleteUrlRecords(ctx, userID, uncached); err != nil {
+		log.Printf("Failed to roll back uncached bulk urls: %v", err)
+	}
+	return nil
+}
+
+// insertBulkAtomic runs insertBulk in one transaction that is only committed if
+// every item was saved, so the links are created all together or not at all
+func (r *ShortUrlService) insertBulkAtomic(ctx context.Context, items []*bulkItem, renameOnConflict bool) error {
+	failed := func() bool {
+		for _, item := range items {
+			if item.err != nil || item.taken {
+				return true
+			}
+		}
+		return false
+	}
+	discard := func(err *models.AppError) {
+		for _, item := range items {
+			if item.saved != nil {
+				item.saved = nil
+				item.err = err
+			}
+		}
+	}
+
+	if failed() {
+		return ErrBulkAborted
+	}
+
+	batch, err := r.PsqlRepo.BeginUrlBatch(ctx)
+	if err != nil {
+		return models.ErrInternal.Wrap(err)
+	}
+	r.insertBulk(ctx, items, renameOnConflict, batch.SaveUrls)
+	if failed() {
+		if err := batch.Rollback(); err != nil {
+			log.Printf("Failed to roll back bulk url batch: %v", err)
+		}
+		discard(nil)
+		return ErrBulkAborted
+	}
+	if err := batch.Commit(); err != nil {
+		log.Printf("Failed to commit bulk url batch: %v", err)
+		discard(models.ErrInternal)
+		return ErrBulkAborted
+	}
+	return nil
+}
+
+// insertBulk saves items in batches through save, generating short IDs where no
+// custom slug was given. Generated IDs that collide are regenerated, growing the
+// shared ID length like saveWithGeneratedId does.
+func (r *ShortUrlService) insertBulk(ctx context.Context, items []*bulkItem, renameOnConflict bool, save func(context.Context, []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error)) {
+	pending := make([]*bulkItem, 0, len(items))
+	for _, item := range items {
+		if item.err == nil && !item.taken {
+			pending = append(pending, item)
+		}
+	}
+
+	for round := 1; len(pending) > 0; round++ {
+		// Link keys must be unique within a round so every returned row maps to one item
+		used := make(map[string]bool, len(pending))
+		for _, item := range pending {
+			if item.custom {
+				used[models.LinkKey(item.info.Domain, item.info.ShortUrl)] = true
+			}
+		}
+		length := int(r.idLength.Load())
+		for _, item := range pending {
+			if item.custom {
+				continue
+			}
+			shortID, err := r.generateUnusedId(ctx, length, item.info.Domain, used)
+			if errors.Is(err, ErrNoFreeId) {
+				item.err = ErrNoFreeId
+				continue
+			} else if err != nil {
+				item.err = models.ErrInternal.Wrap(err)
+				continue
+			}
+			used[models.LinkKey(item.info.Domain, shortID)] = true
+			item.info.ShortUrl = shortID
+		}
+
+		var retry []*bulkItem
+		for start := 0; start < len(pending); start += bulkBatchSize {
+			chunk := pending[start:min(start+bulkBatchSize, len(pending))]
+
+			batch := make([]models.ShortenedUrlInfoReq, 0, len(chunk))
+			for _, item := range chunk {
+				if item.err == nil {
+					batch = append(batch, item.info)
+				}
+			}
+
+			rows, err := save(ctx, batch)
+			if err != nil {
+				log.Printf("Failed to insert bulk url batch: %v", err)
+				for _, item := range chunk {
+					if item.err == nil {
+						item.err = models.ErrInternal
+					}
+				}
+				continue
+			}
+
+			byKey := make(map[string]*models.ShortenedUrlInfoRes, len(rows))
+			for k := range rows {
+				byKey[rows[k].LinkKey()] = &rows[k]
+			}
+			for _, item := range chunk {
+				if item.err != nil {
+					continue
+				}
+				if row, ok := byKey[models.LinkKey(item.info.Domain, item.info.ShortUrl)]; ok {
+					item.saved = row
+				} else if !item.custom {
+					retry = append(retry, item)
+				} else if renameOnConflict {
+					item.custom = false
+					item.renamed = true
+					retry = append(retry, item)
+				} else {
+					item.taken = true
+				}
+			}
+		}
+
+		pending = retry
+		if len(pending) == 0 || round%maxIdAttemptsPerLength != 0 {
+			continue
+		}
+		if length >= core.MaxShortIDLength {
+			for _, item := range pending {
+				item.err = ErrNoFreeId
+			}
+			break
+		}
+		if r.idLength.CompareAndSwap(int32(length), int32(length+1)) {
+			log.Printf("Short ID keyspace crowded at length %d, growing to %d", length, length+1)
+		}
+	}
+}
+
+// generateUnusedId returns a short ID that is servable, not reserved, and whose
+// link key on domain is not in used
+func (r *ShortUrlService) generateUnusedId(ctx context.Context, length int, domain string, used map[string]bool) (string, error) {
+	for attempt := 0; attempt < maxIdAttemptsPerLength*10; attempt++ {
+		shortID, err := r.IdGenerator.Generate(ctx, length)
+		if err != nil {
+			return "", err
+		}
+		if !used[models.LinkKey(domain, shortID)] && !core.IsReservedPath(shortID) && core.IsValidShortIdFormat(shortID) {
+			return shortID, nil
+		}
+	}
+	return "", ErrNoFreeId
+}
+
+// rollbackBulk deletes the committed links of an atomic bulk request that could
+// not be cached, along with the Redis keys already written for them.
+func (r *ShortUrlService) rollbackBulk(ctx context.Context, userID uuid.UUID, items []*bulkItem) {
+	ids := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		if item.saved == nil {
+			continue
+		}
+		ids = append(ids, item.saved.Id)
+		if err := r.RedisRepo.DeleteKeys(ctx, item.saved.LinkKey()); err != nil {
+			log.Printf("Failed to remove %s from cache during bulk rollback: %v", item.saved.LinkKey(), err)
+		}
+		item.saved = nil
+	}
+
+	if err := r.PsqlRepo.DeleteUrlRecords(ctx, userID, ids); err != nil {
+		log.Printf("Failed to roll back bulk created urls: %v", err)
+	}
+}
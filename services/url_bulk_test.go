@@ -0,0 +1,211 @@
+package services
+
+import (
+	"U-235/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// seedTakenUrl stores a link another user already has under key
+func seedTakenUrl(psql *fakeUrlsPsql, shortUrl string) {
+	psql.urls[shortUrl] = models.ShortenedUrlInfoRes{
+		Id:        uuid.New(),
+		UserId:    uuid.New(),
+		ShortUrl:  shortUrl,
+		ExpiresAt: time.Now().Add(time.Hour),
+		IsActive:  true,
+	}
+}
+
+func TestBulkCreateUrlService(t *testing.T) {
+	psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+	seedTakenUrl(psql, "taken-one")
+	seedTakenUrl(psql, "g000001")
+	service := newTestUrlService(psql, redis, &sequentialIds{})
+
+	reqs := []models.CreateShortUrlReq{
+		{OriginalUrl: "https://example.com/a", ExpireTime: 24, CustomShortUrl: "taken-one"},
+		{OriginalUrl: "https://example.com/b", ExpireTime: 24, CustomShortUrl: "fresh-one"},
+		{OriginalUrl: "https://example.com/c", ExpireTime: 24},
+		{OriginalUrl: "https://example.com/d", ExpireTime: 24, CustomShortUrl: "fresh-one"},
+		{OriginalUrl: "https://example.com/e", ExpireTime: 24, CustomShortUrl: "ab"},
+	}
+	results, err := service.BulkCreateUrlService(uuid.New(), uuid.New(), reqs, false, context.Background())
+	if err != nil {
+		t.Fatalf("BulkCreateUrlService() error = %v", err)
+	}
+
+	wantErrs := []error{models.ErrShortUrlTaken, nil, nil, models.ErrShortUrlTaken, models.ErrInvalidShortUrl}
+	for i, want := range wantErrs {
+		res := results[i]
+		if want == nil {
+			if !res.Success || res.Error != nil {
+				t.Errorf("item %d: success = %t, error = %v; want success", i, res.Success, res.Error)
+			}
+			continue
+		}
+		if res.Success || !errors.Is(res.Error, want) {
+			t.Errorf("item %d: success = %t, error = %v; want %v", i, res.Success, res.Error, want)
+		}
+	}
+
+	// The generated ID collided with a taken link and was generated again
+	if got := results[2].Url.ShortUrl; got != "g000002" {
+		t.Errorf("generated short url = %q, want %q", got, "g000002")
+	}
+	for _, key := range []string{"fresh-one", "g000002"} {
+		if _, ok := redis.urls[key]; !ok {
+			t.Errorf("%s was not cached", key)
+		}
+	}
+	if psql.urls["taken-one"].OriginalUrl != "" {
+		t.Errorf("taken link was overwritten: %+v", psql.urls["taken-one"])
+	}
+}
+
+func TestBulkCreateUrlServiceAtomic(t *testing.T) {
+	tests := []struct {
+		name      string
+		reqs      []models.CreateShortUrlReq
+		failCache bool
+	}{
+		{
+			name: "taken short url",
+			reqs: []models.CreateShortUrlReq{
+				{OriginalUrl: "https://example.com/a", ExpireTime: 24, CustomShortUrl: "fresh-one"},
+				{OriginalUrl: "https://example.com/b", ExpireTime: 24},
+				{OriginalUrl: "https://example.com/c", ExpireTime: 24, CustomShortUrl: "taken-one"},
+			},
+		},
+		{
+			name: "invalid item",
+			reqs: []models.CreateShortUrlReq{
+				{OriginalUrl: "https://example.com/a", ExpireTime: 24, CustomShortUrl: "fresh-one"},
+				{OriginalUrl: "https://example.com/b", ExpireTime: 24, CustomShortUrl: "ab"},
+			},
+		},
+		{
+			name: "cache unavailable",
+			reqs: []models.CreateShortUrlReq{
+				{OriginalUrl: "https://example.com/a", ExpireTime: 24, CustomShortUrl: "fresh-one"},
+				{OriginalUrl: "https://example.com/b", ExpireTime: 24},
+			},
+			failCache: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+			seedTakenUrl(psql, "taken-one")
+			redis.failSave = tt.failCache
+			service := newTestUrlService(psql, redis, &sequentialIds{})
+
+			results, err := service.BulkCreateUrlService(uuid.New(), uuid.New(), tt.reqs, true, context.Background())
+			if !errors.Is(err, ErrBulkAborted) {
+				t.Fatalf("BulkCreateUrlService() error = %v, want %v", err, ErrBulkAborted)
+			}
+			for i, res := range results {
+				if res.Success {
+					t.Errorf("item %d reported success in an aborted request", i)
+				}
+			}
+			if len(psql.urls) != 1 {
+				t.Errorf("links after abort = %d, want only the taken one", len(psql.urls))
+			}
+			if len(redis.urls) != 0 {
+				t.Errorf("cached links after abort = %d, want 0", len(redis.urls))
+			}
+		})
+	}
+}
+
+func TestImportUrlsService(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour)
+	rows := []models.UrlExportRow{
+		{OriginalUrl: "https://example.com/a", ShortUrl: "taken-one", ExpiresAt: expires, IsActive: true},
+		{OriginalUrl: "https://example.com/b", ShortUrl: "fresh-one", ExpiresAt: expires, IsActive: true},
+		{OriginalUrl: "https://example.com/c", ShortUrl: "fresh-one", ExpiresAt: expires, IsActive: true},
+		{OriginalUrl: "https://example.com/d", ShortUrl: "old-one", ExpiresAt: expires, IsActive: false},
+	}
+
+	tests := []struct {
+		onConflict string
+		wantStatus []string
+		wantErr    error
+		wantLinks  int
+	}{
+		{
+			onConflict: models.ImportConflictSkip,
+			wantStatus: []string{models.ImportStatusSkipped, models.ImportStatusCreated, models.ImportStatusSkipped, models.ImportStatusCreated},
+			wantLinks:  3,
+		},
+		{
+			onConflict: models.ImportConflictRename,
+			wantStatus: []string{models.ImportStatusRenamed, models.ImportStatusCreated, models.ImportStatusRenamed, models.ImportStatusCreated},
+			wantLinks:  5,
+		},
+		{
+			onConflict: models.ImportConflictFail,
+			wantStatus: []string{models.ImportStatusSkipped, models.ImportStatusSkipped, models.ImportStatusFailed, models.ImportStatusSkipped},
+			wantErr:    ErrBulkAborted,
+			wantLinks:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onConflict, func(t *testing.T) {
+			psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+			seedTakenUrl(psql, "taken-one")
+			service := newTestUrlService(psql, redis, &sequentialIds{})
+
+			results, err := service.ImportUrlsService(uuid.New(), uuid.New(), rows, tt.onConflict, context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ImportUrlsService() error = %v, want %v", err, tt.wantErr)
+			}
+			for i, want := range tt.wantStatus {
+				if got := results[i].Status; got != want {
+					t.Errorf("row %d: status = %q (error %v), want %q", i, got, results[i].Error, want)
+				}
+			}
+			if len(psql.urls) != tt.wantLinks {
+				t.Errorf("links after import = %d, want %d", len(psql.urls), tt.wantLinks)
+			}
+			if _, ok := redis.urls["old-one"]; ok {
+				t.Error("inactive imported link was cached")
+			}
+		})
+	}
+}
+
+func TestGeneratedIdsSkipUnservable(t *testing.T) {
+	unservable := []string{"abc123-", "abc/123"}
+	userId := uuid.New()
+
+	t.Run("single", func(t *testing.T) {
+		psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+		service := newTestUrlService(psql, redis, &sequentialIds{first: unservable})
+
+		res, err := service.CreateUrlService(userId, uuid.Nil, &models.CreateShortUrlReq{OriginalUrl: "https://example.com", ExpireTime: 24}, context.Background())
+		if err != nil {
+			t.Fatalf("CreateUrlService() error = %v", err)
+		}
+		if res.ShortUrl != "g000001" {
+			t.Errorf("generated short url = %q, want %q", res.ShortUrl, "g000001")
+		}
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+		service := newTestUrlService(psql, redis, &sequentialIds{first: unservable})
+
+		results, err := service.BulkCreateUrlService(userId, uuid.Nil, []models.CreateShortUrlReq{{OriginalUrl: "https://example.com", ExpireTime: 24}}, false, context.Background())
+		if err != nil {
+			t.Fatalf("BulkCreateUrlService() error = %v", err)
+		}
+		if !results[0].Success || results[0].Url.ShortUrl != "g000001" {
+			t.Errorf("result = %+v, want g000001", results[0])
+		}
+	})
+}
@@ -1,11 +1,13 @@
 package services
 
 import (
+	"U-235/models"
 	"U-235/repositories"
 	"context"
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"log"
+	"strings"
 )
 
 type ExpirationService interface {
@@ -82,12 +84,19 @@ func (s *RedisExpirationService) Stop() {
 	}
 }
 
-func (s *RedisExpirationService) handleExpiredUrl(ctx context.Context, shortUrl string) error {
-	err := s.psqlRepo.MarkUrlAsExpired(ctx, shortUrl)
+func (s *RedisExpirationService) handleExpiredUrl(ctx context.Context, key string) error {
+	// Internal keys (token revocations, counters) are namespaced with ':' which
+	// link keys can never contain
+	if strings.Contains(key, ":") {
+		return nil
+	}
+
+	domain, shortUrl := models.SplitLinkKey(key)
+	err := s.psqlRepo.MarkUrlAsExpired(ctx, domain, shortUrl)
 	if err != nil {
 		return fmt.Errorf("failed to mark URL as expired in database: %w", err)
 	}
 
-	log.Printf("Successfully marked URL %s as expired in database", shortUrl)
+	log.Printf("Successfully marked URL %s as expired in database", key)
 	return nil
 }
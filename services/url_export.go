@@ -0,0 +1,142 @@
+package services
+
+import (
+	"U-235/core"
+	"U-235/core/workspaces"
+	"U-235/models"
+	"context"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// ExportUrlsService streams every link of a workspace to fn in export row form.
+// Exports carry password hashes, so they take the editor role like imports do.
+func (r *ShortUrlService) ExportUrlsService(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, fn func(*models.UrlExportRow) error) error {
+	workspaceId, _, err := r.Workspaces.Authorize(ctx, userId, workspaceId, workspaces.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	return r.PsqlRepo.EachWorkspaceUrl(ctx, workspaceId, func(urlInfo *models.ShortenedUrlInfoRes) error {
+		return fn(&models.UrlExportRow{
+			OriginalUrl:    urlInfo.OriginalUrl,
+			ShortUrl:       urlInfo.ShortUrl,
+			ShortLink:      r.Links.Link(urlInfo.Domain, urlInfo.ShortUrl),
+			Domain:         urlInfo.Domain,
+			ExpiresAt:      urlInfo.ExpiresAt,
+			IsActive:       urlInfo.IsActive,
+			RedirectType:   urlInfo.RedirectType,
+			CreatedAt:      urlInfo.CreatedAt,
+			PasswordHash:   urlInfo.PasswordHash,
+			MaxClicks:      urlInfo.MaxClicks,
+			ActivatesAt:    urlInfo.ActivatesAt,
+			RoutingRules:   urlInfo.RoutingRules,
+			Variants:       urlInfo.Variants,
+			StickyVariants: urlInfo.StickyVariants,
+			QueryParams:    urlInfo.QueryParams,
+			ForwardQuery:   urlInfo.ForwardQuery,
+			Preview:        urlInfo.Preview,
+		})
+	})
+}
+
+// ImportUrlsService recreates exported links in a workspace, keeping their domain,
+// short URL, state and expiry. A short URL that is already taken on its domain, in
+// the database or earlier in the file, is handled by onConflict: skipped, renamed to a generated ID, or
+// failing the whole import with ErrBulkAborted. Active rows are cached in Redis.
+func (r *ShortUrlService) ImportUrlsService(userId uuid.UUID, workspaceId uuid.UUID, rows []models.UrlExportRow, onConflict string, ctx context.Context) ([]models.ImportRowRes, error) {
+	workspaceId, _, err := r.Workspaces.Authorize(ctx, userId, workspaceId, workspaces.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*bulkItem, len(rows))
+	seen := make(map[string]bool, len(rows))
+
+	now := time.Now()
+	for i, row := range rows {
+		item := &bulkItem{
+			info: models.ShortenedUrlInfoReq{
+				UserId:         userId,
+				WorkspaceId:    workspaceId,
+				OriginalUrl:    row.OriginalUrl,
+				ShortUrl:       row.ShortUrl,
+				ExpiresAt:      row.ExpiresAt,
+				IsActive:       row.IsActive && row.ExpiresAt.After(now),
+				RedirectType:   row.RedirectType,
+				PasswordHash:   row.PasswordHash,
+				MaxClicks:      row.MaxClicks,
+				ActivatesAt:    row.ActivatesAt,
+				RoutingRules:   row.RoutingRules,
+				Variants:       row.Variants,
+				StickyVariants: row.StickyVariants,
+				QueryParams:    row.QueryParams,
+				ForwardQuery:   row.ForwardQuery,
+				Preview:        row.Preview,
+			},
+			custom: true,
+		}
+		if item.info.RedirectType == 0 {
+			item.info.RedirectType = models.DefaultRedirectType
+		}
+		items[i] = item
+
+		// Exported short URLs may be generated ones, so the custom length minimum does not apply
+		if !core.IsValidShortIdFormat(row.ShortUrl) || core.IsReservedPath(row.ShortUrl) {
+			item.err = models.ErrInvalidShortUrl
+			continue
+		}
+		domain, err := r.checkDomain(ctx, userId, row.Domain)
+		if err != nil {
+			item.err = err
+			continue
+		}
+		item.info.Domain = domain
+		if row.PasswordHash != "" && !strings.HasPrefix(row.PasswordHash, "$2") {
+			item.err = models.ErrBadRequest.WithMessage("password_hash must be a bcrypt hash")
+			continue
+		}
+		if err := r.checkDestinations(ctx, linkDestinations(row.OriginalUrl, row.RoutingRules, row.Variants)); err != nil {
+			item.err = err
+			continue
+		}
+		key := models.LinkKey(domain, row.ShortUrl)
+		if seen[key] {
+			if onConflict == models.ImportConflictRename {
+				item.custom = false
+				item.renamed = true
+			} else {
+				item.taken = true
+			}
+		}
+		seen[key] = true
+	}
+
+	err = r.saveBulk(ctx, userId, items, onConflict == models.ImportConflictRename, onConflict == models.ImportConflictFail)
+
+	results := make([]models.ImportRowRes, len(items))
+	for i, item := range items {
+		res := models.ImportRowRes{Index: i, ShortUrl: item.info.ShortUrl}
+		if item.saved != nil {
+			res.ShortLink = r.Links.Link(item.saved.Domain, item.saved.ShortUrl)
+		}
+		switch {
+		case item.saved != nil && item.renamed:
+			res.Status = models.ImportStatusRenamed
+		case item.saved != nil:
+			res.Status = models.ImportStatusCreated
+		case item.taken && onConflict == models.ImportConflictSkip, item.err == nil && !item.taken:
+			// The second case is a valid row left out of an aborted import
+			res.Status = models.ImportStatusSkipped
+		case item.taken:
+			res.Status = models.ImportStatusFailed
+			res.Error = models.ErrShortUrlTaken
+		default:
+			res.Status = models.ImportStatusFailed
+			res.Error = item.err
+		}
+		results[i] = res
+	}
+	return results, err
+}
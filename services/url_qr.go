@@ -0,0 +1,97 @@
+package services
+
+import (
+	"U-235/core/qr"
+	"U-235/core/workspaces"
+	"U-235/models"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// QR code option defaults and bounds
+const (
+	defaultQrSize  = 256
+	minQrSize      = 64
+	maxQrSize      = 2048
+	maxQrMargin    = 16
+	defaultQrLevel = "M"
+)
+
+// UrlQrCodeService renders a QR code of the full short link of a link in one of
+// userId's workspaces; every member role can render it
+func (r *ShortUrlService) UrlQrCodeService(userId uuid.UUID, urlId uuid.UUID, req *models.QrCodeReq, ctx context.Context) (*models.QrCodeRes, error) {
+	opts, level, err := qrOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	urlInfo, err := authorizeUrl(ctx, r.PsqlRepo, r.Workspaces, userId, urlId, workspaces.RoleViewer, "view")
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := qr.Encode(r.Links.Link(urlInfo.Domain, urlInfo.ShortUrl), level)
+	if err != nil {
+		return nil, models.ErrBadRequest.WithMessage("The short link is too long for a QR code")
+	}
+
+	res := &models.QrCodeRes{ShortUrl: urlInfo.ShortUrl}
+	var buf bytes.Buffer
+	if req.Format == models.QrFormatSvg {
+		res.ContentType = "image/svg+xml"
+		err = code.SVG(&buf, opts)
+	} else {
+		res.ContentType = "image/png"
+		err = code.PNG(&buf, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+	res.Image = buf.Bytes()
+	return res, nil
+}
+
+// qrOptions fills in the defaults of req and checks its values
+func qrOptions(req *models.QrCodeReq) (qr.Options, qr.Level, error) {
+	if req.Format == "" {
+		req.Format = models.QrFormatPng
+	}
+	if req.Format != models.QrFormatPng && req.Format != models.QrFormatSvg {
+		return qr.Options{}, 0, models.ErrBadRequest.WithMessage("format must be png or svg")
+	}
+	if req.Size == 0 {
+		req.Size = defaultQrSize
+	}
+	if req.Size < minQrSize || req.Size > maxQrSize {
+		return qr.Options{}, 0, models.ErrBadRequest.WithMessage(fmt.Sprintf("size must be between %d and %d pixels", minQrSize, maxQrSize))
+	}
+	if req.Margin < 0 || req.Margin > maxQrMargin {
+		return qr.Options{}, 0, models.ErrBadRequest.WithMessage(fmt.Sprintf("margin must be between 0 and %d modules", maxQrMargin))
+	}
+	if req.Level == "" {
+		req.Level = defaultQrLevel
+	}
+	level, err := qr.ParseLevel(req.Level)
+	if err != nil {
+		return qr.Options{}, 0, models.ErrBadRequest.WithMessage("level must be L, M, Q or H")
+	}
+
+	opts := qr.Options{Size: req.Size, Margin: req.Margin}
+	if req.Foreground != "" {
+		fg, err := qr.ParseColor(req.Foreground)
+		if err != nil {
+			return qr.Options{}, 0, models.ErrBadRequest.WithMessage("fg must be a hex colour such as 000000")
+		}
+		opts.Foreground = fg
+	}
+	if req.Background != "" {
+		bg, err := qr.ParseColor(req.Background)
+		if err != nil {
+			return qr.Options{}, 0, models.ErrBadRequest.WithMessage("bg must be a hex colour such as ffffff")
+		}
+		opts.Background = bg
+	}
+	return opts, level, nil
+}
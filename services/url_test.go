@@ -0,0 +1,521 @@
+package services
+
+import (
+	"U-235/core/links"
+	"U-235/models"
+	"U-235/repositories"
+	"U-235/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeUrlsPsql keeps links in memory, by link key, with the conflict handling of
+// the multi-row INSERT
+type fakeUrlsPsql struct {
+	repositories.UrlsPsql
+
+	urls       map[string]models.ShortenedUrlInfoRes
+	clicksUsed map[uuid.UUID]int64
+	expired    []string
+}
+
+func newFakeUrlsPsql() *fakeUrlsPsql {
+	return &fakeUrlsPsql{
+		urls:       make(map[string]models.ShortenedUrlInfoRes),
+		clicksUsed: make(map[uuid.UUID]int64),
+	}
+}
+
+func (f *fakeUrlsPsql) SaveUrls(ctx context.Context, urls []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error) {
+	return insertFakeUrls(f.urls, urls), nil
+}
+
+func (f *fakeUrlsPsql) SaveUrl(ctx context.Context, urlInfo *models.ShortenedUrlInfoReq) (*models.ShortenedUrlInfoRes, *models.PsqlRollback, error) {
+	saved := insertFakeUrls(f.urls, []models.ShortenedUrlInfoReq{*urlInfo})
+	if len(saved) == 0 {
+		return nil, nil, repositories.ErrShortUrlExists
+	}
+	return &saved[0], &models.PsqlRollback{UserId: urlInfo.UserId, UrlRecordId: saved[0].Id}, nil
+}
+
+func (f *fakeUrlsPsql) BeginUrlBatch(ctx context.Context) (repositories.UrlBatch, error) {
+	return &fakeUrlBatch{repo: f, staged: make(map[string]models.ShortenedUrlInfoRes)}, nil
+}
+
+func (f *fakeUrlsPsql) DeleteUrlRecords(ctx context.Context, userId uuid.UUID, urlRecordIds []uuid.UUID) error {
+	for key, url := range f.urls {
+		for _, id := range urlRecordIds {
+			if url.Id == id {
+				delete(f.urls, key)
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeUrlsPsql) GetClicksUsed(ctx context.Context, urlId uuid.UUID) (int64, error) {
+	return f.clicksUsed[urlId], nil
+}
+
+func (f *fakeUrlsPsql) SetClicksUsed(ctx context.Context, urlId uuid.UUID, used int64) error {
+	f.clicksUsed[urlId] = max(f.clicksUsed[urlId], used)
+	return nil
+}
+
+func (f *fakeUrlsPsql) GetActiveUrlByShortUrl(ctx context.Context, domain string, shortUrl string) (*models.ShortenedUrlInfoRes, error) {
+	url, ok := f.urls[models.LinkKey(domain, shortUrl)]
+	if !ok || !url.IsActive {
+		return nil, sql.ErrNoRows
+	}
+	return &url, nil
+}
+
+func (f *fakeUrlsPsql) GetUrlInfoByRecordId(ctx context.Context, urlRecordId uuid.UUID) (*models.ShortenedUrlInfoRes, error) {
+	for _, url := range f.urls {
+		if url.Id == urlRecordId {
+			return &url, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// UpdateUrl moves the row to its new link key unless another link holds it, and
+// starts the clicks used of a reactivated link over like the UPDATE does
+func (f *fakeUrlsPsql) UpdateUrl(ctx context.Context, urlId uuid.UUID, urlInfo *models.ShortenedUrlInfoReq) (*models.ShortenedUrlInfoRes, error) {
+	current, err := f.GetUrlInfoByRecordId(ctx, urlId)
+	if err != nil {
+		return nil, err
+	}
+	key := models.LinkKey(urlInfo.Domain, urlInfo.ShortUrl)
+	if other, taken := f.urls[key]; taken && other.Id != urlId {
+		return nil, repositories.ErrShortUrlExists
+	}
+	if !current.IsActive && urlInfo.IsActive {
+		f.clicksUsed[urlId] = 0
+	}
+
+	updated := *current
+	updated.OriginalUrl, updated.ShortUrl, updated.ExpiresAt = urlInfo.OriginalUrl, urlInfo.ShortUrl, urlInfo.ExpiresAt
+	updated.IsActive, updated.RedirectType, updated.PasswordHash = urlInfo.IsActive, urlInfo.RedirectType, urlInfo.PasswordHash
+	updated.ActivatesAt, updated.Preview = urlInfo.ActivatesAt, urlInfo.Preview
+	updated.IsProtected = updated.PasswordHash != ""
+	delete(f.urls, current.LinkKey())
+	f.urls[key] = updated
+	return &updated, nil
+}
+
+func (f *fakeUrlsPsql) MarkUrlAsExpired(ctx context.Context, domain string, shortUrl string) error {
+	f.expired = append(f.expired, models.LinkKey(domain, shortUrl))
+	return nil
+}
+
+// fakeUrlBatch only adds its rows to the repository on Commit
+type fakeUrlBatch struct {
+	repo   *fakeUrlsPsql
+	staged map[string]models.ShortenedUrlInfoRes
+}
+
+func (b *fakeUrlBatch) SaveUrls(ctx context.Context, urls []models.ShortenedUrlInfoReq) ([]models.ShortenedUrlInfoRes, error) {
+	var free []models.ShortenedUrlInfoReq
+	for _, url := range urls {
+		if _, taken := b.repo.urls[models.LinkKey(url.Domain, url.ShortUrl)]; !taken {
+			free = append(free, url)
+		}
+	}
+	return insertFakeUrls(b.staged, free), nil
+}
+
+func (b *fakeUrlBatch) Commit() error {
+	for key, url := range b.staged {
+		b.repo.urls[key] = url
+	}
+	return nil
+}
+
+func (b *fakeUrlBatch) Rollback() error {
+	b.staged = nil
+	return nil
+}
+
+// insertFakeUrls adds the urls whose link key is free to rows and returns them
+func insertFakeUrls(rows map[string]models.ShortenedUrlInfoRes, urls []models.ShortenedUrlInfoReq) []models.ShortenedUrlInfoRes {
+	var saved []models.ShortenedUrlInfoRes
+	for _, url := range urls {
+		key := models.LinkKey(url.Domain, url.ShortUrl)
+		if _, taken := rows[key]; taken {
+			continue
+		}
+		row := models.ShortenedUrlInfoRes{
+			Id:          uuid.New(),
+			UserId:      url.UserId,
+			WorkspaceId: url.WorkspaceId,
+			OriginalUrl: url.OriginalUrl,
+			ShortUrl:    url.ShortUrl,
+			Domain:      url.Domain,
+			ExpiresAt:   url.ExpiresAt,
+			IsActive:    url.IsActive,
+			MaxClicks:   url.MaxClicks,
+			ActivatesAt: url.ActivatesAt,
+			CreatedAt:   time.Now(),
+		}
+		rows[key] = row
+		saved = append(saved, row)
+	}
+	return saved
+}
+
+var errRedisDown = errors.New("redis down")
+
+// fakeRedisRepo caches links and click counters in memory like the Redis scripts do
+type fakeRedisRepo struct {
+	repositories.RedisRepo
+
+	urls       map[string]*models.CachedUrl
+	counters   map[string]int64
+	failSave   bool
+	failDelete bool
+}
+
+func newFakeRedisRepo() *fakeRedisRepo {
+	return &fakeRedisRepo{
+		urls:     make(map[string]*models.CachedUrl),
+		counters: make(map[string]int64),
+	}
+}
+
+func (f *fakeRedisRepo) GetUrl(ctx context.Context, shortUrl string) (*models.CachedUrl, bool) {
+	entry, ok := f.urls[shortUrl]
+	return entry, ok
+}
+
+func (f *fakeRedisRepo) SaveUrl(ctx context.Context, shortUrl string, entry *models.CachedUrl, ttl time.Duration) error {
+	if f.failSave {
+		return errRedisDown
+	}
+	f.urls[shortUrl] = entry
+	return nil
+}
+
+func (f *fakeRedisRepo) GetOriginalUrl(ctx context.Context, shortUrl string) (string, bool) {
+	entry, ok := f.urls[shortUrl]
+	if !ok {
+		return "", false
+	}
+	return entry.OriginalUrl, true
+}
+
+func (f *fakeRedisRepo) SaveUrls(ctx context.Context, writes []repositories.CachedUrlWrite) error {
+	if f.failSave {
+		return errRedisDown
+	}
+	for _, write := range writes {
+		f.urls[write.ShortUrl] = write.Entry
+	}
+	return nil
+}
+
+func (f *fakeRedisRepo) DeleteKeys(ctx context.Context, shortUrl string) error {
+	if f.failDelete {
+		return errRedisDown
+	}
+	delete(f.urls, shortUrl)
+	return nil
+}
+
+func (f *fakeRedisRepo) ConsumeClick(ctx context.Context, shortUrl string, maxClicks int) (int64, error) {
+	used, ok := f.counters[shortUrl]
+	if !ok {
+		return 0, repositories.ErrClickCounterMissing
+	}
+	if used >= int64(maxClicks) {
+		return 0, repositories.ErrClickLimitReached
+	}
+	f.counters[shortUrl] = used + 1
+	return used + 1, nil
+}
+
+func (f *fakeRedisRepo) ResetClickCounter(ctx context.Context, shortUrl string) error {
+	delete(f.counters, shortUrl)
+	return f.SeedClickCounter(ctx, shortUrl, 0)
+}
+
+func (f *fakeRedisRepo) SeedClickCounter(ctx context.Context, shortUrl string, used int64) error {
+	if _, ok := f.urls[shortUrl]; !ok {
+		return nil
+	}
+	if _, ok := f.counters[shortUrl]; !ok {
+		f.counters[shortUrl] = used
+	}
+	return nil
+}
+
+// fakeWorkspaces lets every user act in every workspace
+type fakeWorkspaces struct {
+	WorkspaceServices
+}
+
+func (fakeWorkspaces) Authorize(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, minRole string) (uuid.UUID, string, error) {
+	return workspaceId, "owner", nil
+}
+
+// sequentialIds generates g000001, g000002, ... for the requested length, after
+// handing out the IDs in first
+type sequentialIds struct {
+	first []string
+	next  int
+}
+
+func (s *sequentialIds) Generate(ctx context.Context, length int) (string, error) {
+	if len(s.first) > 0 {
+		id := s.first[0]
+		s.first = s.first[1:]
+		return id, nil
+	}
+	s.next++
+	return fmt.Sprintf("g%0*d", length-1, s.next), nil
+}
+
+func newTestUrlService(psql *fakeUrlsPsql, redis *fakeRedisRepo, ids *sequentialIds) *ShortUrlService {
+	builder, err := links.NewBuilder("u235.link")
+	if err != nil {
+		panic(err)
+	}
+	return NewShortUrlService(redis, psql, ids, 7, nil, builder, nil, fakeWorkspaces{})
+}
+
+func TestUpdateUrlService(t *testing.T) {
+	future := time.Now().Add(48 * time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		inactive   bool // the link was deleted or used up its clicks
+		req        models.UpdateUrlReq
+		failCache  bool
+		wantErr    error
+		wantKey    string // where the link is cached afterwards, "" for nowhere
+		wantTarget string
+	}{
+		{name: "destination", req: models.UpdateUrlReq{OriginalUrl: ptr("https://example.com/new")}, wantKey: "old-slug", wantTarget: "https://example.com/new"},
+		{name: "slug", req: models.UpdateUrlReq{ShortUrl: ptr("new-slug")}, wantKey: "new-slug", wantTarget: "https://example.com/old"},
+		{name: "slug live in redis", req: models.UpdateUrlReq{ShortUrl: ptr("live-slug")}, wantErr: models.ErrShortUrlTaken, wantKey: "old-slug", wantTarget: "https://example.com/old"},
+		{name: "slug taken in postgres", req: models.UpdateUrlReq{ShortUrl: ptr("taken-one")}, wantErr: models.ErrShortUrlTaken, wantKey: "old-slug", wantTarget: "https://example.com/old"},
+		{name: "invalid slug", req: models.UpdateUrlReq{ShortUrl: ptr("ab")}, wantErr: models.ErrInvalidShortUrl, wantKey: "old-slug", wantTarget: "https://example.com/old"},
+		{name: "expiry in the past", req: models.UpdateUrlReq{ExpiresAt: &past}, wantErr: models.ErrBadRequest, wantKey: "old-slug", wantTarget: "https://example.com/old"},
+		{name: "reactivate", inactive: true, req: models.UpdateUrlReq{Reactivate: true, ExpiresAt: &future}, wantKey: "old-slug", wantTarget: "https://example.com/old"},
+		{name: "edit an inactive link", inactive: true, req: models.UpdateUrlReq{OriginalUrl: ptr("https://example.com/new")}},
+		{name: "cache unavailable", req: models.UpdateUrlReq{ShortUrl: ptr("new-slug")}, failCache: true, wantErr: errRedisDown, wantKey: "old-slug", wantTarget: "https://example.com/old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+			service := newTestUrlService(psql, redis, &sequentialIds{})
+			seedTakenUrl(psql, "taken-one")
+			redis.urls["live-slug"] = &models.CachedUrl{OriginalUrl: "https://example.com/other"}
+
+			link := models.ShortenedUrlInfoRes{
+				Id:           uuid.New(),
+				OriginalUrl:  "https://example.com/old",
+				ShortUrl:     "old-slug",
+				ExpiresAt:    time.Now().Add(time.Hour),
+				IsActive:     !tt.inactive,
+				RedirectType: http.StatusFound,
+				MaxClicks:    3,
+			}
+			psql.urls["old-slug"] = link
+			psql.clicksUsed[link.Id] = 3
+			if !tt.inactive {
+				redis.urls["old-slug"] = newCachedUrl(&link)
+				redis.counters["old-slug"] = 1
+			} else {
+				redis.counters["old-slug"] = 3
+			}
+			redis.failSave = tt.failCache
+
+			res, err := service.UpdateUrlService(uuid.New(), link.Id, &tt.req, context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUrlService() error = %v, want %v", err, tt.wantErr)
+			}
+
+			for key, entry := range redis.urls {
+				if key == "live-slug" {
+					continue
+				}
+				if key != tt.wantKey || entry.OriginalUrl != tt.wantTarget {
+					t.Errorf("cached %s -> %s, want %q -> %q", key, entry.OriginalUrl, tt.wantKey, tt.wantTarget)
+				}
+			}
+			if _, cached := redis.urls[tt.wantKey]; tt.wantKey != "" && !cached {
+				t.Errorf("%s is not cached", tt.wantKey)
+			}
+			// The row is rolled back along with a failed cache update
+			stored, _ := psql.GetUrlInfoByRecordId(context.Background(), link.Id)
+			if err != nil && (stored.ShortUrl != "old-slug" || stored.OriginalUrl != "https://example.com/old") {
+				t.Errorf("failed update left the row changed: %+v", stored)
+			}
+			if err == nil && (res.Slug != stored.ShortUrl || res.ShortLink != "https://u235.link/"+stored.ShortUrl) {
+				t.Errorf("response slug = %q, short link = %q; want the stored slug %q", res.Slug, res.ShortLink, stored.ShortUrl)
+			}
+			if tt.req.Reactivate && (redis.counters["old-slug"] != 0 || psql.clicksUsed[link.Id] != 0) {
+				t.Errorf("reactivated link kept its clicks: counter = %d, stored = %d", redis.counters["old-slug"], psql.clicksUsed[link.Id])
+			}
+		})
+	}
+}
+
+func TestResolveShortUrl(t *testing.T) {
+	tests := []struct {
+		name       string
+		cached     bool
+		row        *models.ShortenedUrlInfoRes
+		failSave   bool
+		wantErr    error
+		wantCached bool
+	}{
+		{name: "cached", cached: true, wantCached: true},
+		{name: "cache miss re-warms", row: &models.ShortenedUrlInfoRes{IsActive: true, ExpiresAt: time.Now().Add(time.Hour)}, wantCached: true},
+		{name: "cache miss with redis down", row: &models.ShortenedUrlInfoRes{IsActive: true, ExpiresAt: time.Now().Add(time.Hour)}, failSave: true},
+		{name: "expired row", row: &models.ShortenedUrlInfoRes{IsActive: true, ExpiresAt: time.Now().Add(-time.Minute)}, wantErr: models.ErrUrlNotFound},
+		{name: "inactive row", row: &models.ShortenedUrlInfoRes{ExpiresAt: time.Now().Add(time.Hour)}, wantErr: models.ErrUrlNotFound},
+		{name: "scheduled row", row: &models.ShortenedUrlInfoRes{IsActive: true, ActivatesAt: ptr(time.Now().Add(time.Hour)), ExpiresAt: time.Now().Add(2 * time.Hour)}, wantErr: models.ErrUrlNotYetActive},
+		{name: "unknown link", wantErr: models.ErrUrlNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+			service := newTestUrlService(psql, redis, &sequentialIds{})
+			redis.failSave = tt.failSave
+			if tt.cached {
+				redis.urls["abc1234"] = &models.CachedUrl{OriginalUrl: "https://example.com"}
+			}
+			if tt.row != nil {
+				row := *tt.row
+				row.Id, row.ShortUrl, row.OriginalUrl, row.RedirectType = uuid.New(), "abc1234", "https://example.com", http.StatusMovedPermanently
+				psql.urls["abc1234"] = row
+			}
+
+			entry, err := service.ResolveShortUrl(context.Background(), "abc1234")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolveShortUrl() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, cached := redis.urls["abc1234"]; cached != tt.wantCached {
+				t.Errorf("cached after lookup = %t, want %t", cached, tt.wantCached)
+			}
+			if err != nil {
+				return
+			}
+			if entry.OriginalUrl != "https://example.com" {
+				t.Errorf("OriginalUrl = %q, want https://example.com", entry.OriginalUrl)
+			}
+			if tt.row != nil && entry.RedirectType != http.StatusMovedPermanently {
+				t.Errorf("RedirectType = %d, want the row's %d", entry.RedirectType, http.StatusMovedPermanently)
+			}
+			if tt.cached && entry.RedirectType != models.DefaultRedirectType {
+				t.Errorf("RedirectType = %d, want the default %d for entries without one", entry.RedirectType, models.DefaultRedirectType)
+			}
+		})
+	}
+}
+
+func TestConsumeClick(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxClicks  int
+		stored     int64 // clicks used in Postgres when the counter is missing
+		counter    *int64
+		wantErr    error
+		wantUsed   int64
+		wantExpire bool
+	}{
+		{name: "unlimited link", maxClicks: 0},
+		{name: "seeded from stored clicks", maxClicks: 5, stored: 2, wantUsed: 3},
+		{name: "seeded with the last click", maxClicks: 3, stored: 2, wantUsed: 3, wantExpire: true},
+		{name: "seeded at the limit", maxClicks: 3, stored: 3, wantErr: models.ErrUrlNotFound, wantUsed: 3},
+		{name: "existing counter wins over stored clicks", maxClicks: 5, stored: 1, counter: ptr(int64(3)), wantUsed: 4},
+		{name: "counter at the limit", maxClicks: 2, stored: 2, counter: ptr(int64(2)), wantErr: models.ErrUrlNotFound, wantUsed: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+			service := newTestUrlService(psql, redis, &sequentialIds{})
+
+			entry := &models.CachedUrl{Id: uuid.New(), OriginalUrl: "https://example.com", MaxClicks: tt.maxClicks}
+			redis.urls["abc1234"] = entry
+			psql.clicksUsed[entry.Id] = tt.stored
+			if tt.counter != nil {
+				redis.counters["abc1234"] = *tt.counter
+			}
+
+			err := service.ConsumeClick(context.Background(), "abc1234", entry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConsumeClick() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := redis.counters["abc1234"]; got != tt.wantUsed {
+				t.Errorf("counter = %d, want %d", got, tt.wantUsed)
+			}
+			if got := psql.clicksUsed[entry.Id]; got != tt.wantUsed {
+				t.Errorf("stored clicks used = %d, want %d", got, tt.wantUsed)
+			}
+			_, cached := redis.urls["abc1234"]
+			if expired := len(psql.expired) > 0; expired != tt.wantExpire || cached == tt.wantExpire {
+				t.Errorf("expired = %t, cached = %t; want expired = %t", expired, cached, tt.wantExpire)
+			}
+		})
+	}
+}
+
+func TestUnlockShortUrl(t *testing.T) {
+	hash, err := utils.HashPassword("s3cret")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		hash       string
+		staleCache bool // the cache still marks the link protected
+		password   string
+		wantErr    error
+	}{
+		{name: "correct password", hash: hash, password: "s3cret"},
+		{name: "wrong password", hash: hash, password: "guess", wantErr: models.ErrInvalidPassword},
+		{name: "unprotected link", password: "anything", wantErr: models.ErrBadRequest},
+		{name: "password removed since cached", staleCache: true, password: "anything", wantErr: models.ErrBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psql, redis := newFakeUrlsPsql(), newFakeRedisRepo()
+			service := newTestUrlService(psql, redis, &sequentialIds{})
+			psql.urls["abc1234"] = models.ShortenedUrlInfoRes{
+				Id:           uuid.New(),
+				OriginalUrl:  "https://example.com/private",
+				ShortUrl:     "abc1234",
+				ExpiresAt:    time.Now().Add(time.Hour),
+				IsActive:     true,
+				IsProtected:  tt.hash != "",
+				PasswordHash: tt.hash,
+			}
+			if tt.staleCache {
+				redis.urls["abc1234"] = &models.CachedUrl{Id: psql.urls["abc1234"].Id, OriginalUrl: "https://example.com/private", Protected: true}
+			}
+
+			entry, err := service.UnlockShortUrl(context.Background(), "abc1234", tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnlockShortUrl() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && entry.PasswordVer != utils.PasswordVersion(hash) {
+				t.Errorf("unlocked entry has password version %q, want the current one", entry.PasswordVer)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -6,23 +6,44 @@ import (
 	"U-235/repositories"
 	"U-235/utils"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"time"
+)
+
+// refreshTokenBytes is the entropy of an opaque refresh token
+const refreshTokenBytes = 32
+
+var (
+	ErrInvalidRefreshToken = models.NewAppError("INVALID_REFRESH_TOKEN", "Invalid or expired refresh token", http.StatusUnauthorized)
+	ErrRefreshTokenReuse   = models.NewAppError("REFRESH_TOKEN_REUSED", "Refresh token reuse detected, please log in again", http.StatusUnauthorized)
 )
 
 type UserServices interface {
 	UserRegistrationService(user models.UserRegister, ctx context.Context) (*models.User, error)
 	UserLoginService(login models.UserLogin, ctx context.Context) (*models.AuthResponse, error)
 	UserProfileService(userId uuid.UUID, ctx context.Context) (*models.UserProfile, error)
+	RefreshTokenService(refreshToken string, ctx context.Context) (*models.TokenPair, error)
+	LogoutService(claims *middleware.TokenClaims, refreshToken string, ctx context.Context) error
 }
 
 type UserService struct {
-	repo repositories.UserRepository
+	repo            repositories.UserRepository
+	tokenRepo       repositories.RefreshTokenRepo
+	revocations     repositories.TokenRevocationRepo
+	refreshTokenTTL time.Duration
 }
 
-func NewUserService(repo repositories.UserRepository) UserServices {
+func NewUserService(repo repositories.UserRepository, tokenRepo repositories.RefreshTokenRepo, revocations repositories.TokenRevocationRepo, refreshTokenTTL time.Duration) UserServices {
 	return &UserService{
-		repo: repo,
+		repo:            repo,
+		tokenRepo:       tokenRepo,
+		revocations:     revocations,
+		refreshTokenTTL: refreshTokenTTL,
 	}
 }
 
@@ -58,18 +79,136 @@ func (u *UserService) UserLoginService(user models.UserLogin, ctx context.Contex
 		return nil, fmt.Errorf("failed to get user details: %w", err)
 	}
 
-	// Generate token
-	token, err := middleware.CreateToken(userID)
+	// Every login starts a new refresh token family
+	tokens, err := u.issueTokens(ctx, userDetails, uuid.New(), uuid.Nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create token: %w", err)
+		return nil, err
 	}
 
 	return &models.AuthResponse{
-		User:  *userDetails,
-		Token: token,
+		User:         *userDetails,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}, nil
+}
+
+// RefreshTokenService rotates a refresh token: the presented token is retired and a
+// new access/refresh pair is issued in the same family. Presenting a retired token
+// again means it leaked, so the whole family is revoked. The access token picks up
+// the user's current role, and suspended users cannot refresh at all.
+func (u *UserService) RefreshTokenService(refreshToken string, ctx context.Context) (*models.TokenPair, error) {
+	stored, err := u.tokenRepo.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil {
+		if stored.ReplacedBy != nil {
+			u.revokeFamilyOnReuse(ctx, stored)
+			return nil, ErrRefreshTokenReuse
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := u.repo.GetUserByID(stored.UserId, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user details: %w", err)
+	}
+	if user.SuspendedAt != nil {
+		return nil, models.ErrAccountSuspended
+	}
+
+	tokens, err := u.issueTokens(ctx, user, stored.FamilyId, stored.Id)
+	if errors.Is(err, repositories.ErrRefreshTokenReused) {
+		// Lost a race with another request presenting the same token
+		u.revokeFamilyOnReuse(ctx, stored)
+		return nil, ErrRefreshTokenReuse
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// LogoutService revokes the caller's access token and, when given, the refresh token family.
+func (u *UserService) LogoutService(claims *middleware.TokenClaims, refreshToken string, ctx context.Context) error {
+	if claims != nil && claims.ID != "" && claims.ExpiresAt != nil {
+		if err := u.revocations.Revoke(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := u.tokenRepo.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	// A token belonging to someone else is ignored rather than revoked
+	if claims != nil && stored.UserId != claims.UserID {
+		return nil
+	}
+
+	return u.tokenRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyId)
+}
+
+// issueTokens creates an access token and a refresh token in familyId. When
+// replacing is set, that refresh token is retired atomically with the insert.
+func (u *UserService) issueTokens(ctx context.Context, user *models.User, familyId uuid.UUID, replacing uuid.UUID) (*models.TokenPair, error) {
+	accessToken, err := middleware.CreateToken(user.Id, user.Role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &models.RefreshToken{
+		UserId:    user.Id,
+		FamilyId:  familyId,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(u.refreshTokenTTL),
+	}
+
+	if replacing == uuid.Nil {
+		err = u.tokenRepo.CreateRefreshToken(ctx, stored)
+	} else {
+		err = u.tokenRepo.RotateRefreshToken(ctx, replacing, stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(middleware.TokenExpiration.Seconds()),
 	}, nil
 }
 
+func (u *UserService) revokeFamilyOnReuse(ctx context.Context, stored *models.RefreshToken) {
+	log.Printf("Refresh token reuse detected for user %s, revoking family %s", stored.UserId, stored.FamilyId)
+	if err := u.tokenRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyId); err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", stored.FamilyId, err)
+	}
+}
+
 func (u *UserService) UserProfileService(userId uuid.UUID, ctx context.Context) (*models.UserProfile, error) {
 	res, err := u.repo.UserProfileService(userId, ctx)
 	if err != nil {
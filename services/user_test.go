@@ -0,0 +1,180 @@
+package services
+
+import (
+	"U-235/middleware"
+	"U-235/models"
+	"U-235/repositories"
+	"U-235/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// fakeRefreshTokens stores refresh tokens by hash and rotates them like the
+// conditional UPDATE in Postgres
+type fakeRefreshTokens struct {
+	tokens map[string]*models.RefreshToken
+}
+
+func (f *fakeRefreshTokens) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	token.Id = uuid.New()
+	f.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (f *fakeRefreshTokens) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	token, ok := f.tokens[tokenHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	stored := *token
+	return &stored, nil
+}
+
+func (f *fakeRefreshTokens) RotateRefreshToken(ctx context.Context, oldTokenId uuid.UUID, newToken *models.RefreshToken) error {
+	for _, token := range f.tokens {
+		if token.Id != oldTokenId {
+			continue
+		}
+		if token.RevokedAt != nil {
+			return repositories.ErrRefreshTokenReused
+		}
+		if err := f.CreateRefreshToken(ctx, newToken); err != nil {
+			return err
+		}
+		now := time.Now()
+		token.RevokedAt, token.ReplacedBy = &now, &newToken.Id
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func (f *fakeRefreshTokens) RevokeRefreshTokenFamily(ctx context.Context, familyId uuid.UUID) error {
+	now := time.Now()
+	for _, token := range f.tokens {
+		if token.FamilyId == familyId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// fakeJtiRevocations records revoked access token IDs
+type fakeJtiRevocations struct {
+	repositories.TokenRevocationRepo
+
+	jtis map[string]time.Duration
+}
+
+func (f *fakeJtiRevocations) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	f.jtis[jti] = ttl
+	return nil
+}
+
+func newTestUserService(users map[uuid.UUID]*models.User) (*UserService, *fakeRefreshTokens, *fakeJtiRevocations) {
+	tokens := &fakeRefreshTokens{tokens: map[string]*models.RefreshToken{}}
+	revocations := &fakeJtiRevocations{jtis: map[string]time.Duration{}}
+	service := NewUserService(&fakeUserRepo{users: users}, tokens, revocations, 24*time.Hour).(*UserService)
+	return service, tokens, revocations
+}
+
+func TestRefreshTokenService(t *testing.T) {
+	user := &models.User{Id: uuid.New(), Role: models.RoleUser}
+	service, tokens, _ := newTestUserService(map[uuid.UUID]*models.User{user.Id: user})
+	ctx := context.Background()
+
+	first, err := service.issueTokens(ctx, user, uuid.New(), uuid.Nil)
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+
+	second, err := service.RefreshTokenService(first.RefreshToken, ctx)
+	if err != nil {
+		t.Fatalf("RefreshTokenService() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if stored := tokens.tokens[utils.HashToken(first.RefreshToken)]; stored.RevokedAt == nil || stored.ReplacedBy == nil {
+		t.Errorf("rotated token was not retired: %+v", stored)
+	}
+
+	// Presenting the retired token again revokes the whole family
+	if _, err := service.RefreshTokenService(first.RefreshToken, ctx); !errors.Is(err, ErrRefreshTokenReuse) {
+		t.Fatalf("RefreshTokenService(reused token) error = %v, want %v", err, ErrRefreshTokenReuse)
+	}
+	if _, err := service.RefreshTokenService(second.RefreshToken, ctx); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshTokenService(after reuse) error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if _, err := service.RefreshTokenService("unknown", ctx); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshTokenService(unknown token) error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRefreshTokenServiceRejected(t *testing.T) {
+	suspendedAt := time.Now()
+	tests := []struct {
+		name    string
+		user    models.User
+		expires time.Duration
+		wantErr error
+	}{
+		{name: "expired token", user: models.User{Role: models.RoleUser}, expires: -time.Minute, wantErr: ErrInvalidRefreshToken},
+		{name: "suspended user", user: models.User{Role: models.RoleUser, SuspendedAt: &suspendedAt}, expires: time.Hour, wantErr: models.ErrAccountSuspended},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.Id = uuid.New()
+			service, tokens, _ := newTestUserService(map[uuid.UUID]*models.User{user.Id: &user})
+			_ = tokens.CreateRefreshToken(context.Background(), &models.RefreshToken{
+				UserId:    user.Id,
+				FamilyId:  uuid.New(),
+				TokenHash: utils.HashToken("token"),
+				ExpiresAt: time.Now().Add(tt.expires),
+			})
+
+			if _, err := service.RefreshTokenService("token", context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RefreshTokenService() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogoutService(t *testing.T) {
+	user := &models.User{Id: uuid.New(), Role: models.RoleUser}
+	service, tokens, revocations := newTestUserService(map[uuid.UUID]*models.User{user.Id: user})
+	ctx := context.Background()
+
+	pair, err := service.issueTokens(ctx, user, uuid.New(), uuid.Nil)
+	if err != nil {
+		t.Fatalf("issueTokens() error = %v", err)
+	}
+	claims := &middleware.TokenClaims{UserID: user.Id}
+	claims.ID = "jti-1"
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(10 * time.Minute))
+
+	// Someone else's refresh token is left alone
+	other := &middleware.TokenClaims{UserID: uuid.New()}
+	if err := service.LogoutService(other, pair.RefreshToken, ctx); err != nil {
+		t.Fatalf("LogoutService(other user) error = %v", err)
+	}
+	if tokens.tokens[utils.HashToken(pair.RefreshToken)].RevokedAt != nil {
+		t.Fatal("another user's logout revoked the refresh token")
+	}
+
+	if err := service.LogoutService(claims, pair.RefreshToken, ctx); err != nil {
+		t.Fatalf("LogoutService() error = %v", err)
+	}
+	if ttl, ok := revocations.jtis["jti-1"]; !ok || ttl <= 0 || ttl > 10*time.Minute {
+		t.Errorf("access token revoked = %t for %s, want its remaining lifetime", ok, ttl)
+	}
+	if _, err := service.RefreshTokenService(pair.RefreshToken, ctx); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshTokenService(after logout) error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"U-235/core/workspaces"
+	"U-235/models"
+	"U-235/repositories"
+	"U-235/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// invitationTokenBytes is the entropy of an invitation token
+const invitationTokenBytes = 30
+
+var (
+	ErrWorkspaceNotFound  = models.NewAppError("WORKSPACE_NOT_FOUND", "Workspace not found", http.StatusNotFound)
+	ErrInsufficientRole   = models.NewAppError("INSUFFICIENT_ROLE", "Your role in this workspace does not allow this", http.StatusForbidden)
+	ErrMemberNotFound     = models.NewAppError("MEMBER_NOT_FOUND", "Workspace member not found", http.StatusNotFound)
+	ErrLastOwner          = models.NewAppError("LAST_OWNER", "A workspace must keep at least one owner", http.StatusConflict)
+	ErrInvitationNotFound = models.NewAppError("INVITATION_NOT_FOUND", "Invitation not found, used or expired", http.StatusNotFound)
+	ErrInvitationEmail    = models.NewAppError("INVITATION_EMAIL_MISMATCH", "The invitation was sent to another email address", http.StatusForbidden)
+	ErrAlreadyMember      = models.NewAppError("ALREADY_MEMBER", "You are already a member of this workspace", http.StatusConflict)
+)
+
+type WorkspaceServices interface {
+	CreateWorkspace(ctx context.Context, userId uuid.UUID, req *models.CreateWorkspaceReq) (*models.Workspace, error)
+	ListWorkspaces(ctx context.Context, userId uuid.UUID) ([]models.Workspace, error)
+	ListMembers(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID) ([]models.WorkspaceMember, error)
+	RemoveMember(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, memberId uuid.UUID) error
+	CreateInvitation(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, req *models.CreateInvitationReq) (*models.CreateInvitationRes, error)
+	ListInvitations(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID) ([]models.WorkspaceInvitation, error)
+	RevokeInvitation(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, invitationId uuid.UUID) error
+	AcceptInvitation(ctx context.Context, userId uuid.UUID, token string) (*models.Workspace, error)
+	Authorize(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, minRole string) (uuid.UUID, string, error)
+}
+
+type WorkspaceService struct {
+	WorkspaceRepo repositories.WorkspaceRepo
+	UserRepo      repositories.UserRepository
+	// InvitationTTL is how long an invitation can be accepted
+	InvitationTTL time.Duration
+}
+
+func NewWorkspaceService(repo repositories.WorkspaceRepo, userRepo repositories.UserRepository, invitationTTL time.Duration) WorkspaceServices {
+	return &WorkspaceService{
+		WorkspaceRepo: repo,
+		UserRepo:      userRepo,
+		InvitationTTL: invitationTTL,
+	}
+}
+
+// Authorize checks that userId holds at least minRole in workspaceId and returns
+// the workspace and the user's role. uuid.Nil selects the user's personal workspace,
+// which the user owns. Workspaces the user is not a member of are ErrWorkspaceNotFound.
+func (w *WorkspaceService) Authorize(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, minRole string) (uuid.UUID, string, error) {
+	if workspaceId == uuid.Nil {
+		personal, err := w.WorkspaceRepo.PersonalWorkspace(ctx, userId)
+		return personal, workspaces.RoleOwner, err
+	}
+
+	role, err := w.WorkspaceRepo.GetMemberRole(ctx, workspaceId, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, "", ErrWorkspaceNotFound
+		}
+		return uuid.Nil, "", err
+	}
+	if !workspaces.Allows(role, minRole) {
+		return uuid.Nil, "", ErrInsufficientRole.WithMessage(fmt.Sprintf("This requires the %s role or higher in the workspace", minRole))
+	}
+	return workspaceId, role, nil
+}
+
+func (w *WorkspaceService) CreateWorkspace(ctx context.Context, userId uuid.UUID, req *models.CreateWorkspaceReq) (*models.Workspace, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, models.ErrBadRequest.WithMessage("Workspace name must not be blank")
+	}
+	return w.WorkspaceRepo.CreateWorkspace(ctx, userId, name)
+}
+
+// ListWorkspaces returns the user's workspaces, creating the personal one if needed
+func (w *WorkspaceService) ListWorkspaces(ctx context.Context, userId uuid.UUID) ([]models.Workspace, error) {
+	if _, err := w.WorkspaceRepo.PersonalWorkspace(ctx, userId); err != nil {
+		return nil, err
+	}
+	return w.WorkspaceRepo.ListWorkspaces(ctx, userId)
+}
+
+func (w *WorkspaceService) ListMembers(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID) ([]models.WorkspaceMember, error) {
+	if _, _, err := w.Authorize(ctx, userId, workspaceId, workspaces.RoleViewer); err != nil {
+		return nil, err
+	}
+	return w.WorkspaceRepo.ListMembers(ctx, workspaceId)
+}
+
+// RemoveMember removes memberId from the workspace. Any member can leave; removing
+// someone else needs a role that manages theirs (see workspaces.CanManage).
+func (w *WorkspaceService) RemoveMember(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, memberId uuid.UUID) error {
+	_, role, err := w.Authorize(ctx, userId, workspaceId, workspaces.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	if memberId != userId {
+		target, err := w.WorkspaceRepo.GetMemberRole(ctx, workspaceId, memberId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrMemberNotFound
+			}
+			return err
+		}
+		if !workspaces.CanManage(role, target) {
+			return ErrInsufficientRole.WithMessage(fmt.Sprintf("Your role cannot remove a member with the %s role", target))
+		}
+	}
+
+	err = w.WorkspaceRepo.RemoveMember(ctx, workspaceId, memberId)
+	switch {
+	case errors.Is(err, repositories.ErrLastOwner):
+		return ErrLastOwner
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrMemberNotFound
+	}
+	return err
+}
+
+// CreateInvitation invites an email address to the workspace with a role the
+// inviter manages. The token is returned once, for the inviter to pass on.
+func (w *WorkspaceService) CreateInvitation(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, req *models.CreateInvitationReq) (*models.CreateInvitationRes, error) {
+	_, role, err := w.Authorize(ctx, userId, workspaceId, workspaces.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !workspaces.CanManage(role, req.Role) {
+		return nil, ErrInsufficientRole.WithMessage(fmt.Sprintf("Your role cannot invite members with the %s role", req.Role))
+	}
+
+	token, err := utils.GenerateOpaqueToken(invitationTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := models.WorkspaceInvitation{
+		WorkspaceId: workspaceId,
+		Email:       strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:        req.Role,
+		InvitedBy:   userId,
+		ExpiresAt:   time.Now().Add(w.InvitationTTL),
+	}
+	if err := w.WorkspaceRepo.CreateInvitation(ctx, &invitation, utils.HashToken(token)); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateInvitationRes{
+		WorkspaceInvitation: invitation,
+		Token:               token,
+	}, nil
+}
+
+func (w *WorkspaceService) ListInvitations(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID) ([]models.WorkspaceInvitation, error) {
+	if _, _, err := w.Authorize(ctx, userId, workspaceId, workspaces.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return w.WorkspaceRepo.ListInvitations(ctx, workspaceId)
+}
+
+func (w *WorkspaceService) RevokeInvitation(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID, invitationId uuid.UUID) error {
+	if _, _, err := w.Authorize(ctx, userId, workspaceId, workspaces.RoleAdmin); err != nil {
+		return err
+	}
+	err := w.WorkspaceRepo.DeleteInvitation(ctx, workspaceId, invitationId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvitationNotFound
+	}
+	return err
+}
+
+// AcceptInvitation joins the workspace of an invitation sent to the user's email
+func (w *WorkspaceService) AcceptInvitation(ctx context.Context, userId uuid.UUID, token string) (*models.Workspace, error) {
+	user, err := w.UserRepo.GetUserByID(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := w.WorkspaceRepo.AcceptInvitation(ctx, utils.HashToken(token), userId, user.Email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrInvitationNotFound
+	case errors.Is(err, repositories.ErrInvitationEmail):
+		return nil, ErrInvitationEmail
+	case errors.Is(err, repositories.ErrAlreadyMember):
+		return nil, ErrAlreadyMember
+	}
+	return workspace, err
+}
+
+// authorizeUrl loads a link and checks that userId holds at least minRole in the
+// link's workspace. action completes the denial message, e.g. "delete".
+func authorizeUrl(ctx context.Context, urls repositories.UrlsPsql, access WorkspaceServices, userId uuid.UUID, urlId uuid.UUID, minRole string, action string) (*models.ShortenedUrlInfoRes, error) {
+	urlInfo, err := urls.GetUrlInfoByRecordId(ctx, urlId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrUrlNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if _, _, err := access.Authorize(ctx, userId, urlInfo.WorkspaceId, minRole); err != nil {
+		if errors.Is(err, ErrWorkspaceNotFound) || errors.Is(err, ErrInsufficientRole) {
+			return nil, models.ErrUrlAccessDenied.WithMessage(fmt.Sprintf("You do not have permission to %s this URL", action))
+		}
+		return nil, err
+	}
+	return urlInfo, nil
+}
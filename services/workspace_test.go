@@ -0,0 +1,121 @@
+package services
+
+import (
+	"U-235/core/workspaces"
+	"U-235/models"
+	"U-235/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+)
+
+type membership struct {
+	workspaceId uuid.UUID
+	userId      uuid.UUID
+}
+
+// fakeWorkspaceRepo knows the role of each member and every user's personal workspace
+type fakeWorkspaceRepo struct {
+	repositories.WorkspaceRepo
+
+	roles    map[membership]string
+	personal map[uuid.UUID]uuid.UUID
+}
+
+func (f *fakeWorkspaceRepo) PersonalWorkspace(ctx context.Context, userId uuid.UUID) (uuid.UUID, error) {
+	workspaceId, ok := f.personal[userId]
+	if !ok {
+		return uuid.Nil, sql.ErrNoRows
+	}
+	return workspaceId, nil
+}
+
+func (f *fakeWorkspaceRepo) GetMemberRole(ctx context.Context, workspaceId uuid.UUID, userId uuid.UUID) (string, error) {
+	role, ok := f.roles[membership{workspaceId, userId}]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return role, nil
+}
+
+func TestAuthorizeUrl(t *testing.T) {
+	team, owner, editor, viewer, outsider := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	repo := &fakeWorkspaceRepo{
+		roles: map[membership]string{
+			{team, owner}:  workspaces.RoleOwner,
+			{team, editor}: workspaces.RoleEditor,
+			{team, viewer}: workspaces.RoleViewer,
+		},
+		personal: map[uuid.UUID]uuid.UUID{outsider: uuid.New()},
+	}
+	access := NewWorkspaceService(repo, nil, 0)
+
+	psql := newFakeUrlsPsql()
+	link := models.ShortenedUrlInfoRes{Id: uuid.New(), WorkspaceId: team, ShortUrl: "team123", IsActive: true}
+	psql.urls[link.ShortUrl] = link
+
+	tests := []struct {
+		name    string
+		userId  uuid.UUID
+		urlId   uuid.UUID
+		minRole string
+		wantErr error
+	}{
+		{name: "owner edits", userId: owner, urlId: link.Id, minRole: workspaces.RoleEditor},
+		{name: "editor edits", userId: editor, urlId: link.Id, minRole: workspaces.RoleEditor},
+		{name: "viewer views", userId: viewer, urlId: link.Id, minRole: workspaces.RoleViewer},
+		{name: "viewer edits", userId: viewer, urlId: link.Id, minRole: workspaces.RoleEditor, wantErr: models.ErrUrlAccessDenied},
+		{name: "non-member views", userId: outsider, urlId: link.Id, minRole: workspaces.RoleViewer, wantErr: models.ErrUrlAccessDenied},
+		{name: "unknown link", userId: owner, urlId: uuid.New(), minRole: workspaces.RoleViewer, wantErr: models.ErrUrlNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlInfo, err := authorizeUrl(context.Background(), psql, access, tt.userId, tt.urlId, tt.minRole, "edit")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authorizeUrl() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && urlInfo.Id != link.Id {
+				t.Errorf("authorizeUrl() = %s, want %s", urlInfo.Id, link.Id)
+			}
+		})
+	}
+}
+
+func TestWorkspaceAuthorize(t *testing.T) {
+	team, personal, admin, viewer := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	access := NewWorkspaceService(&fakeWorkspaceRepo{
+		roles: map[membership]string{
+			{team, admin}:  workspaces.RoleAdmin,
+			{team, viewer}: workspaces.RoleViewer,
+		},
+		personal: map[uuid.UUID]uuid.UUID{viewer: personal},
+	}, nil, 0)
+
+	tests := []struct {
+		name          string
+		userId        uuid.UUID
+		workspaceId   uuid.UUID
+		minRole       string
+		wantWorkspace uuid.UUID
+		wantRole      string
+		wantErr       error
+	}{
+		{name: "personal workspace", userId: viewer, minRole: workspaces.RoleOwner, wantWorkspace: personal, wantRole: workspaces.RoleOwner},
+		{name: "higher role", userId: admin, workspaceId: team, minRole: workspaces.RoleEditor, wantWorkspace: team, wantRole: workspaces.RoleAdmin},
+		{name: "lower role", userId: viewer, workspaceId: team, minRole: workspaces.RoleEditor, wantErr: ErrInsufficientRole},
+		{name: "not a member", userId: uuid.New(), workspaceId: team, minRole: workspaces.RoleViewer, wantErr: ErrWorkspaceNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspaceId, role, err := access.Authorize(context.Background(), tt.userId, tt.workspaceId, tt.minRole)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authorize() error = %v, want %v", err, tt.wantErr)
+			}
+			if workspaceId != tt.wantWorkspace || role != tt.wantRole {
+				t.Errorf("Authorize() = %s, %q, want %s, %q", workspaceId, role, tt.wantWorkspace, tt.wantRole)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnvInt reads an integer setting, falling back to def when unset or invalid
+func GetEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid value %q for %s, using %d", value, key, def)
+		return def
+	}
+	return n
+}
+
+// GetEnvBool reads a boolean setting such as "true" or "0", falling back to def when unset or invalid
+func GetEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid value %q for %s, using %t", value, key, def)
+		return def
+	}
+	return b
+}
+
+// GetEnvDuration reads a duration setting such as "2s", falling back to def when unset or invalid
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid value %q for %s, using %s", value, key, def)
+		return def
+	}
+	return d
+}
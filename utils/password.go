@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 
 	"golang.org/x/crypto/bcrypt"
@@ -25,3 +27,13 @@ func HashPassword(password string) (string, error) {
 func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+// PasswordVersion returns a short fingerprint of a password hash. bcrypt salts every
+// hash, so each password change gives a new version, even back to the same password.
+func PasswordVersion(hashedPassword string) string {
+	if hashedPassword == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(hashedPassword))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,60 @@
+package utils
+
+import "strings"
+
+// uaRule maps a User-Agent substring to a family name; the first match wins,
+// so more specific tokens must come before the generic ones they contain.
+type uaRule struct {
+	token  string
+	family string
+}
+
+var browserRules = []uaRule{
+	{"bot", "Bot"},
+	{"spider", "Bot"},
+	{"crawler", "Bot"},
+	{"edg/", "Edge"},
+	{"edgios/", "Edge"},
+	{"edga/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+}
+
+var osRules = []uaRule{
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{"windows", "Windows"},
+	{"cros", "ChromeOS"},
+	{"macintosh", "macOS"},
+	{"mac os x", "macOS"},
+	{"linux", "Linux"},
+}
+
+// ParseUserAgent returns coarse browser and OS families for analytics breakdowns.
+// Unrecognised agents are reported as "Other".
+func ParseUserAgent(userAgent string) (browser, os string) {
+	ua := strings.ToLower(userAgent)
+	return matchFamily(ua, browserRules), matchFamily(ua, osRules)
+}
+
+func matchFamily(ua string, rules []uaRule) string {
+	if ua == "" {
+		return "Other"
+	}
+	for _, rule := range rules {
+		if strings.Contains(ua, rule.token) {
+			return rule.family
+		}
+	}
+	return "Other"
+}
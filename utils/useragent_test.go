@@ -0,0 +1,70 @@
+package utils
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name        string
+		userAgent   string
+		wantBrowser string
+		wantOs      string
+	}{
+		{
+			name:        "chrome on windows",
+			userAgent:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			wantBrowser: "Chrome",
+			wantOs:      "Windows",
+		},
+		{
+			name:        "edge on windows",
+			userAgent:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			wantBrowser: "Edge",
+			wantOs:      "Windows",
+		},
+		{
+			name:        "safari on iphone",
+			userAgent:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			wantBrowser: "Safari",
+			wantOs:      "iOS",
+		},
+		{
+			name:        "chrome on android",
+			userAgent:   "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			wantBrowser: "Chrome",
+			wantOs:      "Android",
+		},
+		{
+			name:        "firefox on linux",
+			userAgent:   "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			wantBrowser: "Firefox",
+			wantOs:      "Linux",
+		},
+		{
+			name:        "safari on macos",
+			userAgent:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			wantBrowser: "Safari",
+			wantOs:      "macOS",
+		},
+		{
+			name:        "crawler",
+			userAgent:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			wantBrowser: "Bot",
+			wantOs:      "Other",
+		},
+		{
+			name:        "empty",
+			userAgent:   "",
+			wantBrowser: "Other",
+			wantOs:      "Other",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			browser, os := ParseUserAgent(tt.userAgent)
+			if browser != tt.wantBrowser || os != tt.wantOs {
+				t.Errorf("ParseUserAgent() = (%q, %q), want (%q, %q)", browser, os, tt.wantBrowser, tt.wantOs)
+			}
+		})
+	}
+}
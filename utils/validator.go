@@ -3,6 +3,7 @@ package utils
 import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"reflect"
 	"strings"
 )
 
@@ -18,7 +19,26 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 
 // NewValidator initializes a new validator
 func NewValidator() *CustomValidator {
-	return &CustomValidator{validator: validator.New()}
+	v := validator.New()
+
+	// Report JSON field names in validation errors instead of Go field names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	// link_password is a link password of 4 to 72 bytes (bcrypt's limit), or the
+	// empty string, which leaves a new link unprotected and removes the password
+	// of an existing one
+	_ = v.RegisterValidation("link_password", func(fl validator.FieldLevel) bool {
+		n := len(fl.Field().String())
+		return n == 0 || (n >= 4 && n <= 72)
+	})
+
+	return &CustomValidator{validator: v}
 }
 
 // IsValidUUID User UUID Validator
@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+func TestLinkPasswordValidation(t *testing.T) {
+	type createReq struct {
+		Password string `validate:"link_password"`
+	}
+	type updateReq struct {
+		Password *string `validate:"omitnil,link_password"`
+	}
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"", true},
+		{"abc", false},
+		{"abcd", true},
+		{string(make([]byte, 72)), true},
+		{string(make([]byte, 73)), false},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		if err := v.Validate(&createReq{Password: tt.password}); (err == nil) != tt.valid {
+			t.Errorf("create with %d byte password: error = %v, want valid = %t", len(tt.password), err, tt.valid)
+		}
+		password := tt.password
+		if err := v.Validate(&updateReq{Password: &password}); (err == nil) != tt.valid {
+			t.Errorf("update with %d byte password: error = %v, want valid = %t", len(tt.password), err, tt.valid)
+		}
+	}
+	if err := v.Validate(&updateReq{}); err != nil {
+		t.Errorf("update without a password: error = %v", err)
+	}
+}